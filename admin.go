@@ -0,0 +1,316 @@
+package influxdbhelper
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	influxModels "github.com/influxdata/influxdb1-client/models"
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+// RetentionPolicy describes an InfluxDb retention policy.
+type RetentionPolicy struct {
+	Name string
+	// Duration is how long data is kept, or 0 to keep it forever.
+	Duration time.Duration
+	// ShardGroupDuration is the time range covered by each shard group, or
+	// 0 for the server default.
+	ShardGroupDuration time.Duration
+	// Replication is the number of copies of the data kept in a cluster.
+	// 0 is treated as 1.
+	Replication int
+	// Default is true if the retention policy is used for writes and
+	// queries that do not specify one.
+	Default bool
+}
+
+// execute runs a statement on db, and returns the series of the result.
+func (c *helperClient) execute(db, q string) ([]influxModels.Row, error) {
+	response, err := c.Query(influxClient.Query{Command: q, Database: db})
+
+	if response != nil {
+		if qErr := newQueryError(q, response, err); qErr != nil {
+			return nil, qErr
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Results) < 1 {
+		return nil, nil
+	}
+
+	return response.Results[0].Series, nil
+}
+
+// usingDB returns the db set with UseDB, clearing it if not retained.
+func (c *helperClient) usingDB() (string, error) {
+	if c.using == nil || c.using.db == nil {
+		return "", ErrNoDatabase
+	}
+
+	db := c.using.db.value
+	if !c.using.db.retain {
+		c.using.db = nil
+	}
+
+	return db, nil
+}
+
+// formatDuration formats d as an InfluxQL duration literal, using the
+// largest unit d is a multiple of. 0 is formatted as INF.
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return "INF"
+	}
+
+	units := []struct {
+		unit time.Duration
+		name string
+	}{
+		{7 * 24 * time.Hour, "w"},
+		{24 * time.Hour, "d"},
+		{time.Hour, "h"},
+		{time.Minute, "m"},
+		{time.Second, "s"},
+		{time.Millisecond, "ms"},
+		{time.Microsecond, "u"},
+	}
+
+	for _, u := range units {
+		if d%u.unit == 0 {
+			return fmt.Sprintf("%d%s", d/u.unit, u.name)
+		}
+	}
+
+	return fmt.Sprintf("%dns", d)
+}
+
+// CreateDatabase creates a database if it does not exist.
+func (c *helperClient) CreateDatabase(name string) error {
+	_, err := c.execute("", "CREATE DATABASE "+QuoteIdent(name))
+	return err
+}
+
+// DropDatabase drops a database and all of its data, if it exists.
+func (c *helperClient) DropDatabase(name string) error {
+	_, err := c.execute("", "DROP DATABASE "+QuoteIdent(name))
+	return err
+}
+
+// ListDatabases returns the names of the databases on the server.
+func (c *helperClient) ListDatabases() ([]string, error) {
+	rows, err := c.execute("", "SHOW DATABASES")
+	if err != nil {
+		return nil, err
+	}
+
+	var databases []struct {
+		Name string `influx:"name"`
+	}
+
+	if err := decode(rows, &databases); err != nil {
+		return nil, &DecodeError{err}
+	}
+
+	ret := make([]string, len(databases))
+	for i, d := range databases {
+		ret[i] = d.Name
+	}
+
+	return ret, nil
+}
+
+func retentionPolicyOptions(rp RetentionPolicy) string {
+	replication := rp.Replication
+	if replication == 0 {
+		replication = 1
+	}
+
+	options := fmt.Sprintf(" DURATION %v REPLICATION %v", formatDuration(rp.Duration), replication)
+	if rp.ShardGroupDuration != 0 {
+		options += " SHARD DURATION " + formatDuration(rp.ShardGroupDuration)
+	}
+	if rp.Default {
+		options += " DEFAULT"
+	}
+
+	return options
+}
+
+// CreateRetentionPolicy creates a retention policy on db if no retention
+// policy with the same name exists. An existing retention policy is not
+// changed; use AlterRetentionPolicy to update it.
+func (c *helperClient) CreateRetentionPolicy(db string, rp RetentionPolicy) error {
+	existing, err := c.ListRetentionPolicies(db)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range existing {
+		if e.Name == rp.Name {
+			return nil
+		}
+	}
+
+	_, err = c.execute(db, "CREATE RETENTION POLICY "+QuoteIdent(rp.Name)+
+		" ON "+QuoteIdent(db)+retentionPolicyOptions(rp))
+	return err
+}
+
+// AlterRetentionPolicy updates the settings of an existing retention
+// policy on db to match rp. A retention policy can not be made
+// non-default; make another one the default instead.
+func (c *helperClient) AlterRetentionPolicy(db string, rp RetentionPolicy) error {
+	_, err := c.execute(db, "ALTER RETENTION POLICY "+QuoteIdent(rp.Name)+
+		" ON "+QuoteIdent(db)+retentionPolicyOptions(rp))
+	return err
+}
+
+// ListRetentionPolicies returns the retention policies of db.
+func (c *helperClient) ListRetentionPolicies(db string) ([]RetentionPolicy, error) {
+	rows, err := c.execute(db, "SHOW RETENTION POLICIES ON "+QuoteIdent(db))
+	if err != nil {
+		return nil, err
+	}
+
+	var policies []struct {
+		Name               string `influx:"name"`
+		Duration           string `influx:"duration"`
+		ShardGroupDuration string `influx:"shardGroupDuration"`
+		ReplicaN           int    `influx:"replicaN"`
+		Default            bool   `influx:"default"`
+	}
+
+	if err := decode(rows, &policies); err != nil {
+		return nil, &DecodeError{err}
+	}
+
+	ret := make([]RetentionPolicy, len(policies))
+	for i, p := range policies {
+		ret[i] = RetentionPolicy{
+			Name:        p.Name,
+			Replication: p.ReplicaN,
+			Default:     p.Default,
+		}
+
+		ret[i].Duration, err = time.ParseDuration(p.Duration)
+		if err != nil {
+			return nil, &DecodeError{err}
+		}
+
+		ret[i].ShardGroupDuration, err = time.ParseDuration(p.ShardGroupDuration)
+		if err != nil {
+			return nil, &DecodeError{err}
+		}
+	}
+
+	return ret, nil
+}
+
+// DropMeasurement drops a measurement and all of its data from the db set
+// with UseDB, if it exists.
+func (c *helperClient) DropMeasurement(measurement string) error {
+	db, err := c.usingDB()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.execute(db, "DROP MEASUREMENT "+QuoteIdent(measurement))
+
+	var qErr *QueryError
+	if errors.As(err, &qErr) && strings.Contains(qErr.Message, "measurement not found") {
+		return nil
+	}
+
+	return err
+}
+
+// DeleteSeries deletes the series of measurement in the db set with UseDB
+// whose tags match filter.
+//
+// filter is a struct, and the tag fields of filter that are not empty must
+// match. Tag values are normalized using the trim and lower options as in
+// WritePoint. For example, this deletes all data for location "Rm 243":
+//
+//	type filter struct {
+//		Location string `influx:"location,tag"`
+//		Sensor   string `influx:"sensor,tag"`
+//	}
+//
+//	c.UseDB("myDb").DeleteSeries("env", filter{Location: "Rm 243"})
+//
+// If measurement is empty, it is determined from the type of filter in the
+// same way as WritePoint. If filter is nil, all series of the measurement
+// are deleted. If filter has no tag values, ErrEmptyFilter is returned.
+func (c *helperClient) DeleteSeries(measurement string, filter interface{}) error {
+	var conditions []string
+
+	if filter != nil {
+		v := reflect.ValueOf(filter)
+		if v.Kind() == reflect.Ptr {
+			v = reflect.Indirect(v)
+		}
+
+		if v.Kind() != reflect.Struct {
+			return errors.New("filter must be a struct")
+		}
+
+		if measurement == "" {
+			measurement = typeMeasurement(v, c.naming)
+		}
+
+		for i := 0; i < v.NumField(); i++ {
+			structField := v.Type().Field(i)
+			if structField.PkgPath != "" {
+				continue
+			}
+
+			fieldData := getInfluxFieldTagData(structField.Name, structField.Tag.Get("influx"))
+			if !fieldData.isTag || fieldData.fieldName == "-" {
+				continue
+			}
+
+			// values are normalized as in WritePoint, but empty values
+			// only mean the tag is not filtered on
+			options := *fieldData
+			options.required, options.maxLen, options.oneOf = false, 0, nil
+
+			value, err := tagValue(v.Field(i), &options)
+			if err != nil {
+				return fmt.Errorf("'%s': %v", fieldData.fieldName, err)
+			}
+			if value == "" {
+				continue
+			}
+
+			conditions = append(conditions, QuoteIdent(fieldData.fieldName)+" = "+QuoteString(value))
+		}
+
+		if len(conditions) == 0 {
+			return ErrEmptyFilter
+		}
+	}
+
+	if measurement == "" {
+		return ErrNoMeasurement
+	}
+
+	db, err := c.usingDB()
+	if err != nil {
+		return err
+	}
+
+	q := "DELETE FROM " + QuoteIdent(measurement)
+	if len(conditions) > 0 {
+		q += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	_, err = c.execute(db, q)
+	return err
+}
@@ -0,0 +1,163 @@
+package influxdbhelper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+func TestDatabaseAdmin(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+
+	for i := 0; i < 2; i++ {
+		if err := c.CreateDatabase("my db"); err != nil {
+			t.Fatal("Error creating database: ", err)
+		}
+	}
+
+	dbs, err := c.ListDatabases()
+	if err != nil {
+		t.Fatal("Error listing databases: ", err)
+	}
+	if !reflect.DeepEqual(dbs, []string{"my db"}) {
+		t.Errorf("Unexpected databases: %v", dbs)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.DropDatabase("my db"); err != nil {
+			t.Fatal("Error dropping database: ", err)
+		}
+	}
+
+	dbs, err = c.ListDatabases()
+	if err != nil || len(dbs) != 0 {
+		t.Errorf("Unexpected databases: %v, %v", dbs, err)
+	}
+}
+
+func TestRetentionPolicyAdmin(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.CreateDatabase("db")
+
+	week := RetentionPolicy{Name: "week", Duration: 7 * 24 * time.Hour, Default: true}
+	for i := 0; i < 2; i++ {
+		if err := c.CreateRetentionPolicy("db", week); err != nil {
+			t.Fatal("Error creating retention policy: ", err)
+		}
+	}
+
+	week.Duration = 14 * 24 * time.Hour
+	week.ShardGroupDuration = 12 * time.Hour
+	if err := c.AlterRetentionPolicy("db", week); err != nil {
+		t.Fatal("Error altering retention policy: ", err)
+	}
+
+	policies, err := c.ListRetentionPolicies("db")
+	if err != nil {
+		t.Fatal("Error listing retention policies: ", err)
+	}
+
+	expected := []RetentionPolicy{
+		{Name: "autogen", ShardGroupDuration: 7 * 24 * time.Hour, Replication: 1},
+		{Name: "week", Duration: 14 * 24 * time.Hour, ShardGroupDuration: 12 * time.Hour,
+			Replication: 1, Default: true},
+	}
+	if !reflect.DeepEqual(policies, expected) {
+		t.Errorf("%+v != %+v", policies, expected)
+	}
+
+	if err := c.AlterRetentionPolicy("db", RetentionPolicy{Name: "none"}); err == nil {
+		t.Error("Expected error altering missing retention policy")
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	data := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{0, "INF"},
+		{14 * 24 * time.Hour, "2w"},
+		{36 * time.Hour, "36h"},
+		{90 * time.Minute, "90m"},
+		{1500 * time.Millisecond, "1500ms"},
+		{time.Nanosecond, "1ns"},
+	}
+
+	for _, d := range data {
+		if s := formatDuration(d.d); s != d.expected {
+			t.Errorf("%v != %v", s, d.expected)
+		}
+	}
+}
+
+func TestDeleteSeries(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+
+	type Sample struct {
+		_        struct{}  `influx:"measurement=env"`
+		Time     time.Time `influx:"time"`
+		Location string    `influx:"location,tag,required"`
+		Sensor   string    `influx:"sensor,tag,lower"`
+		Value    float64   `influx:"value"`
+	}
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, l := range []string{"rm1", "rm2", "rm1"} {
+		err := c.UseDB("db").WritePoint(Sample{Time: start.Add(time.Duration(i) * time.Second),
+			Location: l, Sensor: "a", Value: float64(i)})
+		if err != nil {
+			t.Fatal("Error writing point: ", err)
+		}
+	}
+
+	if err := c.UseDB("db").DeleteSeries("", Sample{Location: "rm1", Sensor: "A"}); err != nil {
+		t.Fatal("Error deleting series: ", err)
+	}
+
+	if n := len(s.Points("db")); n != 1 {
+		t.Errorf("Expected 1 point, got %v", n)
+	}
+
+	// filters without tag values do not delete all series
+	type NoTags struct {
+		_     struct{} `influx:"measurement=env"`
+		Value float64  `influx:"value"`
+	}
+	for _, filter := range []interface{}{Sample{}, &NoTags{Value: 1}} {
+		if err := c.UseDB("db").DeleteSeries("", filter); err != ErrEmptyFilter {
+			t.Errorf("Expected ErrEmptyFilter, got %v", err)
+		}
+	}
+
+	if n := len(s.Points("db")); n != 1 {
+		t.Errorf("Expected 1 point, got %v", n)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.UseDB("db").DropMeasurement("env"); err != nil {
+			t.Fatal("Error dropping measurement: ", err)
+		}
+	}
+
+	if n := len(s.Points("db")); n != 0 {
+		t.Errorf("Expected no points, got %v", n)
+	}
+
+	c, _ = NewClient(s.URL, "", "", "ns")
+	if err := c.DeleteSeries("env", nil); err != ErrNoDatabase {
+		t.Errorf("Expected ErrNoDatabase, got %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package influxdbhelper
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+
+	influxModels "github.com/influxdata/influxdb1-client/models"
+)
+
+// splitTopLevel splits s on sep where sep is not inside parentheses or
+// quotes.
+func splitTopLevel(s string, sep rune) []string {
+	var ret []string
+	var quote rune
+	depth, start := 0, 0
+
+	for i, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			ret = append(ret, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(ret, s[start:])
+}
+
+var (
+	reSelectFields = regexp.MustCompile(`(?is)^\s*SELECT\s+(.*?)\s+FROM\s`)
+	reAlias        = regexp.MustCompile(`(?is)^(.*?)\s+AS\s+(?:"[^"]*"|\w+)\s*$`)
+)
+
+// parseSelectExpressions returns the field expressions of the first SELECT
+// statement in q, without their aliases, or nil if q is not a SELECT
+// statement.
+func parseSelectExpressions(q string) []string {
+	stmt := splitTopLevel(q, ';')[0]
+
+	m := reSelectFields.FindStringSubmatch(stmt)
+	if m == nil {
+		return nil
+	}
+
+	var ret []string
+	for _, field := range splitTopLevel(m[1], ',') {
+		expr := strings.TrimSpace(field)
+		if a := reAlias.FindStringSubmatch(expr); a != nil {
+			expr = a[1]
+		}
+		ret = append(ret, expr)
+	}
+
+	return ret
+}
+
+// normalizeExpression removes whitespace and identifier quotes from an
+// expression, and lower cases function names, so that mean( "temp" ) and
+// MEAN(temp) both become mean(temp).
+func normalizeExpression(expr string) string {
+	var b strings.Builder
+	var word strings.Builder
+
+	flush := func(next rune) {
+		w := word.String()
+		if next == '(' {
+			w = strings.ToLower(w)
+		}
+		b.WriteString(w)
+		word.Reset()
+	}
+
+	for _, c := range expr {
+		switch {
+		case unicode.IsSpace(c) || c == '"':
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_':
+			word.WriteRune(c)
+		default:
+			flush(c)
+			b.WriteRune(c)
+		}
+	}
+	flush(0)
+
+	return b.String()
+}
+
+// addExpressionColumns adds a column named after each select expression to
+// the rows, so that aggregate results, which InfluxDb names after the
+// function (mean, mean_1, ...), can be decoded using struct field tags such
+// as `influx:"mean(temperature)"`. It returns a map of the columns added to
+// the column they copy.
+func addExpressionColumns(q string, rows []influxModels.Row) map[string]string {
+	aliases := make(map[string]string)
+
+	exprs := parseSelectExpressions(q)
+	if exprs == nil {
+		return aliases
+	}
+
+	for _, e := range exprs {
+		// wildcards expand to an unknown number of columns
+		if strings.Contains(e, "*") || strings.Contains(e, "/") {
+			return aliases
+		}
+	}
+
+	for r := range rows {
+		row := &rows[r]
+
+		// columns are the time followed by one per expression
+		if len(row.Columns) != len(exprs)+1 || row.Columns[0] != "time" {
+			continue
+		}
+
+		existing := make(map[string]bool)
+		for _, c := range row.Columns {
+			existing[c] = true
+		}
+
+		columns := append([]string{}, row.Columns...)
+		var sources []int
+		for i, e := range exprs {
+			name := normalizeExpression(e)
+			if existing[name] {
+				continue
+			}
+			existing[name] = true
+			columns = append(columns, name)
+			sources = append(sources, i+1)
+			aliases[name] = row.Columns[i+1]
+		}
+
+		if len(sources) == 0 {
+			continue
+		}
+
+		values := make([][]interface{}, len(row.Values))
+		for i, v := range row.Values {
+			values[i] = append([]interface{}{}, v...)
+			for _, s := range sources {
+				if s < len(v) {
+					values[i] = append(values[i], v[s])
+				} else {
+					values[i] = append(values[i], nil)
+				}
+			}
+		}
+
+		row.Columns = columns
+		row.Values = values
+	}
+
+	return aliases
+}
+
+var reFunctionCall = regexp.MustCompile(`^(\w+)\((.*)\)$`)
+
+// SelectQuery builds a SELECT statement for the fields of the struct v from
+// measurement. If measurement is empty, it is determined from the type in
+// the same way as WritePoint. WHERE, GROUP BY and other clauses can be
+// appended to the returned statement.
+//
+// Fields with an aggregate function in their influx struct field tag, such
+// as `influx:"mean(temperature)"`, are selected using the function:
+//
+//	type hourly struct {
+//		Time        time.Time `influx:"time"`
+//		Temperature float64   `influx:"mean(temperature)"`
+//		MaxHumidity float64   `influx:"max(humidity)"`
+//	}
+//
+//	q, _ := SelectQuery("env", hourly{})
+//	// SELECT mean("temperature"), max("humidity") FROM "env"
+//	q += " WHERE time > now() - 1d GROUP BY time(1h)"
+//
+// If any field uses an aggregate function, tag fields are not selected, as
+// InfluxDb does not allow mixing aggregate and non-aggregate fields. Group
+// by the tags instead.
+func SelectQuery(measurement string, v interface{}) (string, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return "", errors.New("data must be a struct")
+	}
+
+	if measurement == "" {
+		measurement = typeMeasurement(reflect.New(t).Elem(), nil)
+	}
+
+	var fields, tags []string
+	aggregate := false
+
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if structField.PkgPath != "" || structField.Name == "InfluxMeasurement" {
+			continue
+		}
+
+		fieldData := getInfluxFieldTagData(structField.Name, structField.Tag.Get("influx"))
+		if fieldData.fieldName == "-" || fieldData.fieldName == "time" ||
+			fieldData.isTagMap || fieldData.isFieldMap {
+			continue
+		}
+
+		if m := reFunctionCall.FindStringSubmatch(fieldData.fieldName); m != nil {
+			aggregate = true
+			args := splitTopLevel(m[2], ',')
+			args[0] = QuoteIdent(strings.TrimSpace(args[0]))
+			fields = append(fields, m[1]+"("+strings.Join(args, ",")+")")
+			continue
+		}
+
+		if fieldData.isTag && !fieldData.isField {
+			tags = append(tags, QuoteIdent(fieldData.fieldName))
+			continue
+		}
+
+		fields = append(fields, QuoteIdent(fieldData.fieldName))
+	}
+
+	if !aggregate {
+		fields = append(fields, tags...)
+	}
+
+	if len(fields) == 0 {
+		return "", errors.New("no fields to select")
+	}
+
+	return "SELECT " + strings.Join(fields, ", ") + " FROM " + QuoteIdent(measurement), nil
+}
@@ -0,0 +1,154 @@
+package influxdbhelper
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	influxModels "github.com/influxdata/influxdb1-client/models"
+)
+
+func TestParseSelectExpressions(t *testing.T) {
+	exprs := parseSelectExpressions(
+		`SELECT mean("temperature"), percentile(humidity, 95) AS p95, count FROM test GROUP BY time(1h)`)
+
+	expected := []string{`mean("temperature")`, `percentile(humidity, 95)`, `count`}
+
+	if !reflect.DeepEqual(expected, exprs) {
+		t.Errorf("%v != %v", exprs, expected)
+	}
+
+	if parseSelectExpressions("SHOW DATABASES") != nil {
+		t.Error("Expected no expressions")
+	}
+}
+
+func TestNormalizeExpression(t *testing.T) {
+	data := map[string]string{
+		`MEAN( "temperature" )`:      "mean(temperature)",
+		`percentile(Humidity, 95)`:   "percentile(Humidity,95)",
+		`max(temp) - min(temp)`:      "max(temp)-min(temp)",
+		`"Temperature"`:              "Temperature",
+		`derivative(mean(value),1s)`: "derivative(mean(value),1s)",
+	}
+
+	for in, out := range data {
+		if n := normalizeExpression(in); n != out {
+			t.Errorf("%v: %v != %v", in, n, out)
+		}
+	}
+}
+
+func TestDecodeAggregate(t *testing.T) {
+	q := `SELECT mean(temperature), mean(humidity), max(humidity) AS peak FROM test GROUP BY time(1h)`
+
+	rows := []influxModels.Row{{
+		Name:    "test",
+		Columns: []string{"time", "mean", "mean_1", "peak"},
+		Values: [][]interface{}{
+			{"2018-06-14T21:00:00Z", 70.5, 40.0, 45.0},
+			{"2018-06-14T22:00:00Z", nil, nil, nil},
+		},
+	}}
+
+	type DecodeType struct {
+		Time        time.Time `influx:"time"`
+		Temperature float64   `influx:"mean(temperature)"`
+		Humidity    float64   `influx:"mean(humidity)"`
+		Peak        float64   `influx:"peak"`
+	}
+
+	addExpressionColumns(q, rows)
+
+	decoded := []DecodeType{}
+	if err := decode(rows, &decoded); err != nil {
+		t.Fatal("Error decoding: ", err)
+	}
+
+	expected := []DecodeType{
+		{time.Date(2018, 6, 14, 21, 0, 0, 0, time.UTC), 70.5, 40.0, 45.0},
+		{time.Date(2018, 6, 14, 22, 0, 0, 0, time.UTC), 0, 0, 0},
+	}
+
+	if !reflect.DeepEqual(expected, decoded) {
+		t.Errorf("%+v != %+v", decoded, expected)
+	}
+}
+
+func TestSelectQuery(t *testing.T) {
+	type Hourly struct {
+		Time        time.Time `influx:"time"`
+		Location    string    `influx:"location,tag"`
+		Temperature float64   `influx:"mean(temperature)"`
+		P95         float64   `influx:"percentile(humidity,95)"`
+	}
+
+	q, err := SelectQuery("", Hourly{})
+	if err != nil {
+		t.Fatal("Error building query: ", err)
+	}
+
+	expected := `SELECT mean("temperature"), percentile("humidity",95) FROM "Hourly"`
+	if q != expected {
+		t.Errorf("%v != %v", q, expected)
+	}
+
+	type Raw struct {
+		InfluxMeasurement Measurement
+		Time              time.Time `influx:"time"`
+		Location          string    `influx:"location,tag"`
+		Temperature       float64   `influx:"temperature"`
+		ID                string    `influx:"-"`
+	}
+
+	q, _ = SelectQuery("env", &Raw{})
+	expected = `SELECT "temperature", "location" FROM "env"`
+	if q != expected {
+		t.Errorf("%v != %v", q, expected)
+	}
+
+	if _, err := SelectQuery("env", 1); err == nil {
+		t.Error("Expected error")
+	}
+}
+
+func TestDecodeQueryAggregateArgs(t *testing.T) {
+	// the influxdbtest server does not compute aggregates
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"results":[{"statement_id":0,"series":[{"name":"env",`+
+			`"columns":["time","mean","percentile","percentile_1"],`+
+			`"values":[["2019-01-01T00:00:00Z",70.5,45,48]]}]}]}`)
+	}))
+	defer s.Close()
+
+	type Hourly struct {
+		Time        time.Time `influx:"time"`
+		Temperature float64   `influx:"mean(temperature)"`
+		P95         float64   `influx:"percentile(humidity,95)"`
+		P99         float64   `influx:"percentile(humidity,99),float"`
+	}
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+
+	var decoded []Hourly
+	var meta DecodeResult
+	err := c.UseDB("db").DecodeQuery(
+		`SELECT mean(temperature), percentile("humidity", 95), percentile(humidity,99) FROM env GROUP BY time(1h)`,
+		&decoded, &meta)
+	if err != nil {
+		t.Fatal("Error decoding query: ", err)
+	}
+
+	expected := []Hourly{{time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), 70.5, 45, 48}}
+	if !reflect.DeepEqual(expected, decoded) {
+		t.Errorf("%+v != %+v", decoded, expected)
+	}
+
+	if len(meta.Unused) != 0 || len(meta.Unset) != 0 {
+		t.Errorf("Unexpected metadata: %+v", meta)
+	}
+}
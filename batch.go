@@ -0,0 +1,221 @@
+package influxdbhelper
+
+import (
+	"sync"
+	"time"
+
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+// BatchConfig is used to configure a BatchWriter.
+type BatchConfig struct {
+	// DB, RetentionPolicy and Precision are used for all writes. Precision
+	// defaults to "ns".
+	DB              string
+	RetentionPolicy string
+	Precision       string
+
+	// Measurement is used for WritePointTagsFields, and for WritePoint if
+	// set. Otherwise the measurement is determined from the data.
+	Measurement string
+
+	// Naming converts type names to measurement names in WritePoint.
+	Naming NamingStrategy
+
+	// TimeField is the name of the time field used by WritePoint, defaults
+	// to "time".
+	TimeField string
+
+	// MaxPoints is the number of buffered points at which the buffer is
+	// flushed, defaults to 5000.
+	MaxPoints int
+
+	// FlushInterval, if set, starts a goroutine that flushes the buffer at
+	// this interval.
+	FlushInterval time.Duration
+
+	// OnError, if set, is called with the error of each flush started by
+	// the FlushInterval goroutine that fails.
+	OnError func(err error)
+}
+
+// BatchWriter buffers points, and writes them to InfluxDb through a Client
+// in batches, when MaxPoints are buffered, at the FlushInterval, or on
+// Flush. This reduces the number of writes of programs that write points
+// one at a time.
+//
+// Points buffered in the same flush are written with one write, so with a
+// Deduplicator set on the client with UseDeduplicator, duplicate points
+// written separately, for example by overlapping pollers, are coalesced.
+type BatchWriter struct {
+	client Client
+	config BatchConfig
+
+	mu     sync.Mutex
+	points []*influxClient.Point
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewBatchWriter returns a writer that uses c to write to InfluxDb.
+func NewBatchWriter(c Client, config BatchConfig) (*BatchWriter, error) {
+	if config.DB == "" {
+		return nil, ErrNoDatabase
+	}
+	if config.Precision == "" {
+		config.Precision = "ns"
+	}
+	if config.TimeField == "" {
+		config.TimeField = "time"
+	}
+	if config.MaxPoints <= 0 {
+		config.MaxPoints = 5000
+	}
+
+	w := &BatchWriter{
+		client: c,
+		config: config,
+	}
+
+	if config.FlushInterval > 0 {
+		w.stop = make(chan struct{})
+		w.done = make(chan struct{})
+		go w.run()
+	}
+
+	return w, nil
+}
+
+// WritePoint encodes data like Client.WritePoint and buffers it.
+func (w *BatchWriter) WritePoint(data interface{}) error {
+	t, tags, fields, measurement, err := encodeWithNaming(data, &usingValue{w.config.TimeField, true}, w.config.Naming)
+	if err != nil {
+		return err
+	}
+
+	if w.config.Measurement != "" {
+		measurement = w.config.Measurement
+	}
+
+	return w.writePoint(measurement, tags, fields, t)
+}
+
+// WritePointTagsFields buffers a point of the configured Measurement.
+func (w *BatchWriter) WritePointTagsFields(tags map[string]string, fields map[string]interface{}, t time.Time) error {
+	if w.config.Measurement == "" {
+		return ErrNoMeasurement
+	}
+
+	return w.writePoint(w.config.Measurement, tags, fields, t)
+}
+
+func (w *BatchWriter) writePoint(measurement string, tags map[string]string, fields map[string]interface{}, t time.Time) error {
+	pt, err := influxClient.NewPoint(measurement, tags, fields, t)
+	if err != nil {
+		return err
+	}
+
+	return w.add(pt)
+}
+
+// Write buffers the points of bp. The database, retention policy and
+// precision of bp are replaced by the values in the BatchConfig.
+func (w *BatchWriter) Write(bp influxClient.BatchPoints) error {
+	return w.add(bp.Points()...)
+}
+
+// add buffers points, and flushes the buffer if it is full. Points without
+// a time are given the current time, as they would otherwise get the time
+// of the flush.
+func (w *BatchWriter) add(points ...*influxClient.Point) error {
+	now := time.Now()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, p := range points {
+		if p.Time().IsZero() {
+			fields, err := p.Fields()
+			if err != nil {
+				return err
+			}
+			p, err = influxClient.NewPoint(p.Name(), p.Tags(), fields, now)
+			if err != nil {
+				return err
+			}
+		}
+		w.points = append(w.points, p)
+	}
+
+	if len(w.points) < w.config.MaxPoints {
+		return nil
+	}
+
+	return w.flush()
+}
+
+// Flush writes the buffered points. The points are removed from the buffer
+// even if the write fails.
+func (w *BatchWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flush()
+}
+
+func (w *BatchWriter) flush() error {
+	if len(w.points) == 0 {
+		return nil
+	}
+
+	bp, err := influxClient.NewBatchPoints(influxClient.BatchPointsConfig{
+		Database:        w.config.DB,
+		RetentionPolicy: w.config.RetentionPolicy,
+		Precision:       w.config.Precision,
+	})
+	if err != nil {
+		return err
+	}
+
+	bp.AddPoints(w.points)
+	w.points = nil
+
+	return w.client.Write(bp)
+}
+
+// Buffered returns the number of points buffered.
+func (w *BatchWriter) Buffered() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.points)
+}
+
+func (w *BatchWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			if err := w.Flush(); err != nil && w.config.OnError != nil {
+				w.config.OnError(err)
+			}
+		}
+	}
+}
+
+// Close stops the flush goroutine, and flushes the buffered points. The
+// client is not closed.
+func (w *BatchWriter) Close() error {
+	if w.stop != nil {
+		close(w.stop)
+		<-w.done
+		w.stop = nil
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,134 @@
+package influxdbhelper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+func TestBatchWriterDedup(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	type envSample struct {
+		InfluxMeasurement Measurement
+		Time              time.Time `influx:"time"`
+		Location          string    `influx:"location,tag"`
+		Temperature       float64   `influx:"temperature"`
+	}
+
+	d := NewDeduplicator(nil)
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseDeduplicator(d)
+
+	w, err := NewBatchWriter(c, BatchConfig{DB: "db"})
+	if err != nil {
+		t.Fatal("Error creating writer: ", err)
+	}
+
+	// two pollers write the same points one at a time
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for poller := 0; poller < 2; poller++ {
+		for i := 0; i < 3; i++ {
+			err := w.WritePoint(envSample{"env", start.Add(time.Duration(i) * time.Second), "rm1", float64(70 + poller)})
+			if err != nil {
+				t.Fatal("Error writing point: ", err)
+			}
+		}
+	}
+
+	if n := len(s.Requests()); n != 0 {
+		t.Errorf("%v != 0", n)
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatal("Error flushing: ", err)
+	}
+
+	requests := s.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("%v != 1", len(requests))
+	}
+
+	exp := "env,location=rm1 temperature=71 1546300800000000000\n" +
+		"env,location=rm1 temperature=71 1546300801000000000\n" +
+		"env,location=rm1 temperature=71 1546300802000000000\n"
+	if body := string(requests[0].Body); body != exp {
+		t.Errorf("%v != %v", body, exp)
+	}
+
+	if stats := d.Stats(); stats.Points != 6 || stats.Duplicates != 3 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// nothing to flush
+	if err := w.Flush(); err != nil || len(s.Requests()) != 1 {
+		t.Errorf("Unexpected flush: %v", err)
+	}
+}
+
+func TestBatchWriterMaxPoints(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+	w, _ := NewBatchWriter(c, BatchConfig{DB: "db", Measurement: "env", MaxPoints: 2})
+
+	for i := 0; i < 3; i++ {
+		if err := w.WritePointTagsFields(nil, map[string]interface{}{"value": float64(i)}, time.Time{}); err != nil {
+			t.Fatal("Error writing point: ", err)
+		}
+	}
+
+	if n := len(s.Points("db")); n != 2 {
+		t.Errorf("%v != 2", n)
+	}
+	if n := w.Buffered(); n != 1 {
+		t.Errorf("%v != 1", n)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal("Error closing: ", err)
+	}
+
+	points := s.Points("db")
+	if len(points) != 3 || points[2].Time().IsZero() {
+		t.Errorf("Unexpected points: %v", points)
+	}
+
+	if _, err := NewBatchWriter(c, BatchConfig{}); err != ErrNoDatabase {
+		t.Errorf("Expected ErrNoDatabase, got %v", err)
+	}
+}
+
+func TestBatchWriterInterval(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+
+	errs := make(chan error, 1)
+	c, _ := NewClient(s.URL, "", "", "ns")
+	w, _ := NewBatchWriter(c, BatchConfig{DB: "db", Measurement: "env",
+		FlushInterval: 10 * time.Millisecond, OnError: func(err error) { errs <- err }})
+	defer w.Close()
+
+	// the database does not exist
+	if err := w.WritePointTagsFields(nil, map[string]interface{}{"value": 1.0}, time.Now()); err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("Expected error")
+		}
+	case <-time.After(time.Second):
+		t.Error("Buffer not flushed")
+	}
+
+	if n := w.Buffered(); n != 0 {
+		t.Errorf("%v != 0", n)
+	}
+}
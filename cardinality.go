@@ -0,0 +1,418 @@
+package influxdbhelper
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sync"
+
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+// CardinalityAction is the action a CardinalityGuard takes for points that
+// would create a series in a measurement that has reached its limit.
+type CardinalityAction int
+
+const (
+	// CardinalityReject fails the whole write with a *CardinalityError.
+	CardinalityReject CardinalityAction = iota
+	// CardinalityDrop writes the remaining points and drops those that
+	// exceed the limit.
+	CardinalityDrop
+	// CardinalityWarn writes all points, and only calls OnExceeded and
+	// counts the points in CardinalityStats. The series over the limit are
+	// counted with a HyperLogLog sketch instead of being kept.
+	CardinalityWarn
+)
+
+// CardinalityConfig configures a CardinalityGuard.
+type CardinalityConfig struct {
+	// Limit is the maximum number of series (distinct tag value
+	// combinations) per measurement. Zero means no limit.
+	Limit uint64
+	// Limits overrides Limit for individual measurements.
+	Limits map[string]uint64
+	// Action is taken for points that exceed the limit. The default is
+	// CardinalityReject.
+	Action CardinalityAction
+	// Precision is the number of bits of the series hash used to select a
+	// HyperLogLog register, between 4 and 16, for the measurements without
+	// a limit, and the series over the limit with CardinalityWarn. Each
+	// sketch uses 2^Precision bytes, and the estimate has a standard error
+	// of about 1.04/sqrt(2^Precision). The default is 14 (16KB, 0.8%).
+	Precision uint8
+	// OnExceeded, if set, is called for each point that exceeds the limit
+	// of its measurement, with the current number of series. It is called
+	// with the guard locked, and must not use it.
+	OnExceeded func(measurement string, series uint64)
+}
+
+// CardinalityStats reports the state of a CardinalityGuard for monitoring.
+type CardinalityStats struct {
+	// Series is the number of series per measurement, estimated for
+	// measurements without a limit, and for the series over the limit with
+	// CardinalityWarn.
+	Series map[string]uint64
+	// Exceeded is the number of points per measurement that exceeded the
+	// limit, regardless of the action taken.
+	Exceeded map[string]uint64
+	// Rejected is the number of writes rejected.
+	Rejected uint64
+	// Dropped is the number of points dropped.
+	Dropped uint64
+	// Warned is the number of points written that exceeded the limit.
+	Warned uint64
+}
+
+// CardinalityError is returned by writes rejected by a CardinalityGuard.
+type CardinalityError struct {
+	Measurement string
+	Series      uint64
+	Limit       uint64
+}
+
+func (e *CardinalityError) Error() string {
+	return fmt.Sprintf("series cardinality limit exceeded: measurement %q has %v series, limit %v",
+		e.Measurement, e.Series, e.Limit)
+}
+
+// CardinalityGuard tracks the number of distinct series written to each
+// measurement, and limits the creation of new series. This protects the
+// server from bugs such as writing a unique ID as a tag value.
+//
+// For measurements with a limit, the hashes of the series written are kept,
+// so the memory used grows with the limit, and once the limit is reached,
+// any point in a series not already written exceeds it. With
+// CardinalityWarn, the series written over the limit are not kept, but
+// estimated with a HyperLogLog sketch, so the memory used is still bounded.
+// Series are counted when the write succeeds, and the series of writes in
+// progress count towards the limit. For measurements without a limit, the
+// number of series is estimated with a HyperLogLog sketch, so the memory
+// used is fixed. Only series written through the guard are counted.
+//
+// A CardinalityGuard is safe for concurrent use, and can be shared by
+// several clients.
+type CardinalityGuard struct {
+	config CardinalityConfig
+
+	lock   sync.Mutex
+	series map[string]*seriesCount
+	stats  CardinalityStats
+}
+
+// seriesCount counts the series of a measurement. seen, pending and
+// overflow are used for measurements with a limit, and sketch for the
+// others.
+type seriesCount struct {
+	sketch *hyperLogLog
+	// overflow estimates the series written over the limit with
+	// CardinalityWarn.
+	overflow *hyperLogLog
+	// seen are the hashes of the series written.
+	seen map[uint64]struct{}
+	// pending are the hashes of the new series of writes in progress, and
+	// the number of points of each.
+	pending map[uint64]int
+}
+
+func (s *seriesCount) count() uint64 {
+	if s.sketch != nil {
+		return s.sketch.estimate()
+	}
+	n := uint64(len(s.seen) + len(s.pending))
+	if s.overflow != nil {
+		n += s.overflow.estimate()
+	}
+	return n
+}
+
+// NewCardinalityGuard returns a new CardinalityGuard. It is enabled for a
+// Client with UseCardinalityGuard.
+func NewCardinalityGuard(config CardinalityConfig) *CardinalityGuard {
+	if config.Precision == 0 {
+		config.Precision = 14
+	}
+	if config.Precision < 4 {
+		config.Precision = 4
+	}
+	if config.Precision > 16 {
+		config.Precision = 16
+	}
+
+	return &CardinalityGuard{
+		config: config,
+		series: make(map[string]*seriesCount),
+		stats: CardinalityStats{
+			Series:   make(map[string]uint64),
+			Exceeded: make(map[string]uint64),
+		},
+	}
+}
+
+// Series returns the number of series written to measurement, which is
+// estimated for measurements without a limit.
+func (g *CardinalityGuard) Series(measurement string) uint64 {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if s, ok := g.series[measurement]; ok {
+		return s.count()
+	}
+
+	return 0
+}
+
+// Stats returns the current state of the guard.
+func (g *CardinalityGuard) Stats() CardinalityStats {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	ret := g.stats
+	ret.Series = make(map[string]uint64, len(g.series))
+	for m, s := range g.series {
+		ret.Series[m] = s.count()
+	}
+	ret.Exceeded = make(map[string]uint64, len(g.stats.Exceeded))
+	for m, n := range g.stats.Exceeded {
+		ret.Exceeded[m] = n
+	}
+
+	return ret
+}
+
+// Reset forgets all series, for example after old series have been removed
+// by a retention policy.
+func (g *CardinalityGuard) Reset() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.series = make(map[string]*seriesCount)
+}
+
+func (g *CardinalityGuard) limit(measurement string) uint64 {
+	if l, ok := g.config.Limits[measurement]; ok {
+		return l
+	}
+	return g.config.Limit
+}
+
+func (g *CardinalityGuard) seriesCount(measurement string) *seriesCount {
+	s, ok := g.series[measurement]
+	if !ok {
+		s = &seriesCount{}
+		if g.limit(measurement) == 0 {
+			s.sketch = newHyperLogLog(g.config.Precision)
+		} else {
+			s.seen = make(map[uint64]struct{})
+			s.pending = make(map[uint64]int)
+		}
+		g.series[measurement] = s
+	}
+	return s
+}
+
+// seriesWrite is a series of a point accepted by check.
+type seriesWrite struct {
+	count *seriesCount
+	hash  uint64
+	// new is true if the series is pending.
+	new bool
+	// overflow is true if the series is over the limit.
+	overflow bool
+}
+
+// check applies the guard to the points of bp. It returns the points to
+// write, which is bp if all points are accepted, and a function that must
+// be called with the result of the write to count the new series.
+func (g *CardinalityGuard) check(bp influxClient.BatchPoints) (influxClient.BatchPoints, func(written bool), error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	points := bp.Points()
+	accepted := make([]*influxClient.Point, 0, len(points))
+	writes := make([]seriesWrite, 0, len(points))
+
+	for _, p := range points {
+		measurement := p.Name()
+		s := g.seriesCount(measurement)
+		hash := seriesHash(measurement, p.Tags())
+
+		if s.sketch != nil {
+			writes = append(writes, seriesWrite{count: s, hash: hash})
+			accepted = append(accepted, p)
+			continue
+		}
+
+		if _, ok := s.seen[hash]; ok {
+			accepted = append(accepted, p)
+			continue
+		}
+
+		limit := g.limit(measurement)
+		if _, ok := s.pending[hash]; ok || s.count() < limit {
+			s.pending[hash]++
+			writes = append(writes, seriesWrite{count: s, hash: hash, new: true})
+			accepted = append(accepted, p)
+			continue
+		}
+
+		series := s.count()
+		g.stats.Exceeded[measurement]++
+		if g.config.OnExceeded != nil {
+			g.config.OnExceeded(measurement, series)
+		}
+
+		switch g.config.Action {
+		case CardinalityReject:
+			g.stats.Rejected++
+			// a rejected write must not count its series
+			g.done(writes, false)
+			return nil, nil, &CardinalityError{measurement, series, limit}
+		case CardinalityDrop:
+			g.stats.Dropped++
+		default:
+			g.stats.Warned++
+			if s.overflow == nil {
+				s.overflow = newHyperLogLog(g.config.Precision)
+			}
+			writes = append(writes, seriesWrite{count: s, hash: hash, overflow: true})
+			accepted = append(accepted, p)
+		}
+	}
+
+	done := func(written bool) {
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		g.done(writes, written)
+	}
+
+	if len(accepted) == len(points) {
+		return bp, done, nil
+	}
+
+	ret, err := influxClient.NewBatchPoints(influxClient.BatchPointsConfig{
+		Precision:        bp.Precision(),
+		Database:         bp.Database(),
+		RetentionPolicy:  bp.RetentionPolicy(),
+		WriteConsistency: bp.WriteConsistency(),
+	})
+	if err != nil {
+		g.done(writes, false)
+		return nil, nil, err
+	}
+	ret.AddPoints(accepted)
+
+	return ret, done, nil
+}
+
+// done counts the series of writes if they were written, and releases the
+// pending series.
+func (g *CardinalityGuard) done(writes []seriesWrite, written bool) {
+	for _, w := range writes {
+		s := w.count
+		if s.sketch != nil || w.overflow {
+			if written && w.overflow {
+				s.overflow.add(w.hash)
+			} else if written {
+				s.sketch.add(w.hash)
+			}
+			continue
+		}
+
+		if written {
+			s.seen[w.hash] = struct{}{}
+		}
+
+		if w.new {
+			if s.pending[w.hash]--; s.pending[w.hash] <= 0 {
+				delete(s.pending, w.hash)
+			}
+		}
+	}
+}
+
+func seriesHash(measurement string, tags map[string]string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(measurement))
+	h.Write([]byte{','})
+	h.Write([]byte(TagSetKey(tags)))
+
+	// FNV does not distribute the high bits well enough for HyperLogLog,
+	// so mix them with the murmur3 finalizer
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return x
+}
+
+// hyperLogLog is a HyperLogLog sketch that estimates the number of
+// distinct hashes added to it. The sum of the register values and number of
+// zero registers are kept up to date, so the estimate does not need to scan
+// the registers.
+type hyperLogLog struct {
+	precision uint8
+	registers []uint8
+	sum       float64
+	zeros     int
+}
+
+func newHyperLogLog(precision uint8) *hyperLogLog {
+	m := 1 << precision
+	return &hyperLogLog{
+		precision: precision,
+		registers: make([]uint8, m),
+		sum:       float64(m),
+		zeros:     m,
+	}
+}
+
+func (h *hyperLogLog) register(hash uint64) (int, uint8) {
+	index := hash >> (64 - h.precision)
+	// the guard bit limits the rank to the bits not used for the index
+	rank := uint8(bits.LeadingZeros64(hash<<h.precision|1<<(h.precision-1))) + 1
+	return int(index), rank
+}
+
+func (h *hyperLogLog) add(hash uint64) {
+	i, rank := h.register(hash)
+	r := h.registers[i]
+	if rank <= r {
+		return
+	}
+
+	if r == 0 {
+		h.zeros--
+	}
+	h.sum += math.Ldexp(1, -int(rank)) - math.Ldexp(1, -int(r))
+	h.registers[i] = rank
+}
+
+func (h *hyperLogLog) estimate() uint64 {
+	m := float64(len(h.registers))
+
+	var alpha float64
+	switch len(h.registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+
+	e := alpha * m * m / h.sum
+
+	// use linear counting for small cardinalities
+	if e <= 2.5*m && h.zeros > 0 {
+		e = m * math.Log(m/float64(h.zeros))
+	}
+
+	return uint64(e + 0.5)
+}
@@ -0,0 +1,259 @@
+package influxdbhelper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+func TestHyperLogLogEstimate(t *testing.T) {
+	for _, n := range []int{100, 10000, 200000} {
+		h := newHyperLogLog(14)
+		for i := 0; i < n; i++ {
+			h.add(seriesHash("test", map[string]string{"id": fmt.Sprint(i)}))
+		}
+
+		e := float64(h.estimate())
+		if math.Abs(e-float64(n))/float64(n) > 0.03 {
+			t.Errorf("estimate %v not within 3%% of %v", e, n)
+		}
+	}
+}
+
+type cardinalitySample struct {
+	Time  time.Time `influx:"time"`
+	ID    string    `influx:"id,tag"`
+	Value float64   `influx:"value"`
+}
+
+func newCardinalityBatch(t *testing.T, ids ...int) influxClient.BatchPoints {
+	bp, _ := influxClient.NewBatchPoints(influxClient.BatchPointsConfig{Database: "db"})
+	for i, id := range ids {
+		p, err := influxClient.NewPoint("test", map[string]string{"id": fmt.Sprint(id)},
+			map[string]interface{}{"value": float64(i)}, time.Unix(int64(i), 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bp.AddPoint(p)
+	}
+	return bp
+}
+
+func TestCardinalityGuardReject(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	guard := NewCardinalityGuard(CardinalityConfig{Limit: 10})
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseDB("db").UseMeasurement("test").UseCardinalityGuard(guard)
+
+	for i := 0; i < 10; i++ {
+		if err := c.WritePoint(cardinalitySample{time.Unix(int64(i), 0), fmt.Sprint(i), 1}); err != nil {
+			t.Fatal("Error writing point: ", err)
+		}
+	}
+
+	err := c.WritePoint(cardinalitySample{time.Unix(10, 0), "new", 1})
+	var cErr *CardinalityError
+	if !errors.As(err, &cErr) {
+		t.Fatalf("Expected *CardinalityError, got %v", err)
+	}
+	if cErr.Measurement != "test" || cErr.Limit != 10 {
+		t.Errorf("Unexpected error: %+v", cErr)
+	}
+
+	// existing series are still accepted
+	if err := c.WritePoint(cardinalitySample{time.Unix(11, 0), "3", 2}); err != nil {
+		t.Error("Error writing existing series: ", err)
+	}
+
+	// a rejected batch writes nothing, and does not count its series
+	err = c.Write(newCardinalityBatch(t, 1, 100))
+	if !errors.As(err, &cErr) {
+		t.Errorf("Expected *CardinalityError, got %v", err)
+	}
+
+	if n := len(s.Points("db")); n != 11 {
+		t.Errorf("Expected 11 points, got %v", n)
+	}
+
+	stats := guard.Stats()
+	if stats.Rejected != 2 || stats.Series["test"] != 10 || stats.Exceeded["test"] != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestCardinalityGuardDrop(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	guard := NewCardinalityGuard(CardinalityConfig{
+		Limit:  100,
+		Limits: map[string]uint64{"test": 5},
+		Action: CardinalityDrop,
+	})
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseCardinalityGuard(guard)
+
+	if err := c.Write(newCardinalityBatch(t, 0, 1, 2, 3, 4, 5, 6, 0)); err != nil {
+		t.Fatal("Error writing: ", err)
+	}
+
+	if n := len(s.Points("db")); n != 6 {
+		t.Errorf("Expected 6 points, got %v", n)
+	}
+
+	// all points dropped
+	if err := c.Write(newCardinalityBatch(t, 7, 8)); err != nil {
+		t.Fatal("Error writing: ", err)
+	}
+
+	stats := guard.Stats()
+	if stats.Dropped != 4 || guard.Series("test") != 5 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestCardinalityGuardWarn(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	warned := map[string]int{}
+	guard := NewCardinalityGuard(CardinalityConfig{
+		Limit:  2,
+		Action: CardinalityWarn,
+		OnExceeded: func(measurement string, series uint64) {
+			warned[measurement]++
+		},
+	})
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseCardinalityGuard(guard)
+
+	if err := c.Write(newCardinalityBatch(t, 0, 1, 2, 3)); err != nil {
+		t.Fatal("Error writing: ", err)
+	}
+
+	if n := len(s.Points("db")); n != 4 {
+		t.Errorf("Expected 4 points, got %v", n)
+	}
+
+	if warned["test"] != 2 || guard.Stats().Warned != 2 {
+		t.Errorf("Unexpected warnings: %v, %+v", warned, guard.Stats())
+	}
+}
+
+func TestCardinalityGuardManySeries(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	const limit, series = 1000, 50000
+
+	guard := NewCardinalityGuard(CardinalityConfig{Limit: limit, Action: CardinalityDrop})
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseCardinalityGuard(guard)
+
+	ids := make([]int, 1000)
+	for n := 0; n < series; n += len(ids) {
+		for i := range ids {
+			ids[i] = n + i
+		}
+		if err := c.Write(newCardinalityBatch(t, ids...)); err != nil {
+			t.Fatal("Error writing: ", err)
+		}
+	}
+
+	if n := len(s.Points("db")); n != limit {
+		t.Errorf("Expected %v points, got %v", limit, n)
+	}
+
+	stats := guard.Stats()
+	if stats.Dropped != series-limit || stats.Series["test"] != limit {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// the series written are still accepted
+	if err := c.Write(newCardinalityBatch(t, 0, limit-1, limit)); err != nil {
+		t.Fatal("Error writing: ", err)
+	}
+	if n := len(s.Points("db")); n != limit+2 {
+		t.Errorf("Expected %v points, got %v", limit+2, n)
+	}
+}
+
+func TestCardinalityGuardWarnManySeries(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	const limit, series = 100, 20000
+
+	guard := NewCardinalityGuard(CardinalityConfig{Limit: limit, Action: CardinalityWarn})
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseCardinalityGuard(guard)
+
+	ids := make([]int, 1000)
+	for n := 0; n < series; n += len(ids) {
+		for i := range ids {
+			ids[i] = n + i
+		}
+		if err := c.Write(newCardinalityBatch(t, ids...)); err != nil {
+			t.Fatal("Error writing: ", err)
+		}
+	}
+
+	if n := len(s.Points("db")); n != series {
+		t.Errorf("Expected %v points, got %v", series, n)
+	}
+
+	// only the series under the limit are kept
+	if n := len(guard.series["test"].seen); n != limit {
+		t.Errorf("%v != %v", n, limit)
+	}
+
+	stats := guard.Stats()
+	if stats.Warned != series-limit {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	if n := stats.Series["test"]; math.Abs(float64(n)-series)/series > 0.05 {
+		t.Errorf("Series estimate %v too far from %v", n, series)
+	}
+}
+
+func TestCardinalityGuardWriteError(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	guard := NewCardinalityGuard(CardinalityConfig{Limit: 2})
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseCardinalityGuard(guard)
+
+	// the series of a failed write are not counted
+	s.FailNext(http.StatusInternalServerError, "timeout")
+	if err := c.Write(newCardinalityBatch(t, 0, 1)); err == nil {
+		t.Fatal("Expected write error")
+	}
+
+	if n := guard.Series("test"); n != 0 {
+		t.Errorf("%v != 0", n)
+	}
+
+	if err := c.Write(newCardinalityBatch(t, 2, 3)); err != nil {
+		t.Fatal("Error writing: ", err)
+	}
+
+	if n := guard.Series("test"); n != 2 {
+		t.Errorf("%v != 2", n)
+	}
+}
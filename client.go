@@ -1,7 +1,9 @@
 package influxdbhelper
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"regexp"
 	"strings"
 	"time"
@@ -12,11 +14,13 @@ import (
 var reRemoveExtraSpace = regexp.MustCompile(`\s\s+`)
 
 // CleanQuery can be used to strip a query string of
-// newline characters. Typically only used for debugging.
+// newline characters. Typically only used for debugging. The password
+// literals of CREATE USER and SET PASSWORD statements are replaced with
+// [REDACTED].
 func CleanQuery(query string) string {
 	ret := strings.Replace(query, "\n", "", -1)
 	ret = reRemoveExtraSpace.ReplaceAllString(ret, " ")
-	return ret
+	return redactPasswords(ret)
 }
 
 // A Client represents an influxdbhelper influxClient connection to
@@ -28,32 +32,163 @@ type Client interface {
 	// This field must be set before WritePoint... calls.
 	UseDB(db string) Client
 
+	// UseRetentionPolicy sets the retention policy to use for DecodeQuery,
+	// WritePoint, and WritePointTagsFields. If it is not set, the default
+	// retention policy of the database is used.
+	UseRetentionPolicy(rp string) Client
+
 	// UseMeasurement sets the measurement to use for WritePoint, and WritePointTagsFields.
-	// If this is not set, a struct field with named InfluxMeasurement is required
-	// in the write data. The data passed in this call has priority over data fields in
+	// If this is not set, WritePoint uses a struct field named InfluxMeasurement in the
+	// write data, the InfluxMeasurementName method of the data type, a
+	// `influx:"measurement=name"` tag on a blank (_) struct field, or the type name,
+	// in that order. The data passed in this call has priority over data fields in
 	// writes.
 	UseMeasurement(measurement string) Client
 
+	// UseNamingStrategy sets how WritePoint converts the type name of the data to a
+	// measurement name, if the measurement is not otherwise defined.
+	UseNamingStrategy(naming NamingStrategy) Client
+
 	// UseTimeField sets the time field to use for WritePoint, and WritePointTagsFields. This
 	// call is optional, and a data struct field with a `influx:"time"` tag can also be used.
 	UseTimeField(fieldName string) Client
 
+	// UseRetryPolicy sets the policy used to retry writes and idempotent
+	// (SELECT and SHOW) queries that fail with a transient error. By
+	// default, requests are not retried.
+	UseRetryPolicy(policy RetryPolicy) Client
+
+	// UseCardinalityGuard limits the number of series written to each
+	// measurement. The guard can be shared by several clients.
+	UseCardinalityGuard(guard *CardinalityGuard) Client
+
+	// UseDeduplicator coalesces points of each batch written that have the
+	// same series and time. Points written one at a time are coalesced if
+	// they are buffered by a BatchWriter. The deduplicator can be shared by
+	// several clients.
+	UseDeduplicator(dedup *Deduplicator) Client
+
+	// UseStrictDecode sets whether DecodeQuery returns an error if the query
+	// returns columns that are not decoded into the result.
+	UseStrictDecode(strict bool) Client
+
+	// UseObserver sets an Observer that is notified of the duration, size
+	// and error of each query, decode, encode and write. Use Observers to
+	// set several.
+	UseObserver(observer Observer) Client
+
+	// UseRouter sets a function that selects the database and retention
+	// policy of each point written. Points are grouped by destination, and
+	// written with one write per destination. The value of the tag with the
+	// route option, such as `influx:"customer,tag,route"`, is passed to the
+	// router; without a router, it is ignored, and points are written to
+	// the default destination.
+	UseRouter(route RouteFunc) Client
+
 	// Query executes an InfluxDb query, and unpacks the result into the
-	// result data structure.
-	DecodeQuery(query string, result interface{}) error
+	// result data structure. If meta is given, metadata about the decode
+	// is stored in it.
+	DecodeQuery(query string, result interface{}, meta ...*DecodeResult) error
+
+	// DecodeQueryParams executes an InfluxDb query with bound parameters,
+	// and unpacks the result into the result data structure. Parameters are
+	// referenced in the query as $name.
+	DecodeQueryParams(query string, params map[string]interface{}, result interface{}, meta ...*DecodeResult) error
+
+	// CreateDatabase creates a database if it does not exist.
+	CreateDatabase(name string) error
+
+	// DropDatabase drops a database and all of its data, if it exists.
+	DropDatabase(name string) error
+
+	// ListDatabases returns the names of the databases on the server.
+	ListDatabases() ([]string, error)
+
+	// CreateRetentionPolicy creates a retention policy on db if no
+	// retention policy with the same name exists.
+	CreateRetentionPolicy(db string, rp RetentionPolicy) error
+
+	// AlterRetentionPolicy updates an existing retention policy on db.
+	AlterRetentionPolicy(db string, rp RetentionPolicy) error
+
+	// ListRetentionPolicies returns the retention policies of db.
+	ListRetentionPolicies(db string) ([]RetentionPolicy, error)
+
+	// DropMeasurement drops a measurement from the db set with UseDB, if
+	// it exists.
+	DropMeasurement(measurement string) error
+
+	// DeleteSeries deletes the series of measurement in the db set with
+	// UseDB whose tags match the non-empty tag fields of the filter struct,
+	// or all series if filter is nil.
+	DeleteSeries(measurement string, filter interface{}) error
+
+	// CreateContinuousQuery creates a continuous query on db if no
+	// continuous query with the same name exists. RollupQuery can be used
+	// to generate the query from struct types.
+	CreateContinuousQuery(db string, cq ContinuousQuery) error
+
+	// ListContinuousQueries returns the continuous queries defined on db.
+	ListContinuousQueries(db string) ([]ContinuousQuery, error)
+
+	// DropContinuousQuery drops a continuous query from db, if it exists.
+	DropContinuousQuery(db, name string) error
+
+	// CreateUser creates a user if no user with the same name exists.
+	CreateUser(name, password string, admin bool) error
+
+	// DropUser drops a user, if it exists.
+	DropUser(name string) error
+
+	// ListUsers returns the users on the server.
+	ListUsers() ([]User, error)
+
+	// SetPassword sets the password of a user.
+	SetPassword(name, password string) error
+
+	// Grant grants a privilege on db to a user. If db is empty, the user is
+	// made an admin.
+	Grant(user string, privilege Privilege, db string) error
+
+	// Revoke revokes a privilege on db from a user. If db is empty, admin
+	// privileges are revoked.
+	Revoke(user string, privilege Privilege, db string) error
+
+	// ShowGrants returns the privileges granted to a user on each database.
+	ShowGrants(user string) ([]Grant, error)
 
 	// WritePoint is used to write arbitrary data into InfluxDb.
+	// A field type option in the struct field tag, such as
+	// `influx:"count,int"`, coerces the value to that InfluxDb type
+	// (int, float, string, bool or unsigned) regardless of the Go type,
+	// which avoids field type conflicts between writers. Tag values can be
+	// normalized and validated with the trim, lower, required, maxlen=N and
+	// oneof=a|b options; empty tags are omitted unless required. The
+	// entries of a map[string]string field tagged `influx:",tags"` and a
+	// map[string]interface{} field tagged `influx:",fields"` are written as
+	// additional tags and fields.
 	WritePoint(data interface{}) error
 
+	// WritePoints writes a slice of structs, encoded as for WritePoint, with
+	// one write per destination.
+	WritePoints(data interface{}) error
+
 	// WritePointTagsFields is used to write a point specifying tags and fields.
 	WritePointTagsFields(tags map[string]string, fields map[string]interface{}, t time.Time) error
 }
 
 type helperClient struct {
-	url       string
-	client    influxClient.Client
-	precision string
-	using     *helperUsing
+	url         string
+	client      influxClient.Client
+	precision   string
+	using       *helperUsing
+	retry       *RetryPolicy
+	naming      NamingStrategy
+	cardinality *CardinalityGuard
+	dedup       *Deduplicator
+	strict      bool
+	observer    Observer
+	router      RouteFunc
 }
 
 type usingValue struct {
@@ -62,9 +197,19 @@ type usingValue struct {
 }
 
 type helperUsing struct {
-	db          *usingValue
-	measurement *usingValue
-	timeField   *usingValue
+	db              *usingValue
+	retentionPolicy *usingValue
+	measurement     *usingValue
+	timeField       *usingValue
+}
+
+// rp returns the retention policy set with UseRetentionPolicy, or "" for
+// the default.
+func (u *helperUsing) rp() string {
+	if u.retentionPolicy == nil {
+		return ""
+	}
+	return u.retentionPolicy.value
 }
 
 // NewClient returns a new influxdbhelper influxClient given a url, user,
@@ -80,15 +225,18 @@ func NewClient(url, user, passwd, precision string) (Client, error) {
 		precision: precision,
 	}
 
-	client, err := influxClient.NewHTTPClient(influxClient.HTTPConfig{
+	client, err := newHTTPClient(influxClient.HTTPConfig{
 		Addr:     url,
 		Username: user,
 		Password: passwd,
 	})
+	if err != nil {
+		return ret, err
+	}
 
 	ret.client = client
 
-	return ret, err
+	return ret, nil
 }
 
 // Ping checks that status of cluster, and will always return 0 time and no
@@ -98,19 +246,138 @@ func (c *helperClient) Ping(timeout time.Duration) (time.Duration, string, error
 }
 
 // Write takes a BatchPoints object and writes all Points to InfluxDB.
+//
+// If InfluxDb rejects the points, a *WriteError is returned. If the points
+// exceed the limit of a CardinalityGuard set to reject them, a
+// *CardinalityError is returned.
+//
+// With UseRouter, the points are grouped by destination, and written with
+// one write per destination.
 func (c *helperClient) Write(bp influxClient.BatchPoints) error {
-	return c.client.Write(bp)
+	if c.router == nil {
+		return c.writeBatch(bp)
+	}
+
+	points := make([]routedPoint, len(bp.Points()))
+	for i, p := range bp.Points() {
+		points[i] = routedPoint{point: p}
+	}
+
+	return c.writeRouted(points, Destination{bp.Database(), bp.RetentionPolicy()}, bp.Precision())
+}
+
+// writeBatch writes bp, coalescing duplicates, checking the cardinality
+// and retrying.
+func (c *helperClient) writeBatch(bp influxClient.BatchPoints) (err error) {
+	if c.dedup != nil {
+		bp, _, err = c.dedup.Dedup(bp)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.cardinality != nil {
+		var done func(written bool)
+		bp, done, err = c.cardinality.check(bp)
+		if err != nil {
+			return err
+		}
+		defer func() { done(err == nil) }()
+		if len(bp.Points()) == 0 {
+			return nil
+		}
+	}
+
+	start := time.Now()
+	var n int
+	defer func() {
+		c.observe(start, Event{Operation: OpWrite, Database: bp.Database(),
+			Points: len(bp.Points()), Bytes: n, Err: err})
+	}()
+
+	if c.retry == nil {
+		n, err = c.write(bp)
+		return newWriteError(err)
+	}
+
+	return newWriteError(c.retry.do(func() (err error) {
+		n, err = c.write(bp)
+		return
+	}))
+}
+
+// sizedClient is implemented by clients that return the size of writes
+// and query responses.
+type sizedClient interface {
+	write(bp influxClient.BatchPoints) (int, error)
+	query(q influxClient.Query) (*influxClient.Response, int, error)
+}
+
+// write writes bp, and returns the size written if it is known.
+func (c *helperClient) write(bp influxClient.BatchPoints) (int, error) {
+	if sc, ok := c.client.(sizedClient); ok {
+		return sc.write(bp)
+	}
+	return 0, c.client.Write(bp)
 }
 
 // Query makes an InfluxDB Query on the database. This will fail if using
 // the UDP client.
-func (c *helperClient) Query(q influxClient.Query) (*influxClient.Response, error) {
-	return c.client.Query(q)
+func (c *helperClient) Query(q influxClient.Query) (response *influxClient.Response, err error) {
+	start := time.Now()
+	var n int
+	defer func() {
+		if c.observer == nil {
+			return
+		}
+		e := Event{Operation: OpQuery, Database: q.Database, Query: q.Command,
+			Params: q.Parameters, Rows: responseRows(response), Bytes: n, Err: err}
+		if err == nil && response != nil {
+			e.Err = newQueryError(q.Command, response, nil)
+		}
+		c.observe(start, e)
+	}()
+
+	if c.retry == nil || !isIdempotentQuery(q.Command) {
+		response, n, err = c.query(q)
+		return
+	}
+
+	err = c.retry.do(func() (err error) {
+		response, n, err = c.query(q)
+		return
+	})
+
+	return
+}
+
+// query makes a query, and returns the size of the response if it is known.
+func (c *helperClient) query(q influxClient.Query) (*influxClient.Response, int, error) {
+	if sc, ok := c.client.(sizedClient); ok {
+		return sc.query(q)
+	}
+	response, err := c.client.Query(q)
+	return response, 0, err
 }
 
 // QueryAsChunk -
-func (c *helperClient) QueryAsChunk(q influxClient.Query) (*influxClient.ChunkedResponse, error) {
-	return c.client.QueryAsChunk(q)
+func (c *helperClient) QueryAsChunk(q influxClient.Query) (response *influxClient.ChunkedResponse, err error) {
+	start := time.Now()
+	defer func() {
+		c.observe(start, Event{Operation: OpQuery, Database: q.Database, Query: q.Command,
+			Params: q.Parameters, Err: err})
+	}()
+
+	if c.retry == nil || !isIdempotentQuery(q.Command) {
+		return c.client.QueryAsChunk(q)
+	}
+
+	err = c.retry.do(func() (err error) {
+		response, err = c.client.QueryAsChunk(q)
+		return
+	})
+
+	return
 }
 
 // Close releases any resources a Client may be using.
@@ -128,6 +395,16 @@ func (c *helperClient) UseDB(db string) Client {
 	return c
 }
 
+// UseRetentionPolicy sets the retention policy to use for DecodeQuery, WritePoint, and WritePointTagsFields
+func (c *helperClient) UseRetentionPolicy(rp string) Client {
+	if c.using == nil {
+		c.using = &helperUsing{}
+	}
+
+	c.using.retentionPolicy = &usingValue{rp, true}
+	return c
+}
+
 // UseMeasurement sets the DB to use for Query, WritePoint, and WritePointTagsFields
 func (c *helperClient) UseMeasurement(measurement string) Client {
 	if c.using == nil {
@@ -148,6 +425,48 @@ func (c *helperClient) UseTimeField(fieldName string) Client {
 	return c
 }
 
+// UseNamingStrategy sets how WritePoint converts type names to measurement names.
+func (c *helperClient) UseNamingStrategy(naming NamingStrategy) Client {
+	c.naming = naming
+	return c
+}
+
+// UseRetryPolicy sets the policy used to retry writes and idempotent queries.
+func (c *helperClient) UseRetryPolicy(policy RetryPolicy) Client {
+	c.retry = &policy
+	return c
+}
+
+// UseCardinalityGuard limits the number of series written to each measurement.
+func (c *helperClient) UseCardinalityGuard(guard *CardinalityGuard) Client {
+	c.cardinality = guard
+	return c
+}
+
+// UseDeduplicator coalesces duplicate points of each batch written.
+func (c *helperClient) UseDeduplicator(dedup *Deduplicator) Client {
+	c.dedup = dedup
+	return c
+}
+
+// UseStrictDecode sets whether DecodeQuery returns an error for unknown columns.
+func (c *helperClient) UseStrictDecode(strict bool) Client {
+	c.strict = strict
+	return c
+}
+
+// UseObserver sets the Observer notified of each operation.
+func (c *helperClient) UseObserver(observer Observer) Client {
+	c.observer = observer
+	return c
+}
+
+// UseRouter sets the function that selects the destination of points.
+func (c *helperClient) UseRouter(route RouteFunc) Client {
+	c.router = route
+	return c
+}
+
 // Query executes an InfluxDb query, and unpacks the result into the
 // result data structure.
 //
@@ -159,42 +478,139 @@ func (c *helperClient) UseTimeField(fieldName string) Client {
 // and InfluxDb field/tag names typically start with a lower case letter.
 // The struct field tag can be set to '-' which indicates this field
 // should be ignored.
-func (c *helperClient) DecodeQuery(q string, result interface{}) (err error) {
+//
+// To keep the series of a GROUP BY query separate, result can also be a
+// map[string][]T, keyed by TagSetKey of the series tags, or a slice of
+// series structs with Name, Tags, and Rows []T `influx:",rows"` fields.
+//
+// Columns of aggregate queries can be decoded using the expression in the
+// struct field tag, for example `influx:"mean(temperature)"` for a column
+// returned by SELECT mean(temperature) FROM ... . Columns renamed with AS
+// are decoded using the alias.
+//
+// Columns that do not match a struct field are stored in a map[string]string
+// field tagged `influx:",tags"` or a map[string]interface{} field tagged
+// `influx:",fields"`, if present. Use GROUP BY * to keep tags separate from
+// string fields.
+//
+// If a DecodeResult is passed as meta, it is set to the columns returned
+// that were not decoded, the struct fields that were not set, and the
+// series of each row. With UseStrictDecode, a *DecodeError is returned if
+// any columns were not decoded.
+//
+// ErrNoDatabase is returned if no db is set, an error matching
+// ErrConnection if the server cannot be reached, a *QueryError if InfluxDb
+// reports an error for the query, and a *DecodeError if the results cannot
+// be decoded into result.
+func (c *helperClient) DecodeQuery(q string, result interface{}, meta ...*DecodeResult) (err error) {
+	return c.DecodeQueryParams(q, nil, result, meta...)
+}
+
+// DecodeQueryParams executes an InfluxDb query with bound parameters, and
+// unpacks the result into the result data structure in the same way as
+// DecodeQuery.
+//
+// Parameters are referenced in the query as $name, and are sent to InfluxDb
+// separately from the query, so values do not need to be quoted or escaped.
+// time.Time values are sent as RFC3339 strings. StructParams can be used to
+// create params from a struct.
+func (c *helperClient) DecodeQueryParams(q string, params map[string]interface{}, result interface{}, meta ...*DecodeResult) (err error) {
 	if c.using == nil || c.using.db == nil {
-		return fmt.Errorf("no db set for query")
+		return ErrNoDatabase
 	}
 
 	query := influxClient.Query{
-		Command:   q,
-		Database:  c.using.db.value,
-		Chunked:   false,
-		ChunkSize: 100,
+		Command:         q,
+		Database:        c.using.db.value,
+		RetentionPolicy: c.using.rp(),
+		Chunked:         false,
+		ChunkSize:       100,
+		Parameters:      bindParams(params),
+	}
+
+	response, err := c.Query(query)
+
+	if response != nil {
+		if qErr := newQueryError(q, response, err); qErr != nil {
+			return qErr
+		}
+	}
+
+	if err != nil {
+		return err
 	}
 
-	var response *influxClient.Response
-	response, err = c.client.Query(query)
 	if !c.using.db.retain {
 		c.using.db = nil
 	}
 
-	if response.Error() != nil {
-		return response.Error()
+	var state *decodeState
+	if c.strict || (len(meta) > 0 && meta[0] != nil) {
+		state = newDecodeState()
 	}
 
-	if err != nil {
-		return
+	results := response.Results
+	if len(results) > 0 && len(results[0].Series) > 0 {
+		aliases := addExpressionColumns(q, results[0].Series)
+		if state != nil {
+			state.aliases = aliases
+		}
+
+		start := time.Now()
+		err = decodeWithState(results[0].Series, result, state)
+		if err != nil {
+			err = &DecodeError{err}
+		}
+		c.observe(start, Event{Operation: OpDecode, Database: query.Database, Query: q,
+			Rows: seriesRows(results[0].Series), Err: err})
+		if err != nil {
+			return err
+		}
 	}
 
-	results := response.Results
-	if len(results) < 1 || len(results[0].Series) < 1 {
+	if state == nil {
 		return
 	}
 
-	err = decode(results[0].Series, result)
+	r := state.result()
+	if len(meta) > 0 && meta[0] != nil {
+		*meta[0] = r
+	}
+
+	if c.strict {
+		if err := unknownColumnsError(r); err != nil {
+			return &DecodeError{err}
+		}
+	}
 
 	return
 }
 
+// newQueryError returns a *QueryError for the first statement error in a
+// response, or nil if there is none. Passwords are redacted from the
+// statement.
+func newQueryError(q string, response *influxClient.Response, err error) error {
+	q = redactPasswords(q)
+
+	if response.Err != "" {
+		return &QueryError{Statement: q, Message: response.Err, Err: err}
+	}
+
+	statements := strings.Split(q, ";")
+	for i, result := range response.Results {
+		if result.Err == "" {
+			continue
+		}
+		statement := q
+		if len(statements) == len(response.Results) {
+			statement = strings.TrimSpace(statements[i])
+		}
+		return &QueryError{Statement: statement, Message: result.Err, Err: err}
+	}
+
+	return nil
+}
+
 // WritePoint is used to write arbitrary data into InfluxDb.
 //
 // data must be a struct with struct field tags that defines the names used
@@ -204,40 +620,117 @@ func (c *helperClient) DecodeQuery(q string, result interface{}) (err error) {
 // is used for the time of the sample.
 func (c *helperClient) WritePoint(data interface{}) error {
 	if c.using == nil || c.using.db == nil {
-		return fmt.Errorf("no db set for query")
+		return ErrNoDatabase
 	}
 
-	t, tags, fields, measurement, err := encode(data, c.using.timeField)
-
-	if c.using.measurement == nil {
+	start := time.Now()
+	t, tags, fields, measurement, err := encodeWithNaming(data, c.using.timeField, c.naming)
+	if err == nil && c.using.measurement == nil {
 		c.using.measurement = &usingValue{measurement, false}
 	}
 
+	if c.observer != nil {
+		e := Event{Operation: OpEncode, Database: c.using.db.value, Measurement: measurement, Err: err}
+		if err == nil {
+			e.Measurement = c.using.measurement.value
+			e.Points = 1
+		}
+		c.observe(start, e)
+	}
+
 	if err != nil {
 		return err
 	}
 
-	return c.WritePointTagsFields(tags, fields, t)
+	route := ""
+	if key := routeTag(data); key != "" {
+		route = tags[key]
+	}
+
+	return c.writePoint(tags, fields, t, route)
 }
 
-// WritePointTagsFields is used to write a point specifying tags and fields.
-func (c *helperClient) WritePointTagsFields(tags map[string]string, fields map[string]interface{}, t time.Time) (err error) {
+// WritePoints writes a slice of structs, or of pointers to structs, encoded
+// as for WritePoint. The measurement set with UseMeasurement is used for
+// all points, otherwise the measurement of each struct. The points are
+// grouped by destination, and written with one write per destination.
+func (c *helperClient) WritePoints(data interface{}) error {
 	if c.using == nil || c.using.db == nil {
-		return fmt.Errorf("no db set for query")
+		return ErrNoDatabase
 	}
 
-	if c.using.measurement == nil {
-		return fmt.Errorf("no measurement set for query")
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return errors.New("data must be a slice of structs")
 	}
 
-	bp, err := influxClient.NewBatchPoints(influxClient.BatchPointsConfig{
-		Database:  c.using.db.value,
-		Precision: c.precision,
-	})
+	def := Destination{c.using.db.value, c.using.rp()}
+	measurement := c.using.measurement
+	timeField := c.using.timeField
 
-	if err != nil {
-		return err
+	if !c.using.db.retain {
+		c.using.db = nil
 	}
+	if measurement != nil && !measurement.retain {
+		c.using.measurement = nil
+	}
+	if timeField != nil && !timeField.retain {
+		c.using.timeField = nil
+	}
+
+	points := make([]routedPoint, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		d := v.Index(i).Interface()
+
+		start := time.Now()
+		t, tags, fields, m, err := encodeWithNaming(d, timeField, c.naming)
+		if measurement != nil {
+			m = measurement.value
+		}
+
+		if c.observer != nil {
+			e := Event{Operation: OpEncode, Database: def.Database, Measurement: m, Err: err}
+			if err == nil {
+				e.Points = 1
+			}
+			c.observe(start, e)
+		}
+
+		if err != nil {
+			return fmt.Errorf("point %v: %w", i, err)
+		}
+
+		pt, err := influxClient.NewPoint(m, tags, fields, t)
+		if err != nil {
+			return fmt.Errorf("point %v: %w", i, err)
+		}
+
+		p := routedPoint{point: pt}
+		if key := routeTag(d); key != "" {
+			p.route = tags[key]
+		}
+		points = append(points, p)
+	}
+
+	return c.writeRouted(points, def, c.precision)
+}
+
+// WritePointTagsFields is used to write a point specifying tags and fields.
+func (c *helperClient) WritePointTagsFields(tags map[string]string, fields map[string]interface{}, t time.Time) error {
+	return c.writePoint(tags, fields, t, "")
+}
+
+// writePoint writes a point with a route value.
+func (c *helperClient) writePoint(tags map[string]string, fields map[string]interface{}, t time.Time, route string) error {
+	if c.using == nil || c.using.db == nil {
+		return ErrNoDatabase
+	}
+
+	if c.using.measurement == nil {
+		return ErrNoMeasurement
+	}
+
+	def := Destination{c.using.db.value, c.using.rp()}
 
 	pt, err := influxClient.NewPoint(c.using.measurement.value, tags, fields, t)
 	if !c.using.db.retain {
@@ -254,7 +747,5 @@ func (c *helperClient) WritePointTagsFields(tags map[string]string, fields map[s
 		return err
 	}
 
-	bp.AddPoint(pt)
-
-	return c.client.Write(bp)
+	return c.writeRouted([]routedPoint{{pt, route}}, def, c.precision)
 }
@@ -1,7 +1,11 @@
 package influxdbhelper
 
 import (
+	"reflect"
+	"testing"
 	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
 )
 
 func ExampleClient_WritePoint() {
@@ -45,3 +49,75 @@ func ExampleClient_Query() {
 
 	// samplesRead is now populated with data from InfluxDb
 }
+
+func TestClientWriteDecodeQuery(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("myDb")
+
+	c, err := NewClient(s.URL, "", "", "ns")
+	if err != nil {
+		t.Fatal("Error creating client: ", err)
+	}
+
+	type EnvSample struct {
+		InfluxMeasurement Measurement
+		Time              time.Time `influx:"time"`
+		Location          string    `influx:"location,tag"`
+		Temperature       float64   `influx:"temperature"`
+		Count             int64     `influx:"count"`
+	}
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	written := []EnvSample{}
+	for i := 0; i < 3; i++ {
+		s := EnvSample{"test", start.Add(time.Duration(i) * time.Second),
+			"Rm 243", 70.5 + float64(i), int64(i)}
+		written = append(written, s)
+
+		if err := c.UseDB("myDb").WritePoint(s); err != nil {
+			t.Fatal("Error writing point: ", err)
+		}
+	}
+
+	read := []EnvSample{}
+	err = c.UseDB("myDb").DecodeQuery(`SELECT * FROM test`, &read)
+	if err != nil {
+		t.Fatal("Error decoding query: ", err)
+	}
+
+	if !reflect.DeepEqual(written, read) {
+		t.Errorf("%+v != %+v", read, written)
+	}
+}
+
+func TestClientUseRetentionPolicy(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseDB("db").UseRetentionPolicy("week")
+
+	err := c.UseMeasurement("test").WritePointTagsFields(nil,
+		map[string]interface{}{"value": 1.0}, time.Now())
+	if err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+
+	result := []struct{}{}
+	if err := c.DecodeQuery("SELECT * FROM test", &result); err != nil {
+		t.Fatal("Error decoding query: ", err)
+	}
+
+	requests := s.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("%v != 2", len(requests))
+	}
+
+	for _, r := range requests {
+		if rp := r.Query.Get("rp"); rp != "week" {
+			t.Errorf("%v: %v != week", r.Path, rp)
+		}
+	}
+}
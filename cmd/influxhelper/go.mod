@@ -0,0 +1,9 @@
+module github.com/cbrake/influxdbhelper/v2/cmd/influxhelper
+
+require (
+	github.com/cbrake/influxdbhelper/v2 v2.2.0
+	github.com/cbrake/influxdbhelper/v2/influxarrow v0.1.0
+	github.com/influxdata/influxdb1-client v0.0.0-20190809212627-fc22c7df067e
+)
+
+go 1.13
@@ -0,0 +1,181 @@
+// Command influxhelper writes, queries and exports InfluxDb data from the
+// command line.
+//
+// Usage:
+//
+//	influxhelper [flags] <command> [command flags] [args]
+//
+// The flags select the server and database, and match the arguments of
+// influxdbhelper.NewClient:
+//
+//	-url        server url (default http://localhost:8086)
+//	-user       user name
+//	-password   password
+//	-db         database
+//	-rp         retention policy (default: the database default)
+//	-precision  precision of written and exported times (default ns)
+//
+// The defaults of the flags, and the TLS, timeout and proxy settings, are
+// read from the INFLUX_* environment variables of
+// influxdbhelper.ConfigFromEnv.
+//
+// The commands are:
+//
+//	write   write JSON or CSV lines read from stdin
+//	query   run a query and print the result as a table, JSON, CSV or Arrow
+//	ping    check that the server is up, and print its version
+//	schema  print the tags and fields of measurements
+//	export  print the points of measurements as line protocol
+//
+// Run influxhelper <command> -h for the flags of each command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cbrake/influxdbhelper/v2"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, "influxhelper:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// options are the global flags shared by all commands.
+type options struct {
+	// config is read from the environment, and provides the defaults of
+	// the flags.
+	config influxdbhelper.Config
+
+	url       string
+	user      string
+	password  string
+	db        string
+	rp        string
+	precision string
+}
+
+// command is a subcommand. args are the arguments following the command
+// name.
+type command struct {
+	summary string
+	run     func(o *options, args []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+var commands = map[string]command{
+	"write":  {"write JSON or CSV lines read from stdin", runWrite},
+	"query":  {"run a query and print the result", runQuery},
+	"ping":   {"check that the server is up", runPing},
+	"schema": {"print the tags and fields of measurements", runSchema},
+	"export": {"print the points of measurements as line protocol", runExport},
+}
+
+var commandOrder = []string{"write", "query", "ping", "schema", "export"}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	config, err := influxdbhelper.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	if config.Precision == "" {
+		config.Precision = "ns"
+	}
+
+	o := &options{config: config}
+
+	fs := flag.NewFlagSet("influxhelper", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&o.url, "url", config.URL, "server url")
+	fs.StringVar(&o.user, "user", config.Username, "user name")
+	fs.StringVar(&o.password, "password", config.Password, "password")
+	fs.StringVar(&o.db, "db", config.DB, "database")
+	fs.StringVar(&o.rp, "rp", config.RetentionPolicy, "retention policy (default: the database default)")
+	fs.StringVar(&o.precision, "precision", config.Precision, "precision of written and exported times: h, m, s, ms, u or ns")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: influxhelper [flags] <command> [command flags] [args]")
+		fmt.Fprintln(stderr, "\ncommands:")
+		for _, name := range commandOrder {
+			fmt.Fprintf(stderr, "  %-8s %s\n", name, commands[name].summary)
+		}
+		fmt.Fprintln(stderr, "\nflags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errors.New("no command")
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("unknown command: %v", fs.Arg(0))
+	}
+
+	return cmd.run(o, fs.Args()[1:], stdin, stdout, stderr)
+}
+
+// newClient returns a client for the server, using the database and
+// retention policy set with the global flags.
+func (o *options) newClient() (influxdbhelper.Client, error) {
+	config := o.config
+	config.URL = o.url
+	config.Username, config.Password = o.user, o.password
+	config.DB, config.RetentionPolicy = o.db, o.rp
+	config.Precision = o.precision
+
+	return influxdbhelper.NewClientWithConfig(config)
+}
+
+// requireDB returns an error if the -db flag was not set.
+func (o *options) requireDB() error {
+	if o.db == "" {
+		return errors.New("-db is required")
+	}
+	return nil
+}
+
+// newFlagSet returns the flag set of a command.
+func newFlagSet(name, args string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: influxhelper [flags] %v [flags] %v\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func runPing(o *options, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("ping", "", stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := o.newClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	rtt, version, err := c.Ping(0)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%v: InfluxDb %v, %v\n", o.url, version, rtt)
+	return nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+func runCommand(t *testing.T, s *influxdbtest.Server, stdin string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-url", s.URL, "-db", "db"}, args...)
+	err := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func TestWriteJSON(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	input := `{"measurement": "env", "tags": {"location": "rm1"}, "fields": {"temperature": 20.5, "ok": true}, "time": "2019-01-01T00:00:00Z"}
+
+{"tags": {"location": "rm2"}, "fields": {"temperature": 21, "ok": false}, "time": 1546300801000000000}
+`
+
+	if _, err := runCommand(t, s, input, "write", "-measurement", "env"); err != nil {
+		t.Fatal("Error writing: ", err)
+	}
+
+	points := s.Points("db")
+	if len(points) != 2 {
+		t.Fatalf("%v != 2", len(points))
+	}
+
+	exp := []string{
+		"env,location=rm1 ok=true,temperature=20.5 1546300800000000000",
+		"env,location=rm2 ok=false,temperature=21 1546300801000000000",
+	}
+	for i, p := range points {
+		if p.String() != exp[i] {
+			t.Errorf("%v != %v", p.String(), exp[i])
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	input := "measurement,time,location,temperature,state\n" +
+		"env,1546300800,rm1,20.5,on\n" +
+		",1546300801,rm2,21,\n"
+
+	if _, err := runCommand(t, s, input, "-precision", "s", "write", "-format", "csv",
+		"-measurement", "other", "-tags", "location"); err != nil {
+		t.Fatal("Error writing: ", err)
+	}
+
+	exp := []string{
+		`env,location=rm1 state="on",temperature=20.5 1546300800000000000`,
+		"other,location=rm2 temperature=21 1546300801000000000",
+	}
+
+	points := s.Points("db")
+	if len(points) != len(exp) {
+		t.Fatalf("%v != %v", len(points), len(exp))
+	}
+	for i, p := range points {
+		if p.String() != exp[i] {
+			t.Errorf("%v != %v", p.String(), exp[i])
+		}
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	if _, err := runCommand(t, s, `{"fields": {"v": 1}}`, "write"); err == nil ||
+		!strings.Contains(err.Error(), "line 1") {
+		t.Error("Expected measurement error, got: ", err)
+	}
+
+	if _, err := runCommand(t, s, `{"measurement": "m", "fields": {"v": 1}, "time": "yesterday"}`, "write"); err == nil {
+		t.Error("Expected time error")
+	}
+
+	if _, err := runCommand(t, s, "", "write", "-format", "xml"); err == nil {
+		t.Error("Expected format error")
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"-url", s.URL, "write"}, strings.NewReader(""), &stdout, &stderr); err == nil {
+		t.Error("Expected -db error")
+	}
+
+	if err := run([]string{"bogus"}, strings.NewReader(""), &stdout, &stderr); err == nil {
+		t.Error("Expected unknown command error")
+	}
+}
+
+func writeEnv(t *testing.T, s *influxdbtest.Server) {
+	s.CreateDatabase("db")
+
+	input := `{"measurement": "env", "tags": {"location": "rm1"}, "fields": {"temperature": 20.5}, "time": "2019-01-01T00:00:00Z"}
+{"measurement": "env", "tags": {"location": "rm2"}, "fields": {"temperature": 21, "state": "on"}, "time": "2019-01-01T00:00:01Z"}
+`
+
+	if _, err := runCommand(t, s, input, "write"); err != nil {
+		t.Fatal("Error writing: ", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	writeEnv(t, s)
+
+	out, err := runCommand(t, s, "", "query", "SELECT temperature\n  FROM env GROUP BY location")
+	if err != nil {
+		t.Fatal("Error querying: ", err)
+	}
+
+	exp := `> SELECT temperature FROM env GROUP BY location
+
+name: env
+tags: location=rm1
+time                  temperature
+----                  -----------
+2019-01-01T00:00:00Z  20.5
+
+name: env
+tags: location=rm2
+time                  temperature
+----                  -----------
+2019-01-01T00:00:01Z  21
+`
+	if out != exp {
+		t.Errorf("Unexpected table:\n%v", out)
+	}
+
+	out, err = runCommand(t, s, "SELECT * FROM env", "query", "-format", "csv", "-epoch", "s")
+	if err != nil {
+		t.Fatal("Error querying: ", err)
+	}
+
+	exp = "name,time,location,state,temperature\n" +
+		"env,1546300800,rm1,,20.5\n" +
+		"env,1546300801,rm2,on,21\n"
+	if out != exp {
+		t.Errorf("Unexpected CSV:\n%v", out)
+	}
+
+	out, err = runCommand(t, s, "", "query", "-format", "json", "SELECT", "temperature", "FROM", "env")
+	if err != nil {
+		t.Fatal("Error querying: ", err)
+	}
+
+	exp = `{"name":"env","time":"2019-01-01T00:00:00Z","temperature":20.5}
+{"name":"env","time":"2019-01-01T00:00:01Z","temperature":21}
+`
+	if out != exp {
+		t.Errorf("Unexpected JSON:\n%v", out)
+	}
+
+	if _, err := runCommand(t, s, "", "query", "SELECT * FROM"); err == nil {
+		t.Error("Expected query error")
+	}
+}
+
+func TestPing(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+
+	out, err := runCommand(t, s, "", "ping")
+	if err != nil {
+		t.Fatal("Error pinging: ", err)
+	}
+
+	if !strings.Contains(out, influxdbtest.Version) {
+		t.Errorf("Version missing from: %v", out)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	writeEnv(t, s)
+
+	out, err := runCommand(t, s, "", "schema")
+	if err != nil {
+		t.Fatal("Error getting schema: ", err)
+	}
+
+	exp := `measurement  key          kind   type
+-----------  ---          ----   ----
+env          location     tag    string
+env          state        field  string
+env          temperature  field  float
+`
+	if out != exp {
+		t.Errorf("Unexpected schema:\n%v", out)
+	}
+}
+
+func TestExport(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	writeEnv(t, s)
+
+	out, err := runCommand(t, s, "", "-precision", "s", "export", "-measurement", "env")
+	if err != nil {
+		t.Fatal("Error exporting: ", err)
+	}
+
+	exp := "env,location=rm1 temperature=20.5 1546300800\n" +
+		`env,location=rm2 state="on",temperature=21 1546300801` + "\n"
+	if out != exp {
+		t.Errorf("Unexpected export:\n%v", out)
+	}
+
+	// an empty database exports nothing
+	empty := influxdbtest.NewServer()
+	defer empty.Close()
+	empty.CreateDatabase("db")
+
+	out, err = runCommand(t, empty, "", "export")
+	if err != nil || out != "" {
+		t.Errorf("Unexpected export of empty database: %q, %v", out, err)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cbrake/influxdbhelper/v2"
+	"github.com/cbrake/influxdbhelper/v2/influxarrow"
+	influxModels "github.com/influxdata/influxdb1-client/models"
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+func runQuery(o *options, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("query", "[query]", stderr)
+	format := fs.String("format", "table", "output format: table, json, csv or arrow")
+	epoch := fs.String("epoch", "", "print times as integers in this precision instead of RFC3339")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// the query is read from stdin if it is not an argument
+	q := strings.Join(fs.Args(), " ")
+	if q == "" {
+		b, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		q = string(b)
+	}
+
+	if strings.TrimSpace(q) == "" {
+		return errors.New("no query")
+	}
+
+	// times are written as returned by the query
+	options := influxdbhelper.ExportOptions{Precision: *epoch, Epoch: *epoch}
+
+	var print func(w io.Writer, q string, series []influxModels.Row) error
+	switch *format {
+	case "table":
+		print = printTable
+	case "json":
+		print = exportWriter(influxdbhelper.NewJSONWriter, options)
+	case "csv":
+		print = exportWriter(influxdbhelper.NewCSVWriter, options)
+	case "arrow":
+		print = exportWriter(influxarrow.NewWriter, options)
+	default:
+		return fmt.Errorf("unknown format: %v", *format)
+	}
+
+	c, err := o.newClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	response, err := c.Query(influxClient.Query{
+		Command:         q,
+		Database:        o.db,
+		RetentionPolicy: o.rp,
+		Precision:       *epoch,
+	})
+	if err == nil {
+		err = response.Error()
+	}
+	if err != nil {
+		return err
+	}
+
+	var series []influxModels.Row
+	for _, r := range response.Results {
+		series = append(series, r.Series...)
+	}
+
+	return print(stdout, influxdbhelper.CleanQuery(q), series)
+}
+
+// formatValue formats a value of a query result for display.
+func formatValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// formatTags formats tags as k=v pairs sorted by key.
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + tags[k]
+	}
+
+	return strings.Join(pairs, ", ")
+}
+
+// printTable prints the query, followed by a table for each series.
+func printTable(w io.Writer, q string, series []influxModels.Row) error {
+	fmt.Fprintf(w, "> %v\n", q)
+
+	for _, s := range series {
+		fmt.Fprintln(w)
+		if s.Name != "" {
+			fmt.Fprintf(w, "name: %v\n", s.Name)
+		}
+		if len(s.Tags) > 0 {
+			fmt.Fprintf(w, "tags: %v\n", formatTags(s.Tags))
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(s.Columns, "\t"))
+
+		underline := make([]string, len(s.Columns))
+		for i, c := range s.Columns {
+			underline[i] = strings.Repeat("-", len(c))
+		}
+		fmt.Fprintln(tw, strings.Join(underline, "\t"))
+
+		for _, values := range s.Values {
+			cells := make([]string, len(values))
+			for i, v := range values {
+				cells[i] = formatValue(v)
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportWriter returns a function that prints series using the exporter
+// newWriter.
+func exportWriter(newWriter func(io.Writer, influxdbhelper.ExportOptions) influxdbhelper.RowWriter,
+	options influxdbhelper.ExportOptions) func(io.Writer, string, []influxModels.Row) error {
+	return func(w io.Writer, q string, series []influxModels.Row) error {
+		rw := newWriter(w, options)
+		if err := rw.WriteRows(series); err != nil {
+			return err
+		}
+		return rw.Close()
+	}
+}
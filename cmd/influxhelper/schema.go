@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2"
+	influxModels "github.com/influxdata/influxdb1-client/models"
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+// schema is the tags and fields of a measurement.
+type schema struct {
+	tags   []string
+	fields map[string]string
+}
+
+// fromClause returns the FROM clause of a SHOW statement for measurement,
+// or "" for all measurements.
+func fromClause(measurement string) string {
+	if measurement == "" {
+		return ""
+	}
+	return " FROM " + influxdbhelper.QuoteIdent(measurement)
+}
+
+// getSchemas returns the schema of measurement, or of all measurements if
+// measurement is empty.
+func getSchemas(c influxdbhelper.Client, measurement string) (map[string]*schema, error) {
+	var fieldKeys []struct {
+		Name string
+		Rows []struct {
+			Key  string `influx:"fieldKey"`
+			Type string `influx:"fieldType"`
+		} `influx:",rows"`
+	}
+
+	if err := c.DecodeQuery("SHOW FIELD KEYS"+fromClause(measurement), &fieldKeys); err != nil {
+		return nil, err
+	}
+
+	var tagKeys []struct {
+		Name string
+		Rows []struct {
+			Key string `influx:"tagKey"`
+		} `influx:",rows"`
+	}
+
+	if err := c.DecodeQuery("SHOW TAG KEYS"+fromClause(measurement), &tagKeys); err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]*schema)
+	for _, s := range fieldKeys {
+		fields := make(map[string]string)
+		for _, r := range s.Rows {
+			fields[r.Key] = r.Type
+		}
+		ret[s.Name] = &schema{fields: fields}
+	}
+
+	for _, s := range tagKeys {
+		m, ok := ret[s.Name]
+		if !ok {
+			continue
+		}
+		for _, r := range s.Rows {
+			m.tags = append(m.tags, r.Key)
+		}
+	}
+
+	return ret, nil
+}
+
+func sortedMeasurements(schemas map[string]*schema) []string {
+	ret := make([]string, 0, len(schemas))
+	for name := range schemas {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func runSchema(o *options, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("schema", "", stderr)
+	measurement := fs.String("measurement", "", "measurement (default: all measurements)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := o.requireDB(); err != nil {
+		return err
+	}
+
+	c, err := o.newClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	schemas, err := getSchemas(c, *measurement)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "measurement\tkey\tkind\ttype")
+	fmt.Fprintln(tw, "-----------\t---\t----\t----")
+
+	for _, name := range sortedMeasurements(schemas) {
+		s := schemas[name]
+		for _, tag := range s.tags {
+			fmt.Fprintf(tw, "%v\t%v\ttag\tstring\n", name, tag)
+		}
+
+		fields := make([]string, 0, len(s.fields))
+		for k := range s.fields {
+			fields = append(fields, k)
+		}
+		sort.Strings(fields)
+
+		for _, field := range fields {
+			fmt.Fprintf(tw, "%v\t%v\tfield\t%v\n", name, field, s.fields[field])
+		}
+	}
+
+	return tw.Flush()
+}
+
+func runExport(o *options, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("export", "", stderr)
+	measurement := fs.String("measurement", "", "measurement (default: all measurements)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := o.requireDB(); err != nil {
+		return err
+	}
+
+	// unsigned fields are exported with a u suffix
+	influxModels.EnableUintSupport()
+
+	c, err := o.newClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// field values are returned as JSON numbers, so the field types are
+	// needed to export them with the right type
+	schemas, err := getSchemas(c, *measurement)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range sortedMeasurements(schemas) {
+		q := "SELECT * FROM " + influxdbhelper.QuoteIdent(name) + " GROUP BY *"
+		response, err := c.Query(influxClient.Query{
+			Command:         q,
+			Database:        o.db,
+			RetentionPolicy: o.rp,
+			Precision:       "ns",
+			Chunked:         true,
+		})
+		if err == nil {
+			err = response.Error()
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, r := range response.Results {
+			for _, s := range r.Series {
+				if err := exportSeries(stdout, s, schemas[name].fields, o.precision); err != nil {
+					return fmt.Errorf("%v: %v", name, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// exportSeries prints the rows of s as line protocol. types are the field
+// types of the measurement.
+func exportSeries(w io.Writer, s influxModels.Row, types map[string]string, precision string) error {
+	tags := influxModels.NewTags(s.Tags)
+
+	for _, values := range s.Values {
+		var t time.Time
+		fields := make(map[string]interface{})
+
+		for i, v := range values {
+			if v == nil || i >= len(s.Columns) {
+				continue
+			}
+
+			column := s.Columns[i]
+			if column == "time" {
+				n, err := v.(json.Number).Int64()
+				if err != nil {
+					return err
+				}
+				t = time.Unix(0, n)
+				continue
+			}
+
+			value, err := fieldValue(v, types[column])
+			if err != nil {
+				return fmt.Errorf("'%s': %v", column, err)
+			}
+			fields[column] = value
+		}
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		pt, err := influxModels.NewPoint(s.Name, tags, fields, t)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintln(w, pt.PrecisionString(precision)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fieldValue converts a value of a query result to a value of fieldType.
+func fieldValue(v interface{}, fieldType string) (interface{}, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v, nil
+	}
+
+	switch fieldType {
+	case "integer":
+		return n.Int64()
+	case "unsigned":
+		return strconv.ParseUint(n.String(), 10, 64)
+	}
+
+	return n.Float64()
+}
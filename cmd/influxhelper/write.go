@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2"
+)
+
+// point is a point read from the input of the write command.
+type point struct {
+	measurement string
+	tags        map[string]string
+	fields      map[string]interface{}
+	time        time.Time
+}
+
+// jsonPoint is the format of a JSON input line. Time is an RFC3339 string,
+// or an integer in units of the precision.
+type jsonPoint struct {
+	Measurement string                 `json:"measurement"`
+	Tags        map[string]string      `json:"tags"`
+	Fields      map[string]interface{} `json:"fields"`
+	Time        interface{}            `json:"time"`
+}
+
+func runWrite(o *options, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("write", "", stderr)
+	format := fs.String("format", "json", "input format: json or csv")
+	measurement := fs.String("measurement", "", "measurement of points that do not specify one")
+	tagList := fs.String("tags", "", "comma separated CSV columns to write as tags")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: influxhelper [flags] write [flags] < input")
+		fmt.Fprintln(stderr, "\nJSON input has a point on each line:")
+		fmt.Fprintln(stderr, `  {"measurement": "env", "tags": {"location": "rm1"}, "fields": {"temperature": 20.5}, "time": "2019-01-01T00:00:00Z"}`)
+		fmt.Fprintln(stderr, "\nCSV input has a header line, and a point on each following line. The")
+		fmt.Fprintln(stderr, "measurement and time columns are used for the measurement and time of the")
+		fmt.Fprintln(stderr, "point, the columns listed with -tags are tags, and the others are fields.")
+		fmt.Fprintln(stderr, "\nTimes are RFC3339, or integers in units of the precision. Points without a")
+		fmt.Fprintln(stderr, "time are written with the current time.")
+		fmt.Fprintln(stderr, "\nflags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := o.requireDB(); err != nil {
+		return err
+	}
+
+	tags := make(map[string]bool)
+	for _, t := range strings.Split(*tagList, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags[t] = true
+		}
+	}
+
+	var read func(func(point) error) error
+	switch *format {
+	case "json":
+		read = func(write func(point) error) error {
+			return readJSON(stdin, o.precision, write)
+		}
+	case "csv":
+		read = func(write func(point) error) error {
+			return readCSV(stdin, o.precision, tags, write)
+		}
+	default:
+		return fmt.Errorf("unknown format: %v", *format)
+	}
+
+	c, err := o.newClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	count := 0
+	err = read(func(p point) error {
+		if p.measurement == "" {
+			p.measurement = *measurement
+		}
+		if p.measurement == "" {
+			return influxdbhelper.ErrNoMeasurement
+		}
+		if p.time.IsZero() {
+			p.time = time.Now()
+		}
+
+		if err := c.UseMeasurement(p.measurement).WritePointTagsFields(p.tags, p.fields, p.time); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+
+	fmt.Fprintf(stderr, "wrote %v points\n", count)
+	return err
+}
+
+// readJSON calls write for each point read from r.
+func readJSON(r io.Reader, precision string, write func(point) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		// numbers are decoded as json.Number, so nanosecond times keep
+		// their precision
+		var jp jsonPoint
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		if err := decoder.Decode(&jp); err != nil {
+			return fmt.Errorf("line %v: %v", line, err)
+		}
+
+		p := point{measurement: jp.Measurement, tags: jp.Tags, fields: jp.Fields}
+		if len(p.fields) == 0 {
+			return fmt.Errorf("line %v: no fields", line)
+		}
+
+		// JSON does not distinguish integers, so all numbers are written as
+		// floats
+		for k, v := range p.fields {
+			if n, ok := v.(json.Number); ok {
+				f, err := n.Float64()
+				if err != nil {
+					return fmt.Errorf("line %v: '%s': %v", line, k, err)
+				}
+				p.fields[k] = f
+			}
+		}
+
+		switch t := jp.Time.(type) {
+		case nil:
+		case string:
+			var err error
+			if p.time, err = parseTime(t, precision); err != nil {
+				return fmt.Errorf("line %v: %v", line, err)
+			}
+		case json.Number:
+			n, err := t.Int64()
+			if err != nil {
+				return fmt.Errorf("line %v: invalid time: %v", line, t)
+			}
+			p.time = epochTime(n, precision)
+		default:
+			return fmt.Errorf("line %v: invalid time: %v", line, t)
+		}
+
+		if err := write(p); err != nil {
+			return fmt.Errorf("line %v: %v", line, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// readCSV calls write for each point read from r. Columns in tags are
+// written as tags.
+func readCSV(r io.Reader, precision string, tags map[string]bool, write func(point) error) error {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		p := point{tags: make(map[string]string), fields: make(map[string]interface{})}
+		for i, value := range record {
+			column := header[i]
+			switch {
+			case value == "":
+			case column == "measurement":
+				p.measurement = value
+			case column == "time":
+				if p.time, err = parseTime(value, precision); err != nil {
+					return fmt.Errorf("line %v: %v", line, err)
+				}
+			case tags[column]:
+				p.tags[column] = value
+			default:
+				p.fields[column] = parseValue(value)
+			}
+		}
+
+		if len(p.fields) == 0 {
+			return fmt.Errorf("line %v: no fields", line)
+		}
+
+		if err := write(p); err != nil {
+			return fmt.Errorf("line %v: %v", line, err)
+		}
+	}
+}
+
+// parseValue parses a CSV field value as a float, a bool, or a string if it
+// is neither.
+func parseValue(s string) interface{} {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+	return s
+}
+
+// parseTime parses an RFC3339 time, or an integer in units of precision.
+func parseTime(s, precision string) (time.Time, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return epochTime(n, precision), nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, errors.New("invalid time: " + s)
+	}
+
+	return t, nil
+}
+
+// epochTime returns the time n units of precision after the Unix epoch.
+func epochTime(n int64, precision string) time.Time {
+	return time.Unix(0, n*int64(influxdbhelper.PrecisionDuration(precision))).UTC()
+}
@@ -0,0 +1,190 @@
+package influxdbhelper
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// coerceField converts a struct field value to the InfluxDb field type set
+// with a struct tag option, so the type written is independent of the Go
+// type.
+func coerceField(v reflect.Value, fieldType string) (interface{}, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	switch fieldType {
+	case fieldTypeInteger:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return v.Int(), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if v.Uint() > math.MaxInt64 {
+				return nil, fmt.Errorf("value %v overflows int", v.Uint())
+			}
+			return int64(v.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			f := v.Float()
+			if f != math.Trunc(f) || f > math.MaxInt64 || f < math.MinInt64 {
+				return nil, fmt.Errorf("value %v is not an integer", f)
+			}
+			return int64(f), nil
+		case reflect.Bool:
+			if v.Bool() {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case reflect.String:
+			return strconv.ParseInt(v.String(), 10, 64)
+		}
+	case fieldTypeUnsigned:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.Int() < 0 {
+				return nil, fmt.Errorf("value %v is negative", v.Int())
+			}
+			return uint64(v.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return v.Uint(), nil
+		case reflect.Float32, reflect.Float64:
+			f := v.Float()
+			if f != math.Trunc(f) || f < 0 || f > math.MaxUint64 {
+				return nil, fmt.Errorf("value %v is not an unsigned integer", f)
+			}
+			return uint64(f), nil
+		case reflect.Bool:
+			if v.Bool() {
+				return uint64(1), nil
+			}
+			return uint64(0), nil
+		case reflect.String:
+			return strconv.ParseUint(v.String(), 10, 64)
+		}
+	case fieldTypeFloat:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(v.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(v.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			return v.Float(), nil
+		case reflect.Bool:
+			if v.Bool() {
+				return 1.0, nil
+			}
+			return 0.0, nil
+		case reflect.String:
+			return strconv.ParseFloat(v.String(), 64)
+		}
+	case fieldTypeBoolean:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return v.Int() != 0, nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return v.Uint() != 0, nil
+		case reflect.Float32, reflect.Float64:
+			return v.Float() != 0, nil
+		case reflect.Bool:
+			return v.Bool(), nil
+		case reflect.String:
+			return strconv.ParseBool(v.String())
+		}
+	case fieldTypeString:
+		return fmt.Sprintf("%v", v.Interface()), nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %v to %v", v.Type(), fieldType)
+}
+
+// coerceColumns converts the values of columns decoded into struct fields
+// with a field type tag option to the Go type of the field, as the type
+// returned by InfluxDb may differ from the Go type.
+func coerceColumns(influxData []map[string]interface{}, result interface{}) error {
+	t := reflect.TypeOf(result)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	errors := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		fieldData := getInfluxFieldTagData(structField.Name, structField.Tag.Get("influx"))
+		if fieldData.fieldType == "" {
+			continue
+		}
+
+		for _, row := range influxData {
+			v, ok := row[fieldData.fieldName]
+			if !ok || v == nil {
+				continue
+			}
+
+			c, err := coerceValue(v, structField.Type)
+			if err != nil {
+				errors = appendErrors(errors,
+					fmt.Errorf("'%s': %v", fieldData.fieldName, err))
+				continue
+			}
+			row[fieldData.fieldName] = c
+		}
+	}
+
+	if len(errors) > 0 {
+		return &Error{errors}
+	}
+
+	return nil
+}
+
+// coerceValue converts a value returned by InfluxDb to the Go type t.
+func coerceValue(v interface{}, t reflect.Type) (interface{}, error) {
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			v = i
+		} else {
+			f, err := n.Float64()
+			if err != nil {
+				return nil, err
+			}
+			v = f
+		}
+	}
+
+	fieldType := ""
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fieldType = fieldTypeInteger
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		fieldType = fieldTypeUnsigned
+	case reflect.Float32, reflect.Float64:
+		fieldType = fieldTypeFloat
+	case reflect.Bool:
+		fieldType = fieldTypeBoolean
+	case reflect.String:
+		fieldType = fieldTypeString
+	default:
+		return v, nil
+	}
+
+	c, err := coerceField(reflect.ValueOf(v), fieldType)
+	if err != nil {
+		return nil, err
+	}
+
+	return reflect.ValueOf(c).Convert(t).Interface(), nil
+}
@@ -0,0 +1,133 @@
+package influxdbhelper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+func TestEncodeFieldType(t *testing.T) {
+	type Sample struct {
+		Count    int     `influx:"count,float"`
+		Ratio    float64 `influx:"ratio,int"`
+		Code     int     `influx:"code,string"`
+		Active   int     `influx:"active,bool"`
+		Total    int32   `influx:"total,unsigned"`
+		Level    string  `influx:"level,int"`
+		Untagged int     `influx:"untagged"`
+	}
+
+	_, _, fields, _, err := encode(Sample{3, 4, 200, 1, 7, "12", 5}, nil)
+	if err != nil {
+		t.Fatal("Error encoding: ", err)
+	}
+
+	expected := map[string]interface{}{
+		"count":    float64(3),
+		"ratio":    int64(4),
+		"code":     "200",
+		"active":   true,
+		"total":    uint64(7),
+		"level":    int64(12),
+		"untagged": 5,
+	}
+
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("%v != %v", fields, expected)
+	}
+}
+
+func TestEncodeFieldTypeError(t *testing.T) {
+	type Sample struct {
+		Ratio float64 `influx:"ratio,int"`
+		Total int     `influx:"total,unsigned"`
+	}
+
+	_, _, _, _, err := encode(Sample{4.5, -1}, nil)
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Expected *Error, got %v", err)
+	}
+
+	if len(e.Errors) != 2 {
+		t.Errorf("Unexpected errors: %v", e.Errors)
+	}
+}
+
+func TestDecodeFieldType(t *testing.T) {
+	type Sample struct {
+		Count  int     `influx:"count,float"`
+		Code   int     `influx:"code,string"`
+		Active int     `influx:"active,bool"`
+		Total  int32   `influx:"total,unsigned"`
+		Ratio  float64 `influx:"ratio,int"`
+	}
+
+	data := []map[string]interface{}{
+		{"count": 3.0, "code": "200", "active": true, "total": 7.0, "ratio": 4.0},
+	}
+
+	read := []Sample{}
+	if err := decodeRows(data, nil, &read, nil); err != nil {
+		t.Fatal("Error decoding: ", err)
+	}
+
+	expected := []Sample{{3, 200, 1, 7, 4}}
+	if !reflect.DeepEqual(read, expected) {
+		t.Errorf("%+v != %+v", read, expected)
+	}
+
+	data = []map[string]interface{}{{"code": "abc"}}
+	if err := decodeRows(data, nil, &read, nil); err == nil {
+		t.Error("Expected error decoding invalid value")
+	}
+}
+
+func TestClientFieldType(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("myDb")
+
+	c, err := NewClient(s.URL, "", "", "ns")
+	if err != nil {
+		t.Fatal("Error creating client: ", err)
+	}
+
+	type Sample struct {
+		Time  time.Time `influx:"time"`
+		Count int       `influx:"count,float"`
+		Total uint32    `influx:"total,unsigned"`
+		Code  int       `influx:"code,string"`
+	}
+
+	// a later point with a fractional float must not conflict
+	type FloatSample struct {
+		Time  time.Time `influx:"time"`
+		Count float64   `influx:"count"`
+	}
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	written := Sample{start, 3, 7, 404}
+
+	if err := c.UseDB("myDb").UseMeasurement("test").WritePoint(written); err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+
+	err = c.UseDB("myDb").UseMeasurement("test").
+		WritePoint(FloatSample{start.Add(time.Second), 2.5})
+	if err != nil {
+		t.Fatal("Error writing float point: ", err)
+	}
+
+	read := []Sample{}
+	err = c.UseDB("myDb").DecodeQuery(`SELECT count, total, code FROM test LIMIT 1`, &read)
+	if err != nil {
+		t.Fatal("Error decoding query: ", err)
+	}
+
+	if len(read) != 1 || !reflect.DeepEqual(read[0], written) {
+		t.Errorf("%+v != %+v", read, written)
+	}
+}
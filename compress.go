@@ -0,0 +1,85 @@
+package influxdbhelper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// gzipTransport compresses the bodies of requests, such as writes, with
+// gzip, and requests and decompresses gzipped responses.
+type gzipTransport struct {
+	next http.RoundTripper
+}
+
+func (t *gzipTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	// InfluxDb only decompresses written points, not the form body of
+	// queries
+	if req.Body != nil && req.Body != http.NoBody &&
+		req.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		var b bytes.Buffer
+		gz := gzip.NewWriter(&b)
+		_, err := io.Copy(gz, req.Body)
+		req.Body.Close()
+		if err == nil {
+			err = gz.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		body := b.Bytes()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+		req.ContentLength = int64(len(body))
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	// net/http only decompresses responses transparently if it added the
+	// header itself
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		resp.Body = &gzipReader{body: resp.Body}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}
+
+	return resp, nil
+}
+
+// gzipReader decompresses a response body. The gzip header is read on the
+// first Read, so empty bodies can be closed without error.
+type gzipReader struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+	err  error
+}
+
+func (r *gzipReader) Read(p []byte) (int, error) {
+	if r.zr == nil && r.err == nil {
+		r.zr, r.err = gzip.NewReader(r.body)
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.zr.Read(p)
+}
+
+func (r *gzipReader) Close() error {
+	return r.body.Close()
+}
@@ -0,0 +1,199 @@
+package influxdbhelper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+type gzipSample struct {
+	Time        time.Time `influx:"time"`
+	Location    string    `influx:"location,tag"`
+	Sensor      string    `influx:"sensor,tag"`
+	Temperature float64   `influx:"temperature"`
+	Humidity    float64   `influx:"humidity"`
+	State       string    `influx:"state"`
+}
+
+func gzipSamples(n int) []gzipSample {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	ret := make([]gzipSample, n)
+	for i := range ret {
+		ret[i] = gzipSample{
+			Time:        start.Add(time.Duration(i) * time.Second),
+			Location:    fmt.Sprintf("Rm %v", i%4),
+			Sensor:      "ambient",
+			Temperature: 70 + float64(i%10)/10,
+			Humidity:    40 + float64(i%7),
+			State:       "ok",
+		}
+	}
+	return ret
+}
+
+func TestClientGzip(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c, err := NewClientWithConfig(Config{URL: s.URL}, WithGzip(), WithDB("db"), WithMeasurement("env"))
+	if err != nil {
+		t.Fatal("Error creating client: ", err)
+	}
+
+	samples := gzipSamples(10)
+	if err := c.WritePoints(samples); err != nil {
+		t.Fatal("Error writing points: ", err)
+	}
+
+	var result []gzipSample
+	if err := c.UseDB("db").DecodeQuery("SELECT * FROM env", &result); err != nil {
+		t.Fatal("Error querying: ", err)
+	}
+
+	if len(result) != len(samples) || result[9].Humidity != samples[9].Humidity {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	requests := s.Requests()
+	write, query := requests[0], requests[1]
+
+	if write.Header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("Write not compressed: %v", write.Header)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(write.Body))
+	if err != nil {
+		t.Fatal("Error reading write body: ", err)
+	}
+	body, _ := ioutil.ReadAll(zr)
+	if !bytes.HasPrefix(body, []byte("env,location=Rm\\ 0,sensor=ambient ")) {
+		t.Errorf("Unexpected write body: %s", body)
+	}
+
+	if query.Header.Get("Accept-Encoding") != "gzip" {
+		t.Errorf("Query response not requested compressed: %v", query.Header)
+	}
+
+	if query.Header.Get("Content-Encoding") != "" || query.Query.Get("q") != "SELECT * FROM env" {
+		t.Errorf("Query form compressed: %v", query.Header)
+	}
+
+	// errors are not compressed by the server
+	s.FailNext(http.StatusBadRequest, "bad request")
+	if err := c.UseDB("db").DecodeQuery("SELECT * FROM env", &result); err == nil {
+		t.Error("Expected error")
+	}
+
+	if _, _, err := c.Ping(0); err != nil {
+		t.Error("Error pinging: ", err)
+	}
+}
+
+// countingConn counts the bytes read and written on a connection.
+type countingConn struct {
+	net.Conn
+	read, written *int64
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	atomic.AddInt64(c.read, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(c.written, int64(n))
+	return n, err
+}
+
+// countBytes makes the transport of c count the bytes sent and received.
+// Transparent compression of responses by net/http is disabled, so that
+// uncompressed responses are measured without gzip.
+func countBytes(c Client) (sent, received *int64) {
+	sent, received = new(int64), new(int64)
+
+	rt := c.(*helperClient).client.(*httpClient).httpClient.Transport
+	if gt, ok := rt.(*gzipTransport); ok {
+		rt = gt.next
+	}
+
+	tr := rt.(*http.Transport)
+	tr.DisableCompression = true
+	dialer := &net.Dialer{}
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &countingConn{conn, received, sent}, nil
+	}
+
+	return sent, received
+}
+
+// BenchmarkGzip reports the bytes sent by writes of 1000 points, and
+// received by queries of them, with and without gzip.
+func BenchmarkGzip(b *testing.B) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+	s.CreateDatabase("query")
+
+	samples := gzipSamples(1000)
+
+	c, err := NewClientWithConfig(Config{URL: s.URL}, WithDB("query"), WithMeasurement("env"))
+	if err != nil {
+		b.Fatal("Error creating client: ", err)
+	}
+	if err := c.WritePoints(samples); err != nil {
+		b.Fatal("Error writing points: ", err)
+	}
+	c.Close()
+
+	for _, compress := range []bool{false, true} {
+		c, err := NewClientWithConfig(Config{URL: s.URL, Gzip: compress}, WithDB("db"), WithMeasurement("env"))
+		if err != nil {
+			b.Fatal("Error creating client: ", err)
+		}
+		sent, received := countBytes(c)
+
+		name := "identity"
+		if compress {
+			name = "gzip"
+		}
+
+		b.Run("write/"+name, func(b *testing.B) {
+			atomic.StoreInt64(sent, 0)
+			for i := 0; i < b.N; i++ {
+				if err := c.WritePoints(samples); err != nil {
+					b.Fatal("Error writing points: ", err)
+				}
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(sent))/float64(b.N), "sent-bytes/op")
+		})
+
+		b.Run("query/"+name, func(b *testing.B) {
+			var result []gzipSample
+			atomic.StoreInt64(received, 0)
+			for i := 0; i < b.N; i++ {
+				if err := c.UseDB("query").DecodeQuery("SELECT * FROM env", &result); err != nil {
+					b.Fatal("Error querying: ", err)
+				}
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(received))/float64(b.N), "received-bytes/op")
+		})
+
+		c.Close()
+	}
+}
@@ -0,0 +1,420 @@
+package influxdbhelper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+// Config configures a client created with NewClientWithConfig.
+type Config struct {
+	// URL of the server, such as http://localhost:8086.
+	URL      string
+	Username string
+	Password string
+	// Precision of the times of written points: h, m, s, ms, u or ns. If
+	// empty, the server default of ns is used.
+	Precision string
+
+	// Timeout is the time limit of requests. 0 means no timeout.
+	Timeout time.Duration
+	// InsecureSkipVerify disables verification of the server certificate.
+	InsecureSkipVerify bool
+	// TLSConfig is the TLS configuration of https connections.
+	TLSConfig *tls.Config
+	// Proxy returns the proxy to use for a request, as for
+	// http.Transport. If nil, no proxy is used.
+	Proxy func(req *http.Request) (*url.URL, error)
+	// UserAgent is sent with each request. The default is InfluxDBClient.
+	UserAgent string
+	// Gzip compresses write bodies with gzip, and requests gzipped query
+	// responses, to reduce the bytes sent over slow or metered links.
+	// Without it, writes are sent uncompressed, and query responses are
+	// only gzipped by the transparent compression of net/http.
+	Gzip bool
+
+	// DB, RetentionPolicy and Measurement are the defaults of the client,
+	// as if set with UseDB, UseRetentionPolicy and UseMeasurement.
+	DB              string
+	RetentionPolicy string
+	Measurement     string
+
+	// rootCAs is the pool created by WithCACert, which can be added to
+	// without changing a pool set by the caller.
+	rootCAs *x509.CertPool
+}
+
+// Option modifies a Config.
+type Option func(c *Config) error
+
+// precisions are the valid values of Config.Precision.
+var precisions = map[string]bool{
+	"": true, "h": true, "m": true, "s": true, "ms": true, "u": true, "ns": true,
+}
+
+// Validate returns an error if the URL or precision of c is invalid.
+func (c *Config) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid url %v: must start with http:// or https://", c.URL)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("invalid url %v: no host", c.URL)
+	}
+
+	if !precisions[c.Precision] {
+		return fmt.Errorf("invalid precision %v: must be h, m, s, ms, u or ns", c.Precision)
+	}
+
+	return nil
+}
+
+// NewClientWithConfig returns a new client configured by config, modified
+// by options. An error is returned if the configuration is invalid.
+//
+//	c, err := NewClientWithConfig(Config{URL: "https://influx:8086"},
+//		WithCACertFile("/etc/ssl/influx-ca.pem"),
+//		WithTimeout(10*time.Second),
+//		WithDB("myDb"))
+func NewClientWithConfig(config Config, options ...Option) (Client, error) {
+	config, err := config.apply(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	client, err := config.newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return config.newHelperClient(client), nil
+}
+
+// apply returns a copy of c modified by options. The TLS configuration is
+// copied, so the TLS configuration of the caller is not changed.
+func (c Config) apply(options []Option) (Config, error) {
+	c.TLSConfig = c.TLSConfig.Clone()
+
+	for _, o := range options {
+		if err := o(&c); err != nil {
+			return Config{}, err
+		}
+	}
+
+	return c, nil
+}
+
+// newHTTPClient returns an http client for the server at c.URL.
+func (c *Config) newHTTPClient() (*httpClient, error) {
+	// InsecureSkipVerify is ignored by the http client if there is a TLS
+	// configuration
+	if c.TLSConfig != nil && c.InsecureSkipVerify {
+		c.TLSConfig.InsecureSkipVerify = true
+	}
+
+	client, err := newHTTPClient(influxClient.HTTPConfig{
+		Addr:               c.URL,
+		Username:           c.Username,
+		Password:           c.Password,
+		UserAgent:          c.UserAgent,
+		Timeout:            c.Timeout,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		TLSConfig:          c.TLSConfig,
+		Proxy:              c.Proxy,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Gzip {
+		client.httpClient.Transport = &gzipTransport{client.httpClient.Transport}
+	}
+
+	return client, nil
+}
+
+// newHelperClient returns a client of client, using the defaults of c.
+func (c *Config) newHelperClient(client influxClient.Client) *helperClient {
+	ret := &helperClient{
+		url:       c.URL,
+		client:    client,
+		precision: c.Precision,
+	}
+
+	if c.DB != "" {
+		ret.UseDB(c.DB)
+	}
+	if c.RetentionPolicy != "" {
+		ret.UseRetentionPolicy(c.RetentionPolicy)
+	}
+	if c.Measurement != "" {
+		ret.UseMeasurement(c.Measurement)
+	}
+
+	return ret
+}
+
+// WithCredentials sets the user name and password.
+func WithCredentials(username, password string) Option {
+	return func(c *Config) error {
+		c.Username, c.Password = username, password
+		return nil
+	}
+}
+
+// WithPrecision sets the precision of written points.
+func WithPrecision(precision string) Option {
+	return func(c *Config) error {
+		c.Precision = precision
+		return nil
+	}
+}
+
+// WithTimeout sets the time limit of requests.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Config) error {
+		c.Timeout = timeout
+		return nil
+	}
+}
+
+// WithUserAgent sets the user agent sent with each request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Config) error {
+		c.UserAgent = userAgent
+		return nil
+	}
+}
+
+// WithGzip compresses writes and query responses with gzip.
+func WithGzip() Option {
+	return func(c *Config) error {
+		c.Gzip = true
+		return nil
+	}
+}
+
+// WithProxy sets the proxy used for requests, such as
+// http.ProxyFromEnvironment.
+func WithProxy(proxy func(req *http.Request) (*url.URL, error)) Option {
+	return func(c *Config) error {
+		c.Proxy = proxy
+		return nil
+	}
+}
+
+// WithProxyURL sends all requests through the proxy at proxyURL.
+func WithProxyURL(proxyURL string) Option {
+	return func(c *Config) error {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy url: %v", err)
+		}
+		c.Proxy = http.ProxyURL(u)
+		return nil
+	}
+}
+
+// WithInsecureSkipVerify disables verification of the server certificate.
+// It should only be used for testing.
+func WithInsecureSkipVerify() Option {
+	return func(c *Config) error {
+		c.InsecureSkipVerify = true
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration. Options applied after it that
+// change the TLS configuration modify a copy of tlsConfig.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *Config) error {
+		c.TLSConfig = tlsConfig.Clone()
+		return nil
+	}
+}
+
+// tls returns the TLS configuration of c, creating it if needed.
+func (c *Config) tls() *tls.Config {
+	if c.TLSConfig == nil {
+		c.TLSConfig = &tls.Config{}
+	}
+	return c.TLSConfig
+}
+
+// WithCACert trusts the PEM encoded CA certificates in pem to verify the
+// server certificate, instead of the system CA certificates or the RootCAs
+// of a TLS configuration set with WithTLSConfig.
+func WithCACert(pem []byte) Option {
+	return func(c *Config) error {
+		t := c.tls()
+		if c.rootCAs == nil || t.RootCAs != c.rootCAs {
+			c.rootCAs = x509.NewCertPool()
+			t.RootCAs = c.rootCAs
+		}
+		if !t.RootCAs.AppendCertsFromPEM(pem) {
+			return errors.New("no CA certificates found")
+		}
+		return nil
+	}
+}
+
+// WithCACertFile trusts the PEM encoded CA certificates in a file, as for
+// WithCACert.
+func WithCACertFile(file string) Option {
+	return func(c *Config) error {
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := WithCACert(pem)(c); err != nil {
+			return fmt.Errorf("%v: %v", file, err)
+		}
+		return nil
+	}
+}
+
+// WithClientCertFile authenticates with the client certificate and key in
+// the PEM encoded certFile and keyFile.
+func WithClientCertFile(certFile, keyFile string) Option {
+	return func(c *Config) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		t := c.tls()
+		// the slice may be shared with a TLS configuration set by the
+		// caller
+		t.Certificates = append(t.Certificates[:len(t.Certificates):len(t.Certificates)], cert)
+		return nil
+	}
+}
+
+// WithDB sets the default database, as for UseDB.
+func WithDB(db string) Option {
+	return func(c *Config) error {
+		c.DB = db
+		return nil
+	}
+}
+
+// WithRetentionPolicy sets the default retention policy, as for
+// UseRetentionPolicy.
+func WithRetentionPolicy(rp string) Option {
+	return func(c *Config) error {
+		c.RetentionPolicy = rp
+		return nil
+	}
+}
+
+// WithMeasurement sets the default measurement, as for UseMeasurement.
+func WithMeasurement(measurement string) Option {
+	return func(c *Config) error {
+		c.Measurement = measurement
+		return nil
+	}
+}
+
+// ConfigFromEnv returns a Config read from these environment variables:
+//
+//	INFLUX_URL                   url (default http://localhost:8086)
+//	INFLUX_USER                  user name
+//	INFLUX_PASSWORD              password
+//	INFLUX_PRECISION             precision
+//	INFLUX_TIMEOUT               timeout, such as 10s
+//	INFLUX_INSECURE_SKIP_VERIFY  true to disable server certificate verification
+//	INFLUX_CA_CERT               file of CA certificates, as for WithCACertFile
+//	INFLUX_CLIENT_CERT           file of the client certificate, as for WithClientCertFile
+//	INFLUX_CLIENT_KEY            file of the client key
+//	INFLUX_PROXY                 proxy url
+//	INFLUX_USER_AGENT            user agent
+//	INFLUX_GZIP                  true to compress writes and query responses
+//	INFLUX_DB                    default database
+//	INFLUX_RP                    default retention policy
+//	INFLUX_MEASUREMENT           default measurement
+//
+// The configuration is validated, and options can be applied to it.
+func ConfigFromEnv(options ...Option) (Config, error) {
+	c := Config{
+		URL:             os.Getenv("INFLUX_URL"),
+		Username:        os.Getenv("INFLUX_USER"),
+		Password:        os.Getenv("INFLUX_PASSWORD"),
+		Precision:       os.Getenv("INFLUX_PRECISION"),
+		UserAgent:       os.Getenv("INFLUX_USER_AGENT"),
+		DB:              os.Getenv("INFLUX_DB"),
+		RetentionPolicy: os.Getenv("INFLUX_RP"),
+		Measurement:     os.Getenv("INFLUX_MEASUREMENT"),
+	}
+
+	if c.URL == "" {
+		c.URL = "http://localhost:8086"
+	}
+
+	var envOptions []Option
+
+	if v := os.Getenv("INFLUX_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("INFLUX_TIMEOUT: %v", err)
+		}
+		envOptions = append(envOptions, WithTimeout(timeout))
+	}
+
+	if v := os.Getenv("INFLUX_INSECURE_SKIP_VERIFY"); v != "" {
+		skip, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("INFLUX_INSECURE_SKIP_VERIFY: %v", err)
+		}
+		c.InsecureSkipVerify = skip
+	}
+
+	if v := os.Getenv("INFLUX_GZIP"); v != "" {
+		gzip, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("INFLUX_GZIP: %v", err)
+		}
+		c.Gzip = gzip
+	}
+
+	if v := os.Getenv("INFLUX_CA_CERT"); v != "" {
+		envOptions = append(envOptions, WithCACertFile(v))
+	}
+
+	certFile, keyFile := os.Getenv("INFLUX_CLIENT_CERT"), os.Getenv("INFLUX_CLIENT_KEY")
+	if certFile != "" || keyFile != "" {
+		envOptions = append(envOptions, WithClientCertFile(certFile, keyFile))
+	}
+
+	if v := os.Getenv("INFLUX_PROXY"); v != "" {
+		envOptions = append(envOptions, WithProxyURL(v))
+	}
+
+	for _, o := range append(envOptions, options...) {
+		if err := o(&c); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return c, nil
+}
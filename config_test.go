@@ -0,0 +1,245 @@
+package influxdbhelper
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+func TestNewClientWithConfig(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c, err := NewClientWithConfig(Config{URL: s.URL, Precision: "s"},
+		WithDB("db"), WithRetentionPolicy("autogen"), WithMeasurement("env"),
+		WithUserAgent("test-agent"), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal("Error creating client: ", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err := c.WritePointTagsFields(nil, map[string]interface{}{"value": 1.0}, time.Now())
+		if err != nil {
+			t.Fatal("Error writing point: ", err)
+		}
+	}
+
+	requests := s.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("%v != 2", len(requests))
+	}
+
+	r := requests[1]
+	if r.Query.Get("db") != "db" || r.Query.Get("rp") != "autogen" || r.Query.Get("precision") != "s" {
+		t.Errorf("Unexpected query: %v", r.Query)
+	}
+
+	if ua := r.Header.Get("User-Agent"); ua != "test-agent" {
+		t.Errorf("%v != test-agent", ua)
+	}
+
+	if n := len(s.Points("db")); n != 2 {
+		t.Errorf("%v != 2", n)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	invalid := []Config{
+		{URL: "localhost:8086"},
+		{URL: "udp://localhost:8089"},
+		{URL: "http://"},
+		{URL: "http://localhost:8086", Precision: "us"},
+	}
+
+	for _, c := range invalid {
+		if _, err := NewClientWithConfig(c); err == nil {
+			t.Errorf("Expected error for %+v", c)
+		}
+	}
+
+	if _, err := NewClientWithConfig(Config{URL: "https://localhost:8086", Precision: "ms"}); err != nil {
+		t.Error("Unexpected error: ", err)
+	}
+
+	if _, err := NewClientWithConfig(Config{URL: "http://localhost:8086"}, WithPrecision("x")); err == nil {
+		t.Error("Expected error for option precision")
+	}
+}
+
+// writeCert generates a self signed certificate and key, and writes them
+// to files in dir.
+func writeCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("Error generating key: ", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal("Error creating certificate: ", err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal("Error marshaling key: ", err)
+	}
+
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client-key.pem")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+
+	return certFile, keyFile
+}
+
+func TestConfigTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "influxdbhelper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("X-Influxdb-Version", "tls")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	s.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	s.StartTLS()
+	defer s.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}), 0600)
+	certFile, keyFile := writeCert(t, dir)
+
+	ping := func(options ...Option) error {
+		c, err := NewClientWithConfig(Config{URL: s.URL}, options...)
+		if err != nil {
+			return err
+		}
+		_, _, err = c.Ping(0)
+		return err
+	}
+
+	// the server certificate is not trusted
+	if err := ping(WithClientCertFile(certFile, keyFile)); err == nil {
+		t.Error("Expected certificate error")
+	}
+
+	// no client certificate
+	if err := ping(WithCACertFile(caFile)); err == nil {
+		t.Error("Expected unauthorized error")
+	}
+
+	if err := ping(WithCACertFile(caFile), WithClientCertFile(certFile, keyFile)); err != nil {
+		t.Error("Error pinging: ", err)
+	}
+
+	if err := ping(WithInsecureSkipVerify(), WithClientCertFile(certFile, keyFile)); err != nil {
+		t.Error("Error pinging: ", err)
+	}
+
+	// the TLS configuration passed is not modified
+	pool := x509.NewCertPool()
+	tlsConfig := &tls.Config{RootCAs: pool, Certificates: make([]tls.Certificate, 0, 1)}
+	if err := ping(WithTLSConfig(tlsConfig), WithCACertFile(caFile), WithClientCertFile(certFile, keyFile)); err != nil {
+		t.Error("Error pinging: ", err)
+	}
+	if tlsConfig.RootCAs != pool || len(pool.Subjects()) != 0 || len(tlsConfig.Certificates[:1][0].Certificate) != 0 {
+		t.Error("TLS configuration was modified")
+	}
+
+	if err := ping(WithCACertFile(certFile + ".missing")); err == nil {
+		t.Error("Expected missing file error")
+	}
+
+	if err := ping(WithCACert([]byte("not a certificate"))); err == nil {
+		t.Error("Expected invalid certificate error")
+	}
+}
+
+func setEnv(env map[string]string) func() {
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	return func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	restore := setEnv(map[string]string{
+		"INFLUX_URL":                  "https://influx:8086",
+		"INFLUX_USER":                 "admin",
+		"INFLUX_PASSWORD":             "secret",
+		"INFLUX_PRECISION":            "ms",
+		"INFLUX_TIMEOUT":              "5s",
+		"INFLUX_INSECURE_SKIP_VERIFY": "true",
+		"INFLUX_DB":                   "db",
+		"INFLUX_RP":                   "week",
+		"INFLUX_PROXY":                "http://proxy:3128",
+	})
+	defer restore()
+
+	c, err := ConfigFromEnv(WithMeasurement("env"))
+	if err != nil {
+		t.Fatal("Error reading config: ", err)
+	}
+
+	if c.URL != "https://influx:8086" || c.Username != "admin" || c.Password != "secret" ||
+		c.Precision != "ms" || c.Timeout != 5*time.Second || !c.InsecureSkipVerify ||
+		c.DB != "db" || c.RetentionPolicy != "week" || c.Measurement != "env" {
+		t.Errorf("Unexpected config: %+v", c)
+	}
+
+	req, _ := http.NewRequest("GET", c.URL, nil)
+	if proxy, err := c.Proxy(req); err != nil || proxy.Host != "proxy:3128" {
+		t.Errorf("Unexpected proxy: %v, %v", proxy, err)
+	}
+
+	os.Setenv("INFLUX_TIMEOUT", "soon")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("Expected timeout error")
+	}
+	os.Setenv("INFLUX_TIMEOUT", "5s")
+
+	os.Setenv("INFLUX_PRECISION", "days")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("Expected precision error")
+	}
+	os.Unsetenv("INFLUX_PRECISION")
+
+	restore()
+	c, err = ConfigFromEnv()
+	if err != nil || c.URL != "http://localhost:8086" {
+		t.Errorf("Unexpected default config: %+v, %v", c, err)
+	}
+}
@@ -0,0 +1,233 @@
+package influxdbhelper
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ContinuousQuery is a continuous query defined on a database.
+type ContinuousQuery struct {
+	Name string
+	// Query is the CREATE CONTINUOUS QUERY statement.
+	Query string
+}
+
+// CreateContinuousQuery creates cq on db if no continuous query with the
+// same name exists. As continuous queries can not be altered, drop the
+// existing continuous query first to change it.
+func (c *helperClient) CreateContinuousQuery(db string, cq ContinuousQuery) error {
+	existing, err := c.ListContinuousQueries(db)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range existing {
+		if e.Name == cq.Name {
+			return nil
+		}
+	}
+
+	_, err = c.execute(db, cq.Query)
+	return err
+}
+
+// ListContinuousQueries returns the continuous queries defined on db.
+func (c *helperClient) ListContinuousQueries(db string) ([]ContinuousQuery, error) {
+	rows, err := c.execute(db, "SHOW CONTINUOUS QUERIES")
+	if err != nil {
+		return nil, err
+	}
+
+	// the continuous queries of each database are returned as a series
+	// named after the database
+	var databases []struct {
+		Name string
+		Rows []struct {
+			Name  string `influx:"name"`
+			Query string `influx:"query"`
+		} `influx:",rows"`
+	}
+
+	if err := decode(rows, &databases); err != nil {
+		return nil, &DecodeError{err}
+	}
+
+	ret := []ContinuousQuery{}
+	for _, d := range databases {
+		if d.Name != db {
+			continue
+		}
+		for _, r := range d.Rows {
+			ret = append(ret, ContinuousQuery{Name: r.Name, Query: r.Query})
+		}
+	}
+
+	return ret, nil
+}
+
+// DropContinuousQuery drops the continuous query name from db, if it
+// exists.
+func (c *helperClient) DropContinuousQuery(db, name string) error {
+	_, err := c.execute(db, "DROP CONTINUOUS QUERY "+QuoteIdent(name)+" ON "+QuoteIdent(db))
+
+	var qErr *QueryError
+	if errors.As(err, &qErr) && strings.Contains(qErr.Message, "continuous query not found") {
+		return nil
+	}
+
+	return err
+}
+
+type structFields struct {
+	measurement string
+	fields      map[string]bool
+	tags        map[string]bool
+}
+
+func getStructFields(v interface{}) (*structFields, reflect.Type, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, nil, errors.New("data must be a struct")
+	}
+
+	ret := &structFields{
+		measurement: typeMeasurement(reflect.New(t).Elem(), nil),
+		fields:      make(map[string]bool),
+		tags:        make(map[string]bool),
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if structField.PkgPath != "" || structField.Name == "InfluxMeasurement" {
+			continue
+		}
+
+		fieldData := getInfluxFieldTagData(structField.Name, structField.Tag.Get("influx"))
+		if fieldData.fieldName == "-" || fieldData.fieldName == "time" ||
+			fieldData.isTagMap || fieldData.isFieldMap {
+			continue
+		}
+
+		if fieldData.isTag {
+			ret.tags[fieldData.fieldName] = true
+		}
+		if fieldData.isField {
+			ret.fields[fieldData.fieldName] = true
+		}
+	}
+
+	return ret, t, nil
+}
+
+// RollupQuery generates a continuous query named name on db that
+// downsamples the measurement of the raw struct type into the measurement
+// of the rollup struct type, aggregating over interval. The measurements
+// are determined from the types in the same way as WritePoint.
+//
+// Each field of rollup is the mean of the raw field with the same name,
+// unless a different aggregate is set with the rollup option, either as a
+// function name, or a function call on a raw field:
+//
+//	type env struct {
+//		Time        time.Time `influx:"time"`
+//		Location    string    `influx:"location,tag"`
+//		Temperature float64   `influx:"temperature"`
+//	}
+//
+//	type envHourly struct {
+//		_           struct{}  `influx:"measurement=env_1h"`
+//		Time        time.Time `influx:"time"`
+//		Location    string    `influx:"location,tag"`
+//		Temperature float64   `influx:"temperature"`
+//		MaxTemp     float64   `influx:"max_temp,rollup=max(temperature)"`
+//		Samples     int64     `influx:"samples,rollup=count(temperature)"`
+//		P95Temp     float64   `influx:"p95_temp,rollup=percentile(temperature,95)"`
+//	}
+//
+//	cq, _ := RollupQuery("myDb", "env_1h", env{}, envHourly{}, time.Hour)
+//	// CREATE CONTINUOUS QUERY "env_1h" ON "myDb" BEGIN SELECT
+//	// mean("temperature") AS "temperature", max("temperature") AS "max_temp",
+//	// count("temperature") AS "samples", percentile("temperature", 95) AS
+//	// "p95_temp" INTO "env_1h" FROM "env"
+//	// GROUP BY time(1h), * END
+//
+// All tags are kept, so the rollup has the same series as the raw data.
+func RollupQuery(db, name string, raw, rollup interface{}, interval time.Duration) (ContinuousQuery, error) {
+	if interval <= 0 {
+		return ContinuousQuery{}, errors.New("interval must be positive")
+	}
+
+	rawFields, _, err := getStructFields(raw)
+	if err != nil {
+		return ContinuousQuery{}, err
+	}
+
+	rollupFields, rollupType, err := getStructFields(rollup)
+	if err != nil {
+		return ContinuousQuery{}, err
+	}
+
+	errs := []string{}
+	var selects []string
+
+	for i := 0; i < rollupType.NumField(); i++ {
+		structField := rollupType.Field(i)
+		fieldData := getInfluxFieldTagData(structField.Name, structField.Tag.Get("influx"))
+		if !rollupFields.fields[fieldData.fieldName] || fieldData.isTag {
+			continue
+		}
+
+		// args are the arguments of the function after the source field,
+		// such as the N of percentile(field, N)
+		function, source := "mean", fieldData.fieldName
+		var args []string
+		if agg := fieldData.rollup; agg != "" {
+			function = agg
+			if m := reFunctionCall.FindStringSubmatch(agg); m != nil {
+				function, args = m[1], splitTopLevel(m[2], ',')
+				source, args = strings.TrimSpace(args[0]), args[1:]
+				for i := range args {
+					args[i] = strings.TrimSpace(args[i])
+				}
+			}
+		}
+
+		if !rawFields.fields[source] {
+			errs = appendErrors(errs,
+				fmt.Errorf("'%s': raw type has no field '%s'", fieldData.fieldName, source))
+			continue
+		}
+
+		args = append([]string{QuoteIdent(source)}, args...)
+		selects = append(selects, fmt.Sprintf("%s(%s) AS %s",
+			function, strings.Join(args, ", "), QuoteIdent(fieldData.fieldName)))
+	}
+
+	for tag := range rollupFields.tags {
+		if !rawFields.tags[tag] {
+			errs = appendErrors(errs, fmt.Errorf("'%s': raw type has no tag '%s'", tag, tag))
+		}
+	}
+
+	if len(errs) > 0 {
+		return ContinuousQuery{}, &Error{errs}
+	}
+
+	if len(selects) == 0 {
+		return ContinuousQuery{}, errors.New("no fields to select")
+	}
+
+	q := fmt.Sprintf("CREATE CONTINUOUS QUERY %s ON %s BEGIN SELECT %s INTO %s FROM %s GROUP BY time(%s), * END",
+		QuoteIdent(name), QuoteIdent(db), strings.Join(selects, ", "),
+		QuoteIdent(rollupFields.measurement), QuoteIdent(rawFields.measurement),
+		formatDuration(interval))
+
+	return ContinuousQuery{Name: name, Query: q}, nil
+}
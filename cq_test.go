@@ -0,0 +1,125 @@
+package influxdbhelper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+type cqRaw struct {
+	InfluxMeasurement Measurement
+	Time              time.Time `influx:"time"`
+	Location          string    `influx:"location,tag"`
+	Temperature       float64   `influx:"temperature"`
+	Humidity          float64   `influx:"humidity"`
+}
+
+type cqHourly struct {
+	_           struct{}  `influx:"measurement=env_1h"`
+	Time        time.Time `influx:"time"`
+	Location    string    `influx:"location,tag"`
+	Temperature float64   `influx:"temperature"`
+	MaxTemp     float64   `influx:"max_temp,rollup=max(temperature)"`
+	Humidity    float64   `influx:"humidity,rollup=median"`
+	ID          string    `influx:"-"`
+}
+
+func TestRollupQuery(t *testing.T) {
+	cq, err := RollupQuery("myDb", "env_1h", &cqRaw{}, cqHourly{}, 90*time.Minute)
+	if err != nil {
+		t.Fatal("Error generating query: ", err)
+	}
+
+	expected := `CREATE CONTINUOUS QUERY "env_1h" ON "myDb" BEGIN SELECT mean("temperature") AS "temperature", ` +
+		`max("temperature") AS "max_temp", median("humidity") AS "humidity" INTO "env_1h" FROM "cqRaw" ` +
+		`GROUP BY time(90m), * END`
+
+	if cq.Name != "env_1h" || cq.Query != expected {
+		t.Errorf("%v != %v", cq.Query, expected)
+	}
+}
+
+func TestRollupQueryAggregateArgs(t *testing.T) {
+	type Rollup struct {
+		Time    time.Time `influx:"time"`
+		P95Temp float64   `influx:"p95_temp,rollup=percentile(temperature,95)"`
+		TopHum  float64   `influx:"top_hum,rollup=top( humidity , 3 )"`
+	}
+
+	cq, err := RollupQuery("myDb", "cq", cqRaw{}, Rollup{}, time.Hour)
+	if err != nil {
+		t.Fatal("Error generating query: ", err)
+	}
+
+	expected := `CREATE CONTINUOUS QUERY "cq" ON "myDb" BEGIN SELECT percentile("temperature", 95) AS "p95_temp", ` +
+		`top("humidity", 3) AS "top_hum" INTO "Rollup" FROM "cqRaw" GROUP BY time(1h), * END`
+
+	if cq.Query != expected {
+		t.Errorf("%v != %v", cq.Query, expected)
+	}
+}
+
+func TestRollupQueryErrors(t *testing.T) {
+	type Rollup struct {
+		Time     time.Time `influx:"time"`
+		Sensor   string    `influx:"sensor,tag"`
+		Pressure float64   `influx:"pressure"`
+		Max      float64   `influx:"max,rollup=max(dewpoint)"`
+	}
+
+	_, err := RollupQuery("myDb", "cq", cqRaw{}, Rollup{}, time.Hour)
+	e, ok := err.(*Error)
+	if !ok || len(e.Errors) != 3 {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if _, err := RollupQuery("myDb", "cq", cqRaw{}, cqHourly{}, 0); err == nil {
+		t.Error("Expected error for zero interval")
+	}
+}
+
+func TestContinuousQueryAdmin(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+	s.CreateDatabase("other")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+
+	cq, err := RollupQuery("db", "env_1h", cqRaw{}, cqHourly{}, time.Hour)
+	if err != nil {
+		t.Fatal("Error generating query: ", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.CreateContinuousQuery("db", cq); err != nil {
+			t.Fatal("Error creating continuous query: ", err)
+		}
+	}
+
+	cqs, err := c.ListContinuousQueries("db")
+	if err != nil {
+		t.Fatal("Error listing continuous queries: ", err)
+	}
+	if !reflect.DeepEqual(cqs, []ContinuousQuery{cq}) {
+		t.Errorf("%+v != %+v", cqs, cq)
+	}
+
+	cqs, err = c.ListContinuousQueries("other")
+	if err != nil || len(cqs) != 0 {
+		t.Errorf("Unexpected continuous queries: %v, %v", cqs, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.DropContinuousQuery("db", "env_1h"); err != nil {
+			t.Fatal("Error dropping continuous query: ", err)
+		}
+	}
+
+	cqs, err = c.ListContinuousQueries("db")
+	if err != nil || len(cqs) != 0 {
+		t.Errorf("Unexpected continuous queries: %v, %v", cqs, err)
+	}
+}
@@ -1,39 +1,126 @@
 package influxdbhelper
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	influxModels "github.com/influxdata/influxdb1-client/models"
 	"github.com/mitchellh/mapstructure"
 )
 
+// TagSetKey returns the key used for a series when decoding into a map. It
+// contains the tags sorted by key in the form: key1=value1,key2=value2
+// Commas, equals signs and backslashes in keys and values are escaped with a
+// backslash, so different tag sets have different keys.
+func TagSetKey(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = tagSetEscaper.Replace(k) + "=" + tagSetEscaper.Replace(tags[k])
+	}
+
+	return strings.Join(parts, ",")
+}
+
+var tagSetEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`)
+
 // Decode is used to process data returned by an InfluxDb query and uses reflection
 // to transform it into an array of structs of type result.
 //
+// result can also be a map of slices, in which case the rows of each series
+// are stored under the key returned by TagSetKey for the series tags, or a
+// slice of series structs. A series struct has a slice field tagged with
+// the rows option, for example `influx:",rows"`, which the rows of the series
+// are decoded into, and optionally a Name string field and a Tags
+// map[string]string field.
+//
 // This function is used internally by the Query function.
 func decode(influxResult []influxModels.Row, result interface{}) error {
-	influxData := make([]map[string]interface{}, 0)
+	return decodeWithState(influxResult, result, nil)
+}
 
+// decodeWithState decodes in the same way as decode, collecting metadata in
+// state if it is not nil.
+func decodeWithState(influxResult []influxModels.Row, result interface{}, state *decodeState) error {
+	v := reflect.ValueOf(result)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		e := v.Elem()
+		switch {
+		case e.Kind() == reflect.Map && e.Type().Key().Kind() == reflect.String &&
+			e.Type().Elem().Kind() == reflect.Slice:
+			return decodeMap(influxResult, e, state)
+		case e.Kind() == reflect.Slice && seriesRowsField(e.Type().Elem()) >= 0:
+			return decodeSeries(influxResult, e, state)
+		}
+	}
+
+	influxData := make([]map[string]interface{}, 0)
+	tags := make([]map[string]string, 0)
 	for _, series := range influxResult {
-		for _, v := range series.Values {
-			r := make(map[string]interface{})
-			for i, c := range series.Columns {
-				if len(v) >= i+1 {
-					r[c] = v[i]
-				}
-			}
-			for tag, val := range series.Tags {
-				r[tag] = val
-			}
-			r["InfluxMeasurement"] = series.Name
+		influxData = append(influxData, flattenSeries(series)...)
+		tags = append(tags, seriesRowTags(series)...)
+	}
 
-			influxData = append(influxData, r)
+	return decodeRows(influxData, tags, result, state)
+}
+
+// flattenSeries converts the values of a series into maps of column name to
+// value, including the series tags and measurement name.
+func flattenSeries(series influxModels.Row) []map[string]interface{} {
+	ret := make([]map[string]interface{}, 0, len(series.Values))
+
+	for _, v := range series.Values {
+		r := make(map[string]interface{})
+		for i, c := range series.Columns {
+			if len(v) >= i+1 {
+				r[c] = v[i]
+			}
 		}
+		for tag, val := range series.Tags {
+			r[tag] = val
+		}
+		r["InfluxMeasurement"] = series.Name
+
+		ret = append(ret, r)
+	}
+
+	return ret
+}
+
+// seriesRowTags returns the tags of the series for each of its rows.
+func seriesRowTags(series influxModels.Row) []map[string]string {
+	ret := make([]map[string]string, len(series.Values))
+	for i := range ret {
+		ret[i] = series.Tags
+	}
+	return ret
+}
+
+// decodeRows decodes rows of column name to value into result. tags
+// contains the series tags of each row, which are also present as columns,
+// and may be nil if unknown. If state is not nil, metadata about the decode
+// is added to it.
+func decodeRows(influxData []map[string]interface{}, tags []map[string]string, result interface{}, state *decodeState) error {
+	if err := coerceColumns(influxData, result); err != nil {
+		return err
+	}
+
+	var metadata *mapstructure.Metadata
+	if state != nil {
+		metadata = &mapstructure.Metadata{}
 	}
 
 	config := &mapstructure.DecoderConfig{
-		Metadata:         nil,
+		Metadata:         metadata,
 		Result:           result,
 		TagName:          "influx",
 		WeaklyTypedInput: false,
@@ -52,5 +139,189 @@ func decode(influxResult []influxModels.Row, result interface{}) error {
 		return err
 	}
 
-	return decoder.Decode(influxData)
+	if err := decoder.Decode(influxData); err != nil {
+		return err
+	}
+
+	if err := decodeCommaFields(influxData, result, config); err != nil {
+		return err
+	}
+
+	captured, err := decodeDynamic(influxData, tags, result)
+	if err != nil {
+		return err
+	}
+
+	if state != nil {
+		state.add(influxData, tags, result, metadata, captured)
+	}
+
+	return nil
+}
+
+// decodeCommaFields decodes the columns of the struct fields whose influx
+// name contains a comma, such as percentile(humidity,95), which mapstructure
+// does not match as it ends the name at the first comma of the tag. It uses
+// the decode hook and metadata of config.
+func decodeCommaFields(influxData []map[string]interface{}, result interface{}, config *mapstructure.DecoderConfig) error {
+	v := reflect.ValueOf(result)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		return nil
+	}
+
+	t := v.Type().Elem()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		fieldData := getInfluxFieldTagData(t.Field(i).Name, t.Field(i).Tag.Get("influx"))
+		if t.Field(i).PkgPath == "" && strings.Contains(fieldData.fieldName, ",") {
+			fields[fieldData.fieldName] = i
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	used := make(map[string]bool)
+	for i, row := range influxData {
+		if i >= v.Len() {
+			break
+		}
+
+		e := v.Index(i)
+		for e.Kind() == reflect.Ptr && !e.IsNil() {
+			e = e.Elem()
+		}
+		if e.Kind() != reflect.Struct {
+			continue
+		}
+
+		for name, index := range fields {
+			value, ok := row[name]
+			if !ok {
+				continue
+			}
+
+			decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+				Result:     e.Field(index).Addr().Interface(),
+				DecodeHook: config.DecodeHook,
+			})
+			if err != nil {
+				return err
+			}
+			if err := decoder.Decode(value); err != nil {
+				return fmt.Errorf("'[%v].%v': %v", i, name, err)
+			}
+			used[name] = true
+		}
+	}
+
+	if metadata := config.Metadata; metadata != nil {
+		unused := metadata.Unused[:0]
+		for _, key := range metadata.Unused {
+			if k, ok := metadataKey(key); !ok || !used[k] {
+				unused = append(unused, key)
+			}
+		}
+		metadata.Unused = unused
+
+		for name := range used {
+			metadata.Keys = append(metadata.Keys, name)
+		}
+	}
+
+	return nil
+}
+
+// decodeMap decodes each series into a slice stored in m under the tag set
+// key of the series.
+func decodeMap(influxResult []influxModels.Row, m reflect.Value, state *decodeState) error {
+	if m.IsNil() {
+		m.Set(reflect.MakeMap(m.Type()))
+	}
+
+	for _, series := range influxResult {
+		rows := reflect.New(m.Type().Elem())
+		if err := decodeRows(flattenSeries(series), seriesRowTags(series), rows.Interface(), state); err != nil {
+			return err
+		}
+
+		key := reflect.ValueOf(TagSetKey(series.Tags)).Convert(m.Type().Key())
+		existing := m.MapIndex(key)
+		if existing.IsValid() {
+			rows.Elem().Set(reflect.AppendSlice(existing, rows.Elem()))
+		}
+		m.SetMapIndex(key, rows.Elem())
+	}
+
+	return nil
+}
+
+var (
+	stringType = reflect.TypeOf("")
+	tagsType   = reflect.TypeOf(map[string]string{})
+)
+
+// seriesRowsField returns the index of the slice field of the struct type t
+// tagged with the rows option, which marks t as a series struct, or -1.
+func seriesRowsField(t reflect.Type) int {
+	if t.Kind() != reflect.Struct {
+		return -1
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type.Kind() != reflect.Slice {
+			continue
+		}
+		for _, part := range splitTopLevel(f.Tag.Get("influx"), ',')[1:] {
+			if part == "rows" {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// decodeSeries decodes each series into a new series struct in the slice s.
+func decodeSeries(influxResult []influxModels.Row, s reflect.Value, state *decodeState) error {
+	seriesType := s.Type().Elem()
+	rowsField := seriesRowsField(seriesType)
+	ret := reflect.MakeSlice(s.Type(), 0, len(influxResult))
+
+	for _, series := range influxResult {
+		v := reflect.New(seriesType).Elem()
+
+		if f := v.FieldByName("Name"); f.CanSet() && f.Type().ConvertibleTo(stringType) {
+			f.Set(reflect.ValueOf(series.Name).Convert(f.Type()))
+		}
+
+		if f := v.FieldByName("Tags"); f.CanSet() && f.Type() == tagsType {
+			f.Set(reflect.ValueOf(series.Tags))
+		}
+
+		rows := v.Field(rowsField)
+		if !rows.CanSet() {
+			return errors.New("series rows field must be exported")
+		}
+
+		if err := decodeRows(flattenSeries(series), seriesRowTags(series), rows.Addr().Interface(), state); err != nil {
+			return err
+		}
+
+		ret = reflect.Append(ret, v)
+	}
+
+	s.Set(ret)
+	return nil
 }
@@ -257,3 +257,119 @@ func TestDecodeMeasure(t *testing.T) {
 		t.Error("decoded value is not right")
 	}
 }
+
+func groupByTestData() []influxModels.Row {
+	return []influxModels.Row{
+		{
+			Name:    "bla",
+			Columns: []string{"time", "value"},
+			Tags:    map[string]string{"location": "Rm 243"},
+			Values: [][]interface{}{
+				{"2018-06-14T21:47:11Z", 1.0},
+				{"2018-06-14T21:47:12Z", 2.0},
+			},
+		},
+		{
+			Name:    "bla",
+			Columns: []string{"time", "value"},
+			Tags:    map[string]string{"location": "Rm 244"},
+			Values: [][]interface{}{
+				{"2018-06-14T21:47:11Z", 3.0},
+			},
+		},
+	}
+}
+
+func TestDecodeGroupByMap(t *testing.T) {
+	type DecodeType struct {
+		Location string  `influx:"location,tag"`
+		Value    float64 `influx:"value"`
+	}
+
+	decoded := map[string][]DecodeType{}
+	err := decode(groupByTestData(), &decoded)
+	if err != nil {
+		t.Error("Error decoding: ", err)
+	}
+
+	expected := map[string][]DecodeType{
+		"location=Rm 243": {{"Rm 243", 1.0}, {"Rm 243", 2.0}},
+		"location=Rm 244": {{"Rm 244", 3.0}},
+	}
+
+	if !reflect.DeepEqual(expected, decoded) {
+		t.Error("decoded value is not right", expected, decoded)
+	}
+}
+
+func TestDecodeGroupBySeries(t *testing.T) {
+	type DecodeType struct {
+		Time  time.Time `influx:"time"`
+		Value float64   `influx:"value"`
+	}
+
+	type Series struct {
+		Name string
+		Tags map[string]string
+		Rows []DecodeType `influx:",rows"`
+	}
+
+	decoded := []Series{}
+	err := decode(groupByTestData(), &decoded)
+	if err != nil {
+		t.Error("Error decoding: ", err)
+	}
+
+	if len(decoded) != 2 {
+		t.Fatal("Expected 2 series, got: ", len(decoded))
+	}
+
+	if decoded[0].Name != "bla" || decoded[0].Tags["location"] != "Rm 243" ||
+		len(decoded[0].Rows) != 2 || decoded[0].Rows[1].Value != 2.0 {
+		t.Error("decoded value is not right", decoded[0])
+	}
+
+	if decoded[1].Tags["location"] != "Rm 244" || len(decoded[1].Rows) != 1 {
+		t.Error("decoded value is not right", decoded[1])
+	}
+}
+
+func TestTagSetKey(t *testing.T) {
+	key := TagSetKey(map[string]string{"b": "2", "a": "1"})
+	if key != "a=1,b=2" {
+		t.Errorf("%v != %v", key, "a=1,b=2")
+	}
+}
+
+func TestTagSetKeyEscape(t *testing.T) {
+	a := TagSetKey(map[string]string{"a": "1,b=2"})
+	b := TagSetKey(map[string]string{"a": "1", "b": "2"})
+	if a == b {
+		t.Errorf("%v == %v", a, b)
+	}
+
+	exp := `a=1\,b\=2,c\\=3`
+	key := TagSetKey(map[string]string{"a": "1,b=2", `c\`: "3"})
+	if key != exp {
+		t.Errorf("%v != %v", key, exp)
+	}
+}
+
+func TestDecodeRowsSliceNotSeries(t *testing.T) {
+	// a struct with a Rows field is decoded as a row unless the field is
+	// tagged with the rows option
+	type DecodeType struct {
+		Time  time.Time `influx:"time"`
+		Value float64   `influx:"value"`
+		Rows  []string  `influx:"-"`
+	}
+
+	decoded := []DecodeType{}
+	if err := decode(groupByTestData(), &decoded); err != nil {
+		t.Fatal("Error decoding: ", err)
+	}
+
+	if len(decoded) != 3 || decoded[1].Value != 2.0 {
+		t.Error("decoded value is not right", decoded)
+	}
+}
@@ -0,0 +1,160 @@
+package influxdbhelper
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// DecodeResult contains metadata about decoding the result of a query,
+// which can be used to check that a query and struct type match.
+type DecodeResult struct {
+	// Unused lists the columns returned by the query that were not decoded
+	// into a struct field or a tags or fields map.
+	Unused []string
+	// Unset lists the struct fields, by their influx name, that were not
+	// set in any row.
+	Unset []string
+	// Rows contains the series of each row, in the order they were
+	// decoded.
+	Rows []DecodeRow
+}
+
+// DecodeRow identifies the series a decoded row belongs to.
+type DecodeRow struct {
+	Series string
+	Tags   map[string]string
+}
+
+// decodeState collects the metadata of a decode.
+type decodeState struct {
+	// aliases maps columns added by addExpressionColumns to the column
+	// they copy.
+	aliases map[string]string
+
+	declared map[string]bool
+	set      map[string]bool
+	unused   map[string]bool
+	rows     []DecodeRow
+}
+
+func newDecodeState() *decodeState {
+	return &decodeState{
+		declared: make(map[string]bool),
+		set:      make(map[string]bool),
+		unused:   make(map[string]bool),
+		rows:     []DecodeRow{},
+	}
+}
+
+// metadataKey strips the [index]. prefix from a mapstructure metadata key.
+func metadataKey(key string) (string, bool) {
+	if !strings.HasPrefix(key, "[") {
+		return key, true
+	}
+
+	i := strings.Index(key, "].")
+	if i < 0 {
+		return "", false
+	}
+
+	return key[i+2:], true
+}
+
+// add records the metadata of decoding influxData into result.
+func (s *decodeState) add(influxData []map[string]interface{}, tags []map[string]string,
+	result interface{}, metadata *mapstructure.Metadata, captured map[string]bool) {
+	t := reflect.TypeOf(result)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+
+	if t != nil && t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			structField := t.Field(i)
+			if structField.PkgPath != "" {
+				continue
+			}
+
+			fieldData := getInfluxFieldTagData(structField.Name, structField.Tag.Get("influx"))
+			if fieldData.fieldName == "-" || fieldData.isTagMap || fieldData.isFieldMap {
+				continue
+			}
+			s.declared[fieldData.fieldName] = true
+		}
+	}
+
+	for _, key := range metadata.Keys {
+		if k, ok := metadataKey(key); ok {
+			s.set[k] = true
+		}
+	}
+
+	for _, key := range metadata.Unused {
+		k, ok := metadataKey(key)
+		if !ok || k == "InfluxMeasurement" || captured[k] {
+			continue
+		}
+		s.unused[k] = true
+	}
+
+	for i, row := range influxData {
+		r := DecodeRow{}
+		r.Series, _ = row["InfluxMeasurement"].(string)
+		if i < len(tags) {
+			r.Tags = tags[i]
+		}
+		s.rows = append(s.rows, r)
+	}
+}
+
+// result returns the metadata collected.
+func (s *decodeState) result() DecodeResult {
+	ret := DecodeResult{Unused: []string{}, Unset: []string{}, Rows: s.rows}
+
+	// an expression column and the column it copies are both used if
+	// either is
+	used := make(map[string]bool)
+	for added, column := range s.aliases {
+		if s.set[added] || s.set[column] {
+			used[added] = true
+			used[column] = true
+		}
+	}
+
+	for k := range s.unused {
+		if _, ok := s.aliases[k]; ok || used[k] {
+			continue
+		}
+		ret.Unused = append(ret.Unused, k)
+	}
+
+	for k := range s.declared {
+		if !s.set[k] && !used[k] {
+			ret.Unset = append(ret.Unset, k)
+		}
+	}
+
+	sort.Strings(ret.Unused)
+	sort.Strings(ret.Unset)
+
+	return ret
+}
+
+// unknownColumnsError returns an error listing the unused columns, or nil
+// if there are none.
+func unknownColumnsError(r DecodeResult) error {
+	if len(r.Unused) == 0 {
+		return nil
+	}
+
+	errors := []string{}
+	for _, c := range r.Unused {
+		errors = appendErrors(errors, fmt.Errorf("unknown column '%s'", c))
+	}
+
+	return &Error{errors}
+}
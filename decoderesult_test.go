@@ -0,0 +1,109 @@
+package influxdbhelper
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+func newDecodeResultServer(t *testing.T) (*influxdbtest.Server, Client) {
+	s := influxdbtest.NewServer()
+	s.CreateDatabase("db")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+
+	type Sample struct {
+		Time        time.Time `influx:"time"`
+		Location    string    `influx:"location,tag"`
+		Temperature float64   `influx:"temperature"`
+		Humidity    float64   `influx:"humidity"`
+	}
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, l := range []string{"rm1", "rm2"} {
+		err := c.UseDB("db").UseMeasurement("test").
+			WritePoint(Sample{start.Add(time.Duration(i) * time.Second), l, 20.5, 50.5})
+		if err != nil {
+			t.Fatal("Error writing point: ", err)
+		}
+	}
+
+	return s, c
+}
+
+type decodeResultSample struct {
+	Time        time.Time `influx:"time"`
+	Location    string    `influx:"location"`
+	Temperature float64   `influx:"temperature"`
+	Pressure    float64   `influx:"pressure"`
+}
+
+func TestDecodeResult(t *testing.T) {
+	s, c := newDecodeResultServer(t)
+	defer s.Close()
+
+	read := []decodeResultSample{}
+	var meta DecodeResult
+	if err := c.UseDB("db").DecodeQuery(`SELECT * FROM test`, &read, &meta); err != nil {
+		t.Fatal("Error decoding query: ", err)
+	}
+
+	if !reflect.DeepEqual(meta.Unused, []string{"humidity"}) {
+		t.Errorf("Unexpected unused columns: %v", meta.Unused)
+	}
+
+	if !reflect.DeepEqual(meta.Unset, []string{"pressure"}) {
+		t.Errorf("Unexpected unset fields: %v", meta.Unset)
+	}
+
+	if len(meta.Rows) != 2 || meta.Rows[0].Series != "test" {
+		t.Errorf("Unexpected rows: %+v", meta.Rows)
+	}
+
+	err := c.UseDB("db").DecodeQuery(`SELECT * FROM test GROUP BY location`, &read, &meta)
+	if err != nil {
+		t.Fatal("Error decoding query: ", err)
+	}
+
+	if len(meta.Rows) != 2 || meta.Rows[1].Tags["location"] != "rm2" {
+		t.Errorf("Unexpected rows: %+v", meta.Rows)
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	s, c := newDecodeResultServer(t)
+	defer s.Close()
+
+	c.UseStrictDecode(true)
+
+	read := []decodeResultSample{}
+	err := c.UseDB("db").DecodeQuery(`SELECT * FROM test`, &read)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("Expected *DecodeError, got %v", err)
+	}
+
+	err = c.UseDB("db").DecodeQuery(`SELECT temperature, location FROM test`, &read)
+	if err != nil {
+		t.Error("Error decoding query: ", err)
+	}
+
+	type Mean struct {
+		Time        time.Time `influx:"time"`
+		Temperature float64   `influx:"mean(temperature)"`
+	}
+
+	mean := []Mean{}
+	var meta DecodeResult
+	err = c.UseDB("db").DecodeQuery(`SELECT mean(temperature) FROM test`, &mean, &meta)
+	if err != nil {
+		t.Error("Error decoding aggregate query: ", err)
+	}
+
+	if len(meta.Unused) != 0 || len(meta.Unset) != 0 {
+		t.Errorf("Unexpected metadata: %+v", meta)
+	}
+}
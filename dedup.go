@@ -0,0 +1,151 @@
+package influxdbhelper
+
+import (
+	"sync"
+
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+// MergeFunc merges the fields of a duplicate point into the fields of the
+// point written before it in the same batch, and returns the fields of the
+// coalesced point. existing may be modified and returned.
+type MergeFunc func(existing, duplicate map[string]interface{}) map[string]interface{}
+
+// LastValueWins is the default MergeFunc of a Deduplicator. The fields of
+// the duplicate replace those of the existing point, and fields only in
+// the existing point are kept, as InfluxDb does for points written
+// separately.
+func LastValueWins(existing, duplicate map[string]interface{}) map[string]interface{} {
+	for k, v := range duplicate {
+		existing[k] = v
+	}
+	return existing
+}
+
+// DedupStats reports the points checked by a Deduplicator.
+type DedupStats struct {
+	// Points is the number of points checked.
+	Points uint64
+	// Duplicates is the number of points coalesced into an earlier point
+	// of the same batch.
+	Duplicates uint64
+}
+
+// Deduplicator coalesces the points of a batch that have the same
+// measurement, tag set and time at the precision of the batch, before the
+// batch is written. This reduces the size of writes when several pollers
+// produce the same points. Points written one at a time, as by WritePoint,
+// are coalesced when buffered by a BatchWriter and written at its flush.
+// Points without a time are not coalesced.
+//
+// A Deduplicator is safe for concurrent use, and can be shared by several
+// clients.
+type Deduplicator struct {
+	merge MergeFunc
+
+	lock  sync.Mutex
+	stats DedupStats
+}
+
+// NewDeduplicator returns a new Deduplicator that merges the fields of
+// duplicate points with merge, or LastValueWins if merge is nil. It is
+// enabled for a Client with UseDeduplicator.
+func NewDeduplicator(merge MergeFunc) *Deduplicator {
+	if merge == nil {
+		merge = LastValueWins
+	}
+	return &Deduplicator{merge: merge}
+}
+
+// Stats returns the number of points checked and coalesced.
+func (d *Deduplicator) Stats() DedupStats {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.stats
+}
+
+// dedupKey identifies the points that are coalesced.
+type dedupKey struct {
+	series string
+	time   int64
+}
+
+// Dedup returns the points of bp with duplicates coalesced into the first
+// point of each series and time, and the number of duplicates. bp is
+// returned if there are none.
+func (d *Deduplicator) Dedup(bp influxClient.BatchPoints) (influxClient.BatchPoints, int, error) {
+	points := bp.Points()
+	unit := PrecisionDuration(bp.Precision()).Nanoseconds()
+
+	index := make(map[dedupKey]int, len(points))
+	fields := make(map[int]map[string]interface{})
+	var coalesced []*influxClient.Point
+
+	for _, p := range points {
+		if p.Time().IsZero() {
+			coalesced = append(coalesced, p)
+			continue
+		}
+
+		key := dedupKey{p.Name() + "," + TagSetKey(p.Tags()), p.UnixNano() / unit}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(coalesced)
+			coalesced = append(coalesced, p)
+			continue
+		}
+
+		existing, ok := fields[i]
+		if !ok {
+			var err error
+			existing, err = coalesced[i].Fields()
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+
+		duplicate, err := p.Fields()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		fields[i] = d.merge(existing, duplicate)
+	}
+
+	duplicates := len(points) - len(coalesced)
+	if duplicates == 0 {
+		d.count(len(points), 0)
+		return bp, 0, nil
+	}
+
+	for i, f := range fields {
+		p := coalesced[i]
+		pt, err := influxClient.NewPoint(p.Name(), p.Tags(), f, p.Time())
+		if err != nil {
+			return nil, 0, err
+		}
+		coalesced[i] = pt
+	}
+
+	ret, err := influxClient.NewBatchPoints(influxClient.BatchPointsConfig{
+		Precision:        bp.Precision(),
+		Database:         bp.Database(),
+		RetentionPolicy:  bp.RetentionPolicy(),
+		WriteConsistency: bp.WriteConsistency(),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	ret.AddPoints(coalesced)
+
+	d.count(len(points), duplicates)
+	return ret, duplicates, nil
+}
+
+func (d *Deduplicator) count(points, duplicates int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.stats.Points += uint64(points)
+	d.stats.Duplicates += uint64(duplicates)
+}
@@ -0,0 +1,155 @@
+package influxdbhelper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+func newDedupBatch(t *testing.T, precision string, points ...*influxClient.Point) influxClient.BatchPoints {
+	bp, err := influxClient.NewBatchPoints(influxClient.BatchPointsConfig{Database: "db", Precision: precision})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bp.AddPoints(points)
+	return bp
+}
+
+func newDedupPoint(t *testing.T, location string, fields map[string]interface{}, tm time.Time) *influxClient.Point {
+	p, err := influxClient.NewPoint("env", map[string]string{"location": location}, fields, tm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDeduplicator(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	bp := newDedupBatch(t, "s",
+		newDedupPoint(t, "rm1", map[string]interface{}{"temperature": 70.0, "humidity": 40.0}, start),
+		newDedupPoint(t, "rm2", map[string]interface{}{"temperature": 68.0}, start),
+		// same second at the precision of the batch
+		newDedupPoint(t, "rm1", map[string]interface{}{"temperature": 71.0}, start.Add(10*time.Millisecond)),
+		newDedupPoint(t, "rm1", map[string]interface{}{"temperature": 72.0}, start.Add(time.Second)),
+		newDedupPoint(t, "rm1", map[string]interface{}{"pressure": 1000.0}, start),
+		// points without a time are not coalesced
+		newDedupPoint(t, "rm1", map[string]interface{}{"temperature": 1.0}, time.Time{}),
+		newDedupPoint(t, "rm1", map[string]interface{}{"temperature": 2.0}, time.Time{}),
+	)
+
+	d := NewDeduplicator(nil)
+	ret, n, err := d.Dedup(bp)
+	if err != nil {
+		t.Fatal("Error deduplicating: ", err)
+	}
+
+	if n != 2 {
+		t.Errorf("%v != 2", n)
+	}
+
+	exp := []string{
+		"env,location=rm1 humidity=40,pressure=1000,temperature=71 1546300800",
+		"env,location=rm2 temperature=68 1546300800",
+		"env,location=rm1 temperature=72 1546300801",
+		"env,location=rm1 temperature=1",
+		"env,location=rm1 temperature=2",
+	}
+
+	points := ret.Points()
+	if len(points) != len(exp) {
+		t.Fatalf("%v != %v", len(points), len(exp))
+	}
+	for i, p := range points {
+		if s := p.PrecisionString("s"); s != exp[i] {
+			t.Errorf("%v != %v", s, exp[i])
+		}
+	}
+
+	if ret.Database() != "db" || ret.Precision() != "s" {
+		t.Errorf("Unexpected batch config: %v %v", ret.Database(), ret.Precision())
+	}
+
+	// no duplicates
+	bp = newDedupBatch(t, "ns", points[:3]...)
+	if ret, n, _ := d.Dedup(bp); ret != bp || n != 0 {
+		t.Errorf("Expected the batch unchanged, %v duplicates", n)
+	}
+
+	if stats := d.Stats(); stats.Points != 10 || stats.Duplicates != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestDeduplicatorMerge(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// keep the maximum value
+	d := NewDeduplicator(func(existing, duplicate map[string]interface{}) map[string]interface{} {
+		for k, v := range duplicate {
+			if e, ok := existing[k].(float64); !ok || v.(float64) > e {
+				existing[k] = v
+			}
+		}
+		return existing
+	})
+
+	bp := newDedupBatch(t, "ns",
+		newDedupPoint(t, "rm1", map[string]interface{}{"temperature": 70.0}, start),
+		newDedupPoint(t, "rm1", map[string]interface{}{"temperature": 75.0}, start),
+		newDedupPoint(t, "rm1", map[string]interface{}{"temperature": 72.0}, start),
+	)
+
+	ret, n, err := d.Dedup(bp)
+	if err != nil || n != 2 {
+		t.Fatalf("Unexpected result: %v, %v", n, err)
+	}
+
+	fields, _ := ret.Points()[0].Fields()
+	if fields["temperature"] != 75.0 {
+		t.Errorf("%v != 75", fields["temperature"])
+	}
+}
+
+func TestClientDeduplicator(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	type envSample struct {
+		Time        time.Time `influx:"time"`
+		Location    string    `influx:"location,tag"`
+		Temperature float64   `influx:"temperature"`
+	}
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := []envSample{
+		{start, "rm1", 70},
+		{start, "rm1", 71},
+		{start, "rm2", 68},
+	}
+
+	d := NewDeduplicator(nil)
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseDeduplicator(d)
+
+	if err := c.UseDB("db").UseMeasurement("env").WritePoints(samples); err != nil {
+		t.Fatal("Error writing points: ", err)
+	}
+
+	requests := s.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("%v != 1", len(requests))
+	}
+
+	exp := "env,location=rm1 temperature=71 1546300800000000000\nenv,location=rm2 temperature=68 1546300800000000000\n"
+	if body := string(requests[0].Body); body != exp {
+		t.Errorf("%v != %v", body, exp)
+	}
+
+	if stats := d.Stats(); stats.Points != 3 || stats.Duplicates != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,145 @@
+package influxdbhelper
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// dynamicFields describes the tags and fields map fields of a struct type,
+// and the columns decoded into its other fields.
+type dynamicFields struct {
+	tags     int
+	fields   int
+	declared map[string]bool
+}
+
+// getDynamicFields returns the dynamic fields of the struct type t, or nil
+// if it has no tags or fields map.
+func getDynamicFields(t reflect.Type) *dynamicFields {
+	ret := &dynamicFields{tags: -1, fields: -1, declared: make(map[string]bool)}
+
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if structField.PkgPath != "" {
+			continue
+		}
+
+		fieldData := getInfluxFieldTagData(structField.Name, structField.Tag.Get("influx"))
+		switch {
+		case fieldData.isTagMap && structField.Type == tagsType:
+			ret.tags = i
+		case fieldData.isFieldMap && structField.Type == fieldsType:
+			ret.fields = i
+		case fieldData.fieldName != "-":
+			// mapstructure matches names case insensitively
+			ret.declared[strings.ToLower(fieldData.fieldName)] = true
+		}
+	}
+
+	if ret.tags < 0 && ret.fields < 0 {
+		return nil
+	}
+
+	return ret
+}
+
+var fieldsType = reflect.TypeOf(map[string]interface{}{})
+
+// decodeDynamic stores columns that are not decoded into a declared struct
+// field in the tags or fields map of each row of result, if the struct type
+// has one.
+//
+// Series tags are stored in the tags map, and other columns in the fields
+// map. As InfluxDb also returns tags as columns when a query does not group
+// by them, string columns are stored in the tags map if there is no fields
+// map. The columns stored are returned.
+func decodeDynamic(influxData []map[string]interface{}, tags []map[string]string, result interface{}) (map[string]bool, error) {
+	v := reflect.ValueOf(result)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Slice {
+		return nil, nil
+	}
+
+	elemType := v.Type().Elem()
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+
+	if elemType.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	dynamic := getDynamicFields(elemType)
+	if dynamic == nil {
+		return nil, nil
+	}
+
+	captured := make(map[string]bool)
+
+	for i, row := range influxData {
+		if i >= v.Len() {
+			break
+		}
+
+		elem := v.Index(i)
+		for elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+
+		var seriesTags map[string]string
+		if i < len(tags) {
+			seriesTags = tags[i]
+		}
+
+		for k, value := range row {
+			if value == nil || k == "InfluxMeasurement" || dynamic.declared[strings.ToLower(k)] {
+				continue
+			}
+
+			_, isTag := seriesTags[k]
+			s, isString := value.(string)
+
+			switch {
+			case dynamic.tags >= 0 && isString && (isTag || dynamic.fields < 0):
+				setMapIndex(elem.Field(dynamic.tags), k, s)
+				captured[k] = true
+			case dynamic.fields >= 0:
+				setMapIndex(elem.Field(dynamic.fields), k, dynamicValue(value))
+				captured[k] = true
+			}
+		}
+	}
+
+	return captured, nil
+}
+
+func setMapIndex(m reflect.Value, key string, value interface{}) {
+	if m.IsNil() {
+		m.Set(reflect.MakeMap(m.Type()))
+	}
+	m.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+}
+
+// dynamicValue converts a json.Number to an int64 if it is an integer, or
+// else a float64. As InfluxDb returns float values without a fractional
+// part as integers, the type of a float field may differ between rows.
+func dynamicValue(value interface{}) interface{} {
+	n, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+
+	return value
+}
@@ -0,0 +1,99 @@
+package influxdbhelper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+type dynamicSample struct {
+	Time     time.Time              `influx:"time"`
+	Location string                 `influx:"location,tag"`
+	Value    float64                `influx:"value"`
+	Labels   map[string]string      `influx:",tags"`
+	Extra    map[string]interface{} `influx:",fields"`
+}
+
+func TestEncodeDynamic(t *testing.T) {
+	d := dynamicSample{
+		Location: "rm",
+		Value:    1,
+		Labels:   map[string]string{"host": "a", "location": "ignored", "empty": ""},
+		Extra:    map[string]interface{}{"count": 3, "value": 2.0, "none": nil},
+	}
+
+	_, tags, fields, _, err := encode(d, nil)
+	if err != nil {
+		t.Fatal("Error encoding: ", err)
+	}
+
+	expectedTags := map[string]string{"location": "rm", "host": "a"}
+	if !reflect.DeepEqual(tags, expectedTags) {
+		t.Errorf("%v != %v", tags, expectedTags)
+	}
+
+	expectedFields := map[string]interface{}{"value": 1.0, "count": 3}
+	if !reflect.DeepEqual(fields, expectedFields) {
+		t.Errorf("%v != %v", fields, expectedFields)
+	}
+}
+
+func TestEncodeDynamicNotMap(t *testing.T) {
+	type Sample struct {
+		Labels string  `influx:",tags"`
+		Value  float64 `influx:"value"`
+	}
+
+	if _, _, _, _, err := encode(Sample{"a", 1}, nil); err == nil {
+		t.Error("Expected error for tags field that is not a map")
+	}
+}
+
+func TestClientDynamic(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	written := dynamicSample{
+		Time:     start,
+		Location: "rm",
+		Value:    1.5,
+		Labels:   map[string]string{"host": "a"},
+		Extra:    map[string]interface{}{"count": int64(3), "note": "x"},
+	}
+
+	if err := c.UseDB("db").UseMeasurement("test").WritePoint(written); err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+
+	read := []dynamicSample{}
+	if err := c.UseDB("db").DecodeQuery(`SELECT * FROM test GROUP BY *`, &read); err != nil {
+		t.Fatal("Error decoding query: ", err)
+	}
+
+	if len(read) != 1 || !reflect.DeepEqual(read[0], written) {
+		t.Errorf("%+v != %+v", read, written)
+	}
+
+	// without a GROUP BY, only string columns are known to be tags
+	type TagsOnly struct {
+		Time   time.Time         `influx:"time"`
+		Value  float64           `influx:"value"`
+		Labels map[string]string `influx:",tags"`
+	}
+
+	tagsOnly := []TagsOnly{}
+	if err := c.UseDB("db").DecodeQuery(`SELECT * FROM test`, &tagsOnly); err != nil {
+		t.Fatal("Error decoding query: ", err)
+	}
+
+	expected := map[string]string{"host": "a", "location": "rm", "note": "x"}
+	if len(tagsOnly) != 1 || !reflect.DeepEqual(tagsOnly[0].Labels, expected) {
+		t.Errorf("%+v != %+v", tagsOnly, expected)
+	}
+}
@@ -8,6 +8,12 @@ import (
 )
 
 func encode(d interface{}, timeField *usingValue) (t time.Time, tags map[string]string, fields map[string]interface{}, measurement string, err error) {
+	return encodeWithNaming(d, timeField, nil)
+}
+
+// encodeWithNaming encodes d, using naming to determine the measurement from
+// the type name if it is not otherwise defined.
+func encodeWithNaming(d interface{}, timeField *usingValue, naming NamingStrategy) (t time.Time, tags map[string]string, fields map[string]interface{}, measurement string, err error) {
 	tags = make(map[string]string)
 	fields = make(map[string]interface{})
 	dValue := reflect.ValueOf(d)
@@ -25,9 +31,19 @@ func encode(d interface{}, timeField *usingValue) (t time.Time, tags map[string]
 		timeField = &usingValue{"time", false}
 	}
 
+	errs := []string{}
+
+	// entries of tags and fields maps, which declared fields override
+	dynamicTags := make(map[string]string)
+	dynamicFields := make(map[string]interface{})
+
 	for i := 0; i < dValue.NumField(); i++ {
 		f := dValue.Field(i)
 		structFieldName := dValue.Type().Field(i).Name
+		if dValue.Type().Field(i).PkgPath != "" {
+			// unexported, including blank marker fields
+			continue
+		}
 		if structFieldName == "InfluxMeasurement" {
 			measurement = f.String()
 			continue
@@ -39,23 +55,89 @@ func encode(d interface{}, timeField *usingValue) (t time.Time, tags map[string]
 			continue
 		}
 
+		if fieldData.isTagMap || fieldData.isFieldMap {
+			if f.Kind() != reflect.Map || f.Type().Key().Kind() != reflect.String {
+				errs = appendErrors(errs,
+					fmt.Errorf("'%s': tags and fields must be a map with string keys", structFieldName))
+				continue
+			}
+
+			iter := f.MapRange()
+			for iter.Next() {
+				k, v := iter.Key().String(), iter.Value()
+				if v.Kind() == reflect.Interface && v.IsNil() {
+					continue
+				}
+				if fieldData.isTagMap {
+					if s := fmt.Sprintf("%v", v); s != "" {
+						dynamicTags[k] = s
+					}
+				} else {
+					dynamicFields[k] = v.Interface()
+				}
+			}
+			continue
+		}
+
 		if fieldData.fieldName == timeField.value {
 			// TODO error checking
 			t = f.Interface().(time.Time)
 			continue
 		}
 
+		if fieldData.route && !fieldData.isTag {
+			errs = appendErrors(errs,
+				fmt.Errorf("'%s': the route option requires a tag", fieldData.fieldName))
+		}
+
 		if fieldData.isTag {
-			tags[fieldData.fieldName] = fmt.Sprintf("%v", f)
+			v, tErr := tagValue(f, fieldData)
+			if tErr != nil {
+				errs = appendErrors(errs,
+					fmt.Errorf("'%s': %v", fieldData.fieldName, tErr))
+			} else if v != "" {
+				// InfluxDb does not store empty tag values
+				tags[fieldData.fieldName] = v
+			}
 		}
 
 		if fieldData.isField {
-			fields[fieldData.fieldName] = f.Interface()
+			if fieldData.fieldType == "" {
+				fields[fieldData.fieldName] = f.Interface()
+				continue
+			}
+
+			v, cErr := coerceField(f, fieldData.fieldType)
+			if cErr != nil {
+				errs = appendErrors(errs,
+					fmt.Errorf("'%s': %v", fieldData.fieldName, cErr))
+				continue
+			}
+			if v != nil {
+				fields[fieldData.fieldName] = v
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		err = &Error{errs}
+		return
+	}
+
+	for k, v := range dynamicTags {
+		if _, ok := tags[k]; !ok {
+			tags[k] = v
+		}
+	}
+
+	for k, v := range dynamicFields {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
 		}
 	}
 
 	if measurement == "" {
-		measurement = dValue.Type().Name()
+		measurement = typeMeasurement(dValue, naming)
 	}
 
 	return
@@ -6,7 +6,10 @@ package influxdbhelper
 import (
 	"errors"
 	"fmt"
+	"net/url"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -51,3 +54,160 @@ func appendErrors(errors []string, err error) []string {
 		return append(errors, e.Error())
 	}
 }
+
+// ErrNoDatabase is returned when a query or write is made before a database
+// is selected with UseDB.
+var ErrNoDatabase = errors.New("no db set for query")
+
+// ErrNoMeasurement is returned when a write is made without a measurement.
+var ErrNoMeasurement = errors.New("no measurement set for query")
+
+// ErrEmptyFilter is returned by DeleteSeries when a filter has no tag values
+// to match, so that a filter can not delete all series by mistake. Pass a
+// nil filter to delete all series.
+var ErrEmptyFilter = errors.New("filter has no tag values")
+
+// ErrConnection matches, using errors.Is, any error caused by failing to
+// reach the InfluxDb server. The underlying network error is available
+// with errors.Unwrap.
+var ErrConnection = errors.New("influxdb connection error")
+
+type connectionError struct {
+	err error
+}
+
+// Error returns the error without the query of the request URL, which may
+// contain credentials.
+func (e *connectionError) Error() string {
+	if ue, ok := e.err.(*url.Error); ok {
+		stripped := *ue
+		if i := strings.IndexByte(stripped.URL, '?'); i >= 0 {
+			stripped.URL = stripped.URL[:i]
+		}
+		return stripped.Error()
+	}
+	return e.err.Error()
+}
+
+func (e *connectionError) Is(target error) bool {
+	return target == ErrConnection
+}
+
+func (e *connectionError) Unwrap() error {
+	return e.err
+}
+
+// QueryError is returned when InfluxDb reports an error for a query
+// statement.
+type QueryError struct {
+	Statement string
+	Message   string
+	// Err is the underlying *HTTPError if the server also responded with
+	// an error status code.
+	Err error
+}
+
+func (e *QueryError) Error() string {
+	return e.Message
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError is returned when query results cannot be decoded into the
+// result data structure.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return "error decoding query result: " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// WriteError is returned when InfluxDb rejects some or all of the points
+// in a write.
+type WriteError struct {
+	Message string
+	// Partial is true if some of the points were written.
+	Partial bool
+	// Dropped is the number of points dropped as reported by the server.
+	Dropped int
+	// Err is a *FieldTypeConflictError if the points were rejected due to
+	// a field type conflict, else the underlying *HTTPError.
+	Err error
+}
+
+func (e *WriteError) Error() string {
+	return e.Message
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// FieldTypeConflictError is returned, wrapped in a *WriteError, when a
+// point contains a field with a different type than previously written to
+// the measurement.
+type FieldTypeConflictError struct {
+	Measurement  string
+	Field        string
+	Type         string
+	ExistingType string
+	Err          error
+}
+
+func (e *FieldTypeConflictError) Error() string {
+	return fmt.Sprintf("field type conflict: input field %q on measurement %q is type %v, already exists as type %v",
+		e.Field, e.Measurement, e.Type, e.ExistingType)
+}
+
+func (e *FieldTypeConflictError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	reDropped           = regexp.MustCompile(`dropped=(\d+)`)
+	reFieldTypeConflict = regexp.MustCompile(`field type conflict: input field "(.*?)" on measurement "(.*?)" is type (\w+), already exists as type (\w+)`)
+)
+
+// newWriteError converts an error returned from a write into a *WriteError
+// if the server rejected the points. Other errors are returned unchanged.
+func newWriteError(err error) error {
+	// the errors of the nodes of a multi client are already converted
+	var multiErr *MultiWriteError
+	if errors.As(err, &multiErr) {
+		return err
+	}
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode < 400 || httpErr.StatusCode >= 500 {
+		return err
+	}
+
+	e := &WriteError{
+		Message: httpErr.Message,
+		Partial: strings.HasPrefix(httpErr.Message, "partial write"),
+		Err:     httpErr,
+	}
+
+	if m := reDropped.FindStringSubmatch(httpErr.Message); m != nil {
+		e.Dropped, _ = strconv.Atoi(m[1])
+	}
+
+	if m := reFieldTypeConflict.FindStringSubmatch(httpErr.Message); m != nil {
+		e.Err = &FieldTypeConflictError{
+			Field:        m[1],
+			Measurement:  m[2],
+			Type:         m[3],
+			ExistingType: m[4],
+			Err:          httpErr,
+		}
+	}
+
+	return e
+}
@@ -0,0 +1,126 @@
+package influxdbhelper
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+func TestErrNoDatabase(t *testing.T) {
+	c, _ := NewClient("http://localhost:8086", "", "", "ns")
+
+	result := []struct{}{}
+	if err := c.DecodeQuery("SELECT * FROM test", &result); err != ErrNoDatabase {
+		t.Errorf("Expected ErrNoDatabase, got: %v", err)
+	}
+
+	if err := c.WritePoint(struct{}{}); err != ErrNoDatabase {
+		t.Errorf("Expected ErrNoDatabase, got: %v", err)
+	}
+}
+
+func TestErrConnection(t *testing.T) {
+	s := influxdbtest.NewServer()
+	s.Close()
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+
+	result := []struct{}{}
+	err := c.UseDB("db").DecodeQuery("SELECT * FROM test", &result)
+	if !errors.Is(err, ErrConnection) {
+		t.Errorf("Expected ErrConnection, got: %v", err)
+	}
+
+	err = c.UseDB("db").UseMeasurement("test").WritePointTagsFields(nil,
+		map[string]interface{}{"value": 1.0}, time.Now())
+	if !errors.Is(err, ErrConnection) {
+		t.Errorf("Expected ErrConnection, got: %v", err)
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+
+	result := []struct{}{}
+	err := c.UseDB("db").DecodeQuery("SELECT * FROM test; BOGUS STATEMENT", &result)
+
+	var qErr *QueryError
+	if !errors.As(err, &qErr) {
+		t.Fatalf("Expected *QueryError, got: %v", err)
+	}
+
+	if qErr.Statement != "BOGUS STATEMENT" {
+		t.Errorf("Unexpected statement: %v", qErr.Statement)
+	}
+
+	// error payloads with an error status code are also query errors
+	s.FailNext(http.StatusUnauthorized, "authorization failed")
+	err = c.UseDB("db").DecodeQuery("SELECT * FROM test", &result)
+
+	var httpErr *HTTPError
+	if !errors.As(err, &qErr) || !errors.As(err, &httpErr) || httpErr.StatusCode != 401 {
+		t.Errorf("Expected *QueryError wrapping a 401 *HTTPError, got: %v", err)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseDB("db").UseMeasurement("test").WritePointTagsFields(nil,
+		map[string]interface{}{"value": "string"}, time.Now())
+
+	result := []struct {
+		Value float64 `influx:"value"`
+	}{}
+	err := c.UseDB("db").DecodeQuery("SELECT * FROM test", &result)
+
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Errorf("Expected *DecodeError, got: %v", err)
+	}
+}
+
+func TestWriteFieldTypeConflict(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+	err := c.UseDB("db").UseMeasurement("test").WritePointTagsFields(nil,
+		map[string]interface{}{"value": 1.0}, time.Now())
+	if err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+
+	err = c.UseDB("db").UseMeasurement("test").WritePointTagsFields(nil,
+		map[string]interface{}{"value": "string"}, time.Now())
+
+	var writeErr *WriteError
+	if !errors.As(err, &writeErr) {
+		t.Fatalf("Expected *WriteError, got: %v", err)
+	}
+
+	if !writeErr.Partial || writeErr.Dropped != 1 {
+		t.Errorf("Unexpected write error: %+v", writeErr)
+	}
+
+	var conflict *FieldTypeConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected *FieldTypeConflictError, got: %v", err)
+	}
+
+	expected := FieldTypeConflictError{"test", "value", "string", "float", conflict.Err}
+	if *conflict != expected {
+		t.Errorf("%+v != %+v", *conflict, expected)
+	}
+}
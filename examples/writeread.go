@@ -5,7 +5,6 @@ import (
 	"time"
 
 	"github.com/cbrake/influxdbhelper/v2"
-	client "github.com/influxdata/influxdb1-client/v2"
 )
 
 const (
@@ -23,14 +22,9 @@ func Init() (err error) {
 		return
 	}
 	// Create test database if it doesn't already exist
-	q := client.NewQuery("CREATE DATABASE "+db, "", "")
-	res, err := c.Query(q)
-	if err != nil {
+	if err := c.CreateDatabase(db); err != nil {
 		return err
 	}
-	if res.Error() != nil {
-		return res.Error()
-	}
 	log.Println("dbhelper db initialized")
 	return nil
 }
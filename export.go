@@ -0,0 +1,439 @@
+package influxdbhelper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	influxModels "github.com/influxdata/influxdb1-client/models"
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+// NameColumn is the column the exporters write the measurement name of
+// each series to. Series with a tag or field of the same name are only
+// exported with OmitName set.
+const NameColumn = "name"
+
+// ExportOptions configures how query results are converted by the
+// exporters.
+type ExportOptions struct {
+	// Columns sets the columns written, in order. By default, the columns
+	// are the name column, the series tags in sorted order, and the columns
+	// of the query in the order returned, as found in the first rows
+	// written.
+	Columns []string
+	// OmitName omits the name column from the default columns. A name
+	// column set in Columns is then a tag or field.
+	OmitName bool
+	// Types sets the type of field columns, using the type names of SHOW
+	// FIELD KEYS or the type options of WritePoint. As InfluxDb returns
+	// floats without a fractional part as integers, numbers in columns
+	// without a type are kept as json.Number, which the exporters write
+	// exactly as returned.
+	Types map[string]string
+	// Precision is the epoch the query was run with, if any, used to read
+	// times returned as integers. The default is ns.
+	Precision string
+	// TimeFormat is the layout times are written with. The default is
+	// time.RFC3339Nano.
+	TimeFormat string
+	// Epoch writes times as integers in this precision instead of
+	// formatting them: h, m, s, ms, u or ns.
+	Epoch string
+	// Location is the time zone times are formatted in. The default is
+	// UTC.
+	Location *time.Location
+}
+
+// RowWriter writes the rows of query results in a tabular format. Tags are
+// written as columns.
+type RowWriter interface {
+	// WriteRows writes the rows of series.
+	WriteRows(series []influxModels.Row) error
+	// Close writes any buffered data. It does not close the underlying
+	// writer.
+	Close() error
+}
+
+// ExportChunked writes the series of each response read from r to w, and
+// closes r. w is not closed.
+func ExportChunked(r *influxClient.ChunkedResponse, w RowWriter) error {
+	defer r.Close()
+
+	for {
+		response, err := r.NextResponse()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := response.Error(); err != nil {
+			return err
+		}
+
+		for _, result := range response.Results {
+			if err := w.WriteRows(result.Series); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Flattener flattens the series of query results into rows with a fixed
+// set of columns. It is used by the exporters, and can be used to write
+// other formats.
+type Flattener struct {
+	options ExportOptions
+	columns []string
+	known   map[string]bool
+	// grow adds unknown columns instead of returning an error.
+	grow bool
+}
+
+// NewFlattener returns a new Flattener.
+func NewFlattener(options ExportOptions) *Flattener {
+	f := &Flattener{options: options, known: make(map[string]bool)}
+	for _, c := range options.Columns {
+		f.addColumn(c)
+	}
+	return f
+}
+
+func (f *Flattener) addColumn(c string) {
+	if !f.known[c] {
+		f.known[c] = true
+		f.columns = append(f.columns, c)
+	}
+}
+
+// Columns returns the columns of the rows. Unless set in the options, the
+// columns are set by the first call to Flatten.
+func (f *Flattener) Columns() []string {
+	return f.columns
+}
+
+// Flatten returns the values of each row of series, in the order of
+// Columns. Values missing from a row are nil, times are time.Time, and
+// numbers are json.Number unless another type is set in the options. Unless
+// the columns are set in the options, an error is returned if series has
+// columns not in Columns. An error is returned if series has a tag or field
+// with the name of the measurement name column.
+func (f *Flattener) Flatten(series []influxModels.Row) ([][]interface{}, error) {
+	if len(f.options.Columns) == 0 && (len(f.columns) == 0 || f.grow) {
+		f.addColumns(series)
+	}
+
+	errors := []string{}
+
+	// the name column holds the measurement, so a tag or field with the
+	// same name would be lost
+	name := !f.options.OmitName && f.known[NameColumn]
+	if name {
+		conflict := false
+		for _, s := range series {
+			_, ok := s.Tags[NameColumn]
+			conflict = conflict || ok
+			for _, c := range s.Columns {
+				conflict = conflict || c == NameColumn
+			}
+		}
+		if conflict {
+			errors = appendErrors(errors, fmt.Errorf(
+				"'%s': tag or field conflicts with the measurement name column, set OmitName to export it",
+				NameColumn))
+		}
+	}
+
+	// columns set in the options select the columns, so others are
+	// ignored
+	if len(f.options.Columns) == 0 {
+		for _, s := range series {
+			for tag := range s.Tags {
+				if !f.known[tag] {
+					errors = appendErrors(errors, fmt.Errorf("unknown column '%s'", tag))
+				}
+			}
+			for _, c := range s.Columns {
+				if !f.known[c] {
+					errors = appendErrors(errors, fmt.Errorf("unknown column '%s'", c))
+				}
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return nil, &Error{errors}
+	}
+
+	var ret [][]interface{}
+	for _, s := range series {
+		for _, row := range flattenSeries(s) {
+			values := make([]interface{}, len(f.columns))
+			for i, c := range f.columns {
+				v := row[c]
+				if c == NameColumn && name {
+					v = row["InfluxMeasurement"]
+				}
+
+				value, err := f.value(c, v)
+				if err != nil {
+					errors = appendErrors(errors, fmt.Errorf("'%s': %v", c, err))
+				}
+				values[i] = value
+			}
+			ret = append(ret, values)
+		}
+	}
+
+	if len(errors) > 0 {
+		return nil, &Error{errors}
+	}
+
+	return ret, nil
+}
+
+// addColumns adds the default columns of series.
+func (f *Flattener) addColumns(series []influxModels.Row) {
+	if len(series) == 0 {
+		return
+	}
+
+	tags := make(map[string]bool)
+	for _, s := range series {
+		for tag := range s.Tags {
+			tags[tag] = true
+		}
+	}
+
+	sortedTags := make([]string, 0, len(tags))
+	for tag := range tags {
+		sortedTags = append(sortedTags, tag)
+	}
+	sort.Strings(sortedTags)
+
+	if !f.options.OmitName && len(f.columns) == 0 {
+		f.addColumn(NameColumn)
+	}
+
+	for _, tag := range sortedTags {
+		f.addColumn(tag)
+	}
+
+	for _, s := range series {
+		for _, c := range s.Columns {
+			f.addColumn(c)
+		}
+	}
+}
+
+// value converts a value of column.
+func (f *Flattener) value(column string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	if column == "time" {
+		return f.parseTime(v)
+	}
+
+	if typ, ok := f.options.Types[column]; ok {
+		fieldType, ok := fieldTypeOptions[typ]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %v", typ)
+		}
+		return coerceField(reflect.ValueOf(dynamicValue(v)), fieldType)
+	}
+
+	return v, nil
+}
+
+// parseTime converts a time returned by InfluxDb as an RFC3339 string or
+// an integer in units of the precision.
+func (f *Flattener) parseTime(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return time.Parse(time.RFC3339Nano, v)
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, n*int64(PrecisionDuration(f.options.Precision))).UTC(), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %v", v)
+}
+
+// formatTime formats a time as set in the options.
+func (o *ExportOptions) formatTime(t time.Time) interface{} {
+	if o.Epoch != "" {
+		return t.UnixNano() / int64(PrecisionDuration(o.Epoch))
+	}
+
+	layout := o.TimeFormat
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+
+	location := o.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	return t.In(location).Format(layout)
+}
+
+// PrecisionDuration returns the duration of a unit of an InfluxDb precision
+// or epoch: h, m, s, ms, u (or us or µ) or ns. Other values, including "",
+// are nanoseconds.
+func PrecisionDuration(precision string) time.Duration {
+	switch precision {
+	case "h":
+		return time.Hour
+	case "m":
+		return time.Minute
+	case "s":
+		return time.Second
+	case "ms":
+		return time.Millisecond
+	case "u", "us", "µ":
+		return time.Microsecond
+	}
+	return time.Nanosecond
+}
+
+type csvWriter struct {
+	w       *csv.Writer
+	f       *Flattener
+	options ExportOptions
+	header  bool
+}
+
+// NewCSVWriter returns a RowWriter that writes CSV with a header line. The
+// columns are fixed by the options or the first rows written, and an error
+// is returned for rows with other columns unless they are set in the
+// options. Missing values are written as empty strings.
+func NewCSVWriter(w io.Writer, options ExportOptions) RowWriter {
+	return &csvWriter{w: csv.NewWriter(w), f: NewFlattener(options), options: options}
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.header || len(c.f.Columns()) == 0 {
+		return nil
+	}
+	c.header = true
+	return c.w.Write(c.f.Columns())
+}
+
+func (c *csvWriter) WriteRows(series []influxModels.Row) error {
+	rows, err := c.f.Flatten(series)
+	if err != nil {
+		return err
+	}
+
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			switch v := v.(type) {
+			case nil:
+			case time.Time:
+				record[i] = fmt.Sprint(c.options.formatTime(v))
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := c.w.Write(record); err != nil {
+			return err
+		}
+	}
+
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonWriter struct {
+	w       io.Writer
+	f       *Flattener
+	options ExportOptions
+}
+
+// NewJSONWriter returns a RowWriter that writes newline delimited JSON, with
+// an object for each row. Missing values are omitted. Unlike the other
+// writers, rows may have columns not in the options or first rows written.
+func NewJSONWriter(w io.Writer, options ExportOptions) RowWriter {
+	f := NewFlattener(options)
+	f.grow = true
+	return &jsonWriter{w: w, f: f, options: options}
+}
+
+func (j *jsonWriter) WriteRows(series []influxModels.Row) error {
+	rows, err := j.f.Flatten(series)
+	if err != nil {
+		return err
+	}
+
+	columns := j.f.Columns()
+	buf := &bytes.Buffer{}
+
+	for _, row := range rows {
+		buf.WriteByte('{')
+		first := true
+		for i, v := range row {
+			if v == nil {
+				continue
+			}
+			if t, ok := v.(time.Time); ok {
+				v = j.options.formatTime(t)
+			}
+
+			key, err := json.Marshal(columns[i])
+			if err != nil {
+				return err
+			}
+			value, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("'%s': %v", columns[i], err)
+			}
+
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(value)
+		}
+		buf.WriteString("}\n")
+	}
+
+	_, err = j.w.Write(buf.Bytes())
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	return nil
+}
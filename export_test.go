@@ -0,0 +1,270 @@
+package influxdbhelper
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+	influxModels "github.com/influxdata/influxdb1-client/models"
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+var exportSeries = []influxModels.Row{
+	{
+		Name:    "env",
+		Tags:    map[string]string{"location": "Rm 243"},
+		Columns: []string{"time", "temperature", "count", "state"},
+		Values: [][]interface{}{
+			{"2019-01-01T00:00:00Z", json.Number("70.5"), json.Number("1"), "on"},
+			{"2019-01-01T00:00:01Z", json.Number("71"), nil, "off, idle"},
+		},
+	},
+	{
+		Name:    "env",
+		Tags:    map[string]string{"location": "Rm 101"},
+		Columns: []string{"time", "temperature", "count", "state"},
+		Values: [][]interface{}{
+			{"2019-01-01T00:00:02Z", json.Number("68"), json.Number("3"), nil},
+		},
+	},
+}
+
+func TestExportCSV(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewCSVWriter(buf, ExportOptions{})
+
+	if err := w.WriteRows(exportSeries); err != nil {
+		t.Fatal("Error writing rows: ", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal("Error closing: ", err)
+	}
+
+	exp := `name,location,time,temperature,count,state
+env,Rm 243,2019-01-01T00:00:00Z,70.5,1,on
+env,Rm 243,2019-01-01T00:00:01Z,71,,"off, idle"
+env,Rm 101,2019-01-01T00:00:02Z,68,3,
+`
+	if buf.String() != exp {
+		t.Errorf("%v != %v", buf.String(), exp)
+	}
+
+	// columns not in the header
+	err := w.WriteRows([]influxModels.Row{{Name: "env", Columns: []string{"time", "humidity"}}})
+	if err == nil {
+		t.Error("Expected unknown column error")
+	}
+}
+
+func TestExportCSVOptions(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewCSVWriter(buf, ExportOptions{
+		Columns:  []string{"time", "location", "count"},
+		Types:    map[string]string{"count": "integer"},
+		Location: time.FixedZone("EST", -5*60*60),
+	})
+
+	// nothing written yet, but the header is known
+	if err := w.WriteRows(nil); err != nil {
+		t.Fatal("Error writing rows: ", err)
+	}
+	if err := w.WriteRows(exportSeries[1:]); err != nil {
+		t.Fatal("Error writing rows: ", err)
+	}
+	w.Close()
+
+	exp := `time,location,count
+2018-12-31T19:00:02-05:00,Rm 101,3
+`
+	if buf.String() != exp {
+		t.Errorf("%v != %v", buf.String(), exp)
+	}
+
+	buf.Reset()
+	w = NewCSVWriter(buf, ExportOptions{OmitName: true, Columns: []string{"time"}, Precision: "s", Epoch: "ms"})
+	w.WriteRows([]influxModels.Row{{Columns: []string{"time"}, Values: [][]interface{}{{json.Number("1546300800")}}}})
+	w.Close()
+
+	exp = "time\n1546300800000\n"
+	if buf.String() != exp {
+		t.Errorf("%v != %v", buf.String(), exp)
+	}
+
+	buf.Reset()
+	w = NewCSVWriter(buf, ExportOptions{Types: map[string]string{"state": "integer"}})
+	if err := w.WriteRows(exportSeries); err == nil {
+		t.Error("Expected type error")
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewJSONWriter(buf, ExportOptions{TimeFormat: "2006-01-02 15:04:05"})
+
+	if err := w.WriteRows(exportSeries[:1]); err != nil {
+		t.Fatal("Error writing rows: ", err)
+	}
+
+	// unlike CSV, new columns are allowed
+	err := w.WriteRows([]influxModels.Row{{
+		Name:    "env",
+		Columns: []string{"time", "humidity"},
+		Values:  [][]interface{}{{"2019-01-01T00:00:03Z", json.Number("40")}},
+	}})
+	if err != nil {
+		t.Fatal("Error writing rows: ", err)
+	}
+	w.Close()
+
+	exp := `{"name":"env","location":"Rm 243","time":"2019-01-01 00:00:00","temperature":70.5,"count":1,"state":"on"}
+{"name":"env","location":"Rm 243","time":"2019-01-01 00:00:01","temperature":71,"state":"off, idle"}
+{"name":"env","time":"2019-01-01 00:00:03","humidity":40}
+`
+	if buf.String() != exp {
+		t.Errorf("%v != %v", buf.String(), exp)
+	}
+}
+
+func TestExportLargeNumbers(t *testing.T) {
+	series := []influxModels.Row{{
+		Name:    "counters",
+		Columns: []string{"time", "count", "total", "ratio"},
+		Values: [][]interface{}{
+			{"2019-01-01T00:00:00Z", json.Number("1234567"), json.Number("9007199254740993"), json.Number("0.000001")},
+		},
+	}}
+
+	buf := &bytes.Buffer{}
+	w := NewCSVWriter(buf, ExportOptions{OmitName: true})
+	if err := w.WriteRows(series); err != nil {
+		t.Fatal("Error writing rows: ", err)
+	}
+
+	exp := `time,count,total,ratio
+2019-01-01T00:00:00Z,1234567,9007199254740993,0.000001
+`
+	if buf.String() != exp {
+		t.Errorf("%v != %v", buf.String(), exp)
+	}
+
+	// floats are not written in exponent form
+	buf.Reset()
+	w = NewCSVWriter(buf, ExportOptions{OmitName: true, Types: map[string]string{"count": "float", "total": "int"}})
+	if err := w.WriteRows(series); err != nil {
+		t.Fatal("Error writing rows: ", err)
+	}
+
+	if buf.String() != exp {
+		t.Errorf("%v != %v", buf.String(), exp)
+	}
+
+	buf.Reset()
+	w = NewJSONWriter(buf, ExportOptions{OmitName: true})
+	if err := w.WriteRows(series); err != nil {
+		t.Fatal("Error writing rows: ", err)
+	}
+
+	exp = `{"time":"2019-01-01T00:00:00Z","count":1234567,"total":9007199254740993,"ratio":0.000001}
+`
+	if buf.String() != exp {
+		t.Errorf("%v != %v", buf.String(), exp)
+	}
+}
+
+func TestExportNameConflict(t *testing.T) {
+	series := []influxModels.Row{{
+		Name:    "env",
+		Tags:    map[string]string{"name": "sensor1"},
+		Columns: []string{"time", "temperature"},
+		Values:  [][]interface{}{{"2019-01-01T00:00:00Z", json.Number("70")}},
+	}}
+
+	buf := &bytes.Buffer{}
+	if err := NewCSVWriter(buf, ExportOptions{}).WriteRows(series); err == nil {
+		t.Error("Expected name conflict error")
+	}
+
+	w := NewCSVWriter(buf, ExportOptions{OmitName: true})
+	if err := w.WriteRows(series); err != nil {
+		t.Fatal("Error writing rows: ", err)
+	}
+
+	exp := `name,time,temperature
+sensor1,2019-01-01T00:00:00Z,70
+`
+	if buf.String() != exp {
+		t.Errorf("%v != %v", buf.String(), exp)
+	}
+}
+
+func TestExportChunked(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+
+	type envSample struct {
+		InfluxMeasurement Measurement
+		Time              time.Time `influx:"time"`
+		Location          string    `influx:"location,tag"`
+		Count             int64     `influx:"count"`
+	}
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		err := c.UseDB("db").WritePoint(envSample{"env", start.Add(time.Duration(i) * time.Second), "rm1", int64(i)})
+		if err != nil {
+			t.Fatal("Error writing point: ", err)
+		}
+	}
+
+	r, err := c.QueryAsChunk(influxClient.Query{
+		Command:   "SELECT * FROM env GROUP BY *",
+		Database:  "db",
+		Precision: "s",
+		Chunked:   true,
+		ChunkSize: 2,
+	})
+	if err != nil {
+		t.Fatal("Error querying: ", err)
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewCSVWriter(buf, ExportOptions{
+		OmitName:  true,
+		Types:     map[string]string{"count": "int"},
+		Precision: "s",
+		Epoch:     "s",
+	})
+
+	if err := ExportChunked(r, w); err != nil {
+		t.Fatal("Error exporting: ", err)
+	}
+	w.Close()
+
+	exp := `location,time,count
+rm1,1546300800,0
+rm1,1546300801,1
+rm1,1546300802,2
+rm1,1546300803,3
+rm1,1546300804,4
+`
+	if buf.String() != exp {
+		t.Errorf("%v != %v", buf.String(), exp)
+	}
+}
+
+func TestPrecisionDuration(t *testing.T) {
+	for precision, exp := range map[string]time.Duration{
+		"h": time.Hour, "m": time.Minute, "s": time.Second, "ms": time.Millisecond,
+		"u": time.Microsecond, "us": time.Microsecond, "µ": time.Microsecond,
+		"ns": time.Nanosecond, "": time.Nanosecond,
+	} {
+		if d := PrecisionDuration(precision); d != exp {
+			t.Errorf("%v: %v != %v", precision, d, exp)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package influxdbhelper
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+// HTTPError is returned when the InfluxDb server responds to a request
+// with an unexpected HTTP status code.
+type HTTPError struct {
+	StatusCode int
+	// RetryAfter is the delay requested by the server in a Retry-After
+	// header, or 0 if none was sent.
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("received status code %d from server", e.StatusCode)
+	}
+	return e.Message
+}
+
+// httpClient implements the influxClient.Client interface on top of
+// net/http. It mirrors the client in the influxdb1-client package, but
+// returns an *HTTPError for failed requests so that status codes and
+// Retry-After headers are available to callers.
+type httpClient struct {
+	url        url.URL
+	username   string
+	password   string
+	useragent  string
+	httpClient *http.Client
+}
+
+func newHTTPClient(conf influxClient.HTTPConfig) (*httpClient, error) {
+	if conf.UserAgent == "" {
+		conf.UserAgent = "InfluxDBClient"
+	}
+
+	u, err := url.Parse(conf.Addr)
+	if err != nil {
+		return nil, err
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("Unsupported protocol scheme: %s, your address"+
+			" must start with http:// or https://", u.Scheme)
+	}
+
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: conf.InsecureSkipVerify,
+		},
+		Proxy: conf.Proxy,
+	}
+	if conf.TLSConfig != nil {
+		tr.TLSClientConfig = conf.TLSConfig
+	}
+
+	return &httpClient{
+		url:       *u,
+		username:  conf.Username,
+		password:  conf.Password,
+		useragent: conf.UserAgent,
+		httpClient: &http.Client{
+			Timeout:   conf.Timeout,
+			Transport: tr,
+		},
+	}, nil
+}
+
+func (c *httpClient) newRequest(method, endpoint string, body io.Reader) (*http.Request, error) {
+	u := c.url
+	u.Path = path.Join(u.Path, endpoint)
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "")
+	req.Header.Set("User-Agent", c.useragent)
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	return req, nil
+}
+
+// Ping checks that status of cluster.
+func (c *httpClient) Ping(timeout time.Duration) (time.Duration, string, error) {
+	now := time.Now()
+
+	req, err := c.newRequest("GET", "ping", nil)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if timeout > 0 {
+		params := req.URL.Query()
+		params.Set("wait_for_leader", fmt.Sprintf("%.0fs", timeout.Seconds()))
+		req.URL.RawQuery = params.Encode()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, "", &connectionError{err}
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		return 0, "", newHTTPError(resp, body)
+	}
+
+	version := resp.Header.Get("X-Influxdb-Version")
+	return time.Since(now), version, nil
+}
+
+// Write takes a BatchPoints object and writes all Points to InfluxDB.
+func (c *httpClient) Write(bp influxClient.BatchPoints) error {
+	_, err := c.write(bp)
+	return err
+}
+
+// write writes the points of bp, and returns the size of the line protocol
+// sent.
+func (c *httpClient) write(bp influxClient.BatchPoints) (int, error) {
+	var b bytes.Buffer
+
+	for _, p := range bp.Points() {
+		if p == nil {
+			continue
+		}
+		b.WriteString(p.PrecisionString(bp.Precision()))
+		b.WriteByte('\n')
+	}
+
+	n := b.Len()
+
+	req, err := c.newRequest("POST", "write", &b)
+	if err != nil {
+		return n, err
+	}
+
+	params := req.URL.Query()
+	params.Set("db", bp.Database())
+	params.Set("rp", bp.RetentionPolicy())
+	params.Set("precision", bp.Precision())
+	params.Set("consistency", bp.WriteConsistency())
+	req.URL.RawQuery = params.Encode()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return n, &connectionError{err}
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return n, err
+	}
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return n, newHTTPError(resp, body)
+	}
+
+	return n, nil
+}
+
+// Query makes an InfluxDB Query on the database.
+func (c *httpClient) Query(q influxClient.Query) (*influxClient.Response, error) {
+	response, _, err := c.query(q)
+	return response, err
+}
+
+// query makes a query, and returns the size of the response body read.
+func (c *httpClient) query(q influxClient.Query) (*influxClient.Response, int, error) {
+	resp, err := c.doQuery(q, q.Chunked)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body := &countingReader{r: resp.Body}
+	var response influxClient.Response
+	if q.Chunked {
+		cr := influxClient.NewChunkedResponse(body)
+		for {
+			r, err := cr.NextResponse()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, body.n, err
+			}
+
+			if r == nil {
+				break
+			}
+
+			response.Results = append(response.Results, r.Results...)
+			if r.Err != "" {
+				response.Err = r.Err
+				break
+			}
+		}
+	} else {
+		dec := json.NewDecoder(body)
+		dec.UseNumber()
+		decErr := dec.Decode(&response)
+
+		// ignore this error if we got an invalid status code
+		if decErr == io.EOF && resp.StatusCode != http.StatusOK {
+			decErr = nil
+		}
+		if decErr != nil {
+			return nil, body.n, fmt.Errorf("unable to decode json: received status code %d err: %s",
+				resp.StatusCode, decErr)
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		httpErr := newHTTPError(resp, nil)
+		if response.Error() != nil {
+			httpErr.Message = response.Error().Error()
+		}
+		return &response, body.n, httpErr
+	}
+
+	return &response, body.n, nil
+}
+
+// QueryAsChunk makes an InfluxDB Query on the database and returns the
+// response as a stream of chunks.
+func (c *httpClient) QueryAsChunk(q influxClient.Query) (*influxClient.ChunkedResponse, error) {
+	resp, err := c.doQuery(q, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, newHTTPError(resp, body)
+	}
+
+	return influxClient.NewChunkedResponse(resp.Body), nil
+}
+
+// Close releases any resources the client may be using.
+func (c *httpClient) Close() error {
+	if tr, ok := c.httpClient.Transport.(*http.Transport); ok {
+		tr.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (c *httpClient) doQuery(q influxClient.Query, chunked bool) (*http.Response, error) {
+	jsonParameters, err := json.Marshal(q.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	// the statement and its parameters are sent in the body, so that
+	// passwords and parameter values are not part of the URL, which is
+	// logged by proxies and included in connection errors
+	form := url.Values{}
+	form.Set("q", q.Command)
+	form.Set("params", string(jsonParameters))
+
+	req, err := c.newRequest("POST", "query", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	params := req.URL.Query()
+	params.Set("db", q.Database)
+	if q.RetentionPolicy != "" {
+		params.Set("rp", q.RetentionPolicy)
+	}
+	if q.Precision != "" {
+		params.Set("epoch", q.Precision)
+	}
+	if chunked {
+		params.Set("chunked", "true")
+		if q.ChunkSize > 0 {
+			params.Set("chunk_size", strconv.Itoa(q.ChunkSize))
+		}
+	}
+	req.URL.RawQuery = params.Encode()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, &connectionError{err}
+	}
+
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+// checkResponse verifies the response came from InfluxDb and contains JSON.
+func checkResponse(resp *http.Response) error {
+	// If we lack a X-Influxdb-Version header, then we didn't get a response
+	// from influxdb but instead some other service such as a load balancer.
+	if resp.Header.Get("X-Influxdb-Version") == "" &&
+		resp.StatusCode >= http.StatusInternalServerError {
+		body, _ := ioutil.ReadAll(resp.Body)
+		e := newHTTPError(resp, nil)
+		e.Message = fmt.Sprintf("received status code %d from downstream server",
+			resp.StatusCode)
+		if len(body) > 0 {
+			e.Message += fmt.Sprintf(", with response body: %q", body)
+		}
+		return e
+	}
+
+	if cType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); cType != "application/json" {
+		// Read up to 1kb of the body to help identify downstream errors.
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		e := newHTTPError(resp, nil)
+		if len(body) == 0 {
+			e.Message = fmt.Sprintf("expected json response, got empty body, with status: %v",
+				resp.StatusCode)
+		} else {
+			e.Message = fmt.Sprintf("expected json response, got %q, with status: %v and response body: %q",
+				cType, resp.StatusCode, body)
+		}
+		return e
+	}
+
+	return nil
+}
+
+// newHTTPError creates an *HTTPError from a response. If body contains an
+// InfluxDb JSON error payload, the error text is used as the message.
+func newHTTPError(resp *http.Response, body []byte) *HTTPError {
+	e := &HTTPError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	var payload struct {
+		Err string `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Err != "" {
+		e.Message = payload.Err
+	} else {
+		e.Message = string(bytes.TrimSpace(body))
+	}
+
+	return e
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which can either
+// be a number of seconds or an HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// statusCode returns the HTTP status code of err, or 0 if err is not an
+// *HTTPError.
+func statusCode(err error) int {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode
+	}
+	return 0
+}
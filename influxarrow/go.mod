@@ -0,0 +1,9 @@
+module github.com/cbrake/influxdbhelper/v2/influxarrow
+
+require (
+	github.com/apache/arrow/go/arrow v0.0.0-20211112161151-bc219186db40
+	github.com/cbrake/influxdbhelper/v2 v2.2.0
+	github.com/influxdata/influxdb1-client v0.0.0-20190809212627-fc22c7df067e
+)
+
+go 1.13
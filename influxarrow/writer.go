@@ -0,0 +1,222 @@
+// Package influxarrow writes the results of InfluxDb queries as Apache
+// Arrow record batches. It is a separate package so programs that do not
+// use Arrow do not depend on it.
+package influxarrow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/cbrake/influxdbhelper/v2"
+	influxModels "github.com/influxdata/influxdb1-client/models"
+)
+
+type writer struct {
+	w       io.Writer
+	f       *influxdbhelper.Flattener
+	options influxdbhelper.ExportOptions
+	mem     memory.Allocator
+
+	schema *arrow.Schema
+	ipc    *ipc.Writer
+}
+
+// NewWriter returns an influxdbhelper.RowWriter that writes the rows passed
+// to each call of WriteRows as an Arrow record batch, in the IPC streaming
+// format.
+//
+// The columns are fixed by the options or the first rows written, as for
+// influxdbhelper.NewCSVWriter. The time column is a timestamp, in the unit
+// of the Epoch option if it is s, ms or u, or else in nanoseconds. The type
+// of the other columns is set by the Types option, or else by the first
+// value written to the column: float64 for numbers, bool or string.
+// Columns without values in the first rows are strings.
+//
+// The schema is written with the first rows, or by Close if no rows are
+// written.
+func NewWriter(w io.Writer, options influxdbhelper.ExportOptions) influxdbhelper.RowWriter {
+	return &writer{
+		w:       w,
+		f:       influxdbhelper.NewFlattener(options),
+		options: options,
+		mem:     memory.NewGoAllocator(),
+	}
+}
+
+// timestampUnit returns the Arrow unit of the Epoch option.
+func (w *writer) timestampUnit() arrow.TimeUnit {
+	switch influxdbhelper.PrecisionDuration(w.options.Epoch) {
+	case time.Second:
+		return arrow.Second
+	case time.Millisecond:
+		return arrow.Millisecond
+	case time.Microsecond:
+		return arrow.Microsecond
+	}
+	return arrow.Nanosecond
+}
+
+// columnType returns the Arrow type of a column, given a value in the
+// column, which may be nil.
+func (w *writer) columnType(column string, value interface{}) (arrow.DataType, error) {
+	if column == "time" {
+		tz := "UTC"
+		if w.options.Location != nil {
+			tz = w.options.Location.String()
+		}
+		return &arrow.TimestampType{Unit: w.timestampUnit(), TimeZone: tz}, nil
+	}
+
+	if typ, ok := w.options.Types[column]; ok {
+		switch typ {
+		case "int", "integer":
+			return arrow.PrimitiveTypes.Int64, nil
+		case "unsigned":
+			return arrow.PrimitiveTypes.Uint64, nil
+		case "float":
+			return arrow.PrimitiveTypes.Float64, nil
+		case "bool", "boolean":
+			return arrow.FixedWidthTypes.Boolean, nil
+		case "string":
+			return arrow.BinaryTypes.String, nil
+		}
+		return nil, fmt.Errorf("'%s': unknown type %v", column, typ)
+	}
+
+	switch value.(type) {
+	case float64, json.Number:
+		return arrow.PrimitiveTypes.Float64, nil
+	case bool:
+		return arrow.FixedWidthTypes.Boolean, nil
+	}
+
+	return arrow.BinaryTypes.String, nil
+}
+
+// start determines the schema from the first rows, and writes it.
+func (w *writer) start(rows [][]interface{}) error {
+	columns := w.f.Columns()
+	fields := make([]arrow.Field, len(columns))
+
+	for i, c := range columns {
+		var value interface{}
+		for _, row := range rows {
+			if row[i] != nil {
+				value = row[i]
+				break
+			}
+		}
+
+		typ, err := w.columnType(c, value)
+		if err != nil {
+			return err
+		}
+		fields[i] = arrow.Field{Name: c, Type: typ, Nullable: true}
+	}
+
+	w.schema = arrow.NewSchema(fields, nil)
+	w.ipc = ipc.NewWriter(w.w, ipc.WithSchema(w.schema), ipc.WithAllocator(w.mem))
+	return nil
+}
+
+func (w *writer) WriteRows(series []influxModels.Row) error {
+	rows, err := w.f.Flatten(series)
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	if w.ipc == nil {
+		if err := w.start(rows); err != nil {
+			return err
+		}
+	}
+
+	b := array.NewRecordBuilder(w.mem, w.schema)
+	defer b.Release()
+
+	for _, row := range rows {
+		for i, v := range row {
+			if err := appendValue(b.Field(i), w.schema.Field(i).Type, v); err != nil {
+				return fmt.Errorf("'%s': %v", w.schema.Field(i).Name, err)
+			}
+		}
+	}
+
+	record := b.NewRecord()
+	defer record.Release()
+
+	return w.ipc.Write(record)
+}
+
+// appendValue appends v to the builder of a column of type typ.
+func appendValue(b array.Builder, typ arrow.DataType, v interface{}) error {
+	if v == nil {
+		b.AppendNull()
+		return nil
+	}
+
+	ok := false
+	switch b := b.(type) {
+	case *array.TimestampBuilder:
+		var t time.Time
+		if t, ok = v.(time.Time); ok {
+			unit := typ.(*arrow.TimestampType).Unit.Multiplier()
+			b.Append(arrow.Timestamp(t.UnixNano() / int64(unit)))
+		}
+	case *array.Float64Builder:
+		var f float64
+		if n, isNumber := v.(json.Number); isNumber {
+			if x, err := n.Float64(); err == nil {
+				v = x
+			}
+		}
+		if f, ok = v.(float64); ok {
+			b.Append(f)
+		}
+	case *array.Int64Builder:
+		var i int64
+		if i, ok = v.(int64); ok {
+			b.Append(i)
+		}
+	case *array.Uint64Builder:
+		var u uint64
+		if u, ok = v.(uint64); ok {
+			b.Append(u)
+		}
+	case *array.BooleanBuilder:
+		var x bool
+		if x, ok = v.(bool); ok {
+			b.Append(x)
+		}
+	case *array.StringBuilder:
+		var s string
+		if s, ok = v.(string); ok {
+			b.Append(s)
+		}
+	}
+
+	if !ok {
+		return fmt.Errorf("value %v does not match column type %v", v, typ)
+	}
+
+	return nil
+}
+
+func (w *writer) Close() error {
+	if w.ipc == nil {
+		if err := w.start(nil); err != nil {
+			return err
+		}
+	}
+	return w.ipc.Close()
+}
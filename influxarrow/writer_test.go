@@ -0,0 +1,144 @@
+package influxarrow
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/cbrake/influxdbhelper/v2"
+	influxModels "github.com/influxdata/influxdb1-client/models"
+)
+
+func TestWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, influxdbhelper.ExportOptions{
+		Types: map[string]string{"count": "integer"},
+		Epoch: "ms",
+	})
+
+	series := []influxModels.Row{{
+		Name:    "env",
+		Tags:    map[string]string{"location": "Rm 243"},
+		Columns: []string{"time", "temperature", "count", "ok"},
+		Values: [][]interface{}{
+			{"2019-01-01T00:00:00Z", json.Number("70.5"), json.Number("1"), true},
+			{"2019-01-01T00:00:01Z", json.Number("71"), nil, false},
+		},
+	}}
+
+	// each call is a record batch
+	for i := 0; i < 2; i++ {
+		if err := w.WriteRows(series); err != nil {
+			t.Fatal("Error writing rows: ", err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal("Error closing: ", err)
+	}
+
+	r, err := ipc.NewReader(buf)
+	if err != nil {
+		t.Fatal("Error reading stream: ", err)
+	}
+	defer r.Release()
+
+	exp := []struct {
+		name string
+		typ  arrow.Type
+	}{
+		{"name", arrow.STRING},
+		{"location", arrow.STRING},
+		{"time", arrow.TIMESTAMP},
+		{"temperature", arrow.FLOAT64},
+		{"count", arrow.INT64},
+		{"ok", arrow.BOOL},
+	}
+
+	fields := r.Schema().Fields()
+	if len(fields) != len(exp) {
+		t.Fatalf("%v != %v", len(fields), len(exp))
+	}
+	for i, f := range fields {
+		if f.Name != exp[i].name || f.Type.ID() != exp[i].typ {
+			t.Errorf("%v %v != %v %v", f.Name, f.Type, exp[i].name, exp[i].typ)
+		}
+	}
+
+	records := 0
+	for r.Next() {
+		records++
+		rec := r.Record()
+		if rec.NumRows() != 2 {
+			t.Errorf("%v != 2", rec.NumRows())
+		}
+
+		start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+		if ts := rec.Column(2).(*array.Timestamp).Value(1); int64(ts) != start.Add(time.Second).UnixNano()/1e6 {
+			t.Errorf("%v != %v", ts, start.Add(time.Second).UnixNano()/1e6)
+		}
+
+		if v := rec.Column(3).(*array.Float64).Value(1); v != 71 {
+			t.Errorf("%v != 71", v)
+		}
+
+		count := rec.Column(4).(*array.Int64)
+		if count.Value(0) != 1 || !count.IsNull(1) {
+			t.Errorf("Unexpected count column: %v", count)
+		}
+
+		if v := rec.Column(1).(*array.String).Value(0); v != "Rm 243" {
+			t.Errorf("%v != Rm 243", v)
+		}
+	}
+
+	if records != 2 {
+		t.Errorf("%v != 2", records)
+	}
+}
+
+func TestWriterTypeMismatch(t *testing.T) {
+	w := NewWriter(&bytes.Buffer{}, influxdbhelper.ExportOptions{})
+
+	write := func(v interface{}) error {
+		return w.WriteRows([]influxModels.Row{{
+			Name:    "env",
+			Columns: []string{"time", "state"},
+			Values:  [][]interface{}{{"2019-01-01T00:00:00Z", v}},
+		}})
+	}
+
+	if err := write("on"); err != nil {
+		t.Fatal("Error writing rows: ", err)
+	}
+
+	// the column type is set by the first rows
+	if err := write(json.Number("1")); err == nil {
+		t.Error("Expected type error")
+	}
+}
+
+func TestWriterEmpty(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, influxdbhelper.ExportOptions{Columns: []string{"time", "value"}})
+	if err := w.Close(); err != nil {
+		t.Fatal("Error closing: ", err)
+	}
+
+	r, err := ipc.NewReader(buf)
+	if err != nil {
+		t.Fatal("Error reading stream: ", err)
+	}
+	defer r.Release()
+
+	if n := len(r.Schema().Fields()); n != 2 {
+		t.Errorf("%v != 2", n)
+	}
+	if r.Next() {
+		t.Error("Expected no records")
+	}
+}
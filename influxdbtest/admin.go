@@ -0,0 +1,330 @@
+package influxdbtest
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	influxModels "github.com/influxdata/influxdb1-client/models"
+)
+
+type retentionPolicy struct {
+	name               string
+	duration           time.Duration
+	shardGroupDuration time.Duration
+	replicaN           int
+}
+
+var (
+	reIdent      = `("(?:[^"\\]|\\.)*"|[A-Za-z_]\w*)`
+	reCreateRP   = regexp.MustCompile(`(?is)^CREATE\s+RETENTION\s+POLICY\s+` + reIdent + `\s+ON\s+` + reIdent + `(.*)$`)
+	reAlterRP    = regexp.MustCompile(`(?is)^ALTER\s+RETENTION\s+POLICY\s+` + reIdent + `\s+ON\s+` + reIdent + `(.*)$`)
+	reDropRP     = regexp.MustCompile(`(?is)^DROP\s+RETENTION\s+POLICY\s+` + reIdent + `\s+ON\s+` + reIdent + `\s*$`)
+	reShowRPs    = regexp.MustCompile(`(?is)^SHOW\s+RETENTION\s+POLICIES(?:\s+ON\s+` + reIdent + `)?\s*$`)
+	reRPDuration = regexp.MustCompile(`(?is)(?:^|\s)DURATION\s+(\S+)`)
+	reRPShard    = regexp.MustCompile(`(?is)\sSHARD\s+DURATION\s+(\S+)`)
+	reRPReplica  = regexp.MustCompile(`(?is)\sREPLICATION\s+(\d+)`)
+	reRPDefault  = regexp.MustCompile(`(?is)\sDEFAULT\s*$`)
+	reDropMeas   = regexp.MustCompile(`(?is)^DROP\s+MEASUREMENT\s+` + reIdent + `\s*$`)
+	reDelete     = regexp.MustCompile(`(?is)^DELETE(?:\s+FROM\s+` + reIdent + `)?(?:\s+WHERE\s+(.+?))?\s*$`)
+	reCreateCQ   = regexp.MustCompile(`(?is)^CREATE\s+CONTINUOUS\s+QUERY\s+` + reIdent + `\s+ON\s+` + reIdent + `\s.*\bBEGIN\s.*\sEND$`)
+	reDropCQ     = regexp.MustCompile(`(?is)^DROP\s+CONTINUOUS\s+QUERY\s+` + reIdent + `\s+ON\s+` + reIdent + `\s*$`)
+	reShowCQs    = regexp.MustCompile(`(?is)^SHOW\s+CONTINUOUS\s+QUERIES\s*$`)
+)
+
+// executeAdmin runs database administration statements. ok is false if
+// stmt is not one of them.
+func (s *Server) executeAdmin(ctx *queryContext, stmt string) (rows []influxModels.Row, ok bool, err error) {
+	switch {
+	case reCreateRP.MatchString(stmt):
+		m := reCreateRP.FindStringSubmatch(stmt)
+		return nil, true, s.createRetentionPolicy(unquoteIdent(m[2]), unquoteIdent(m[1]), m[3])
+
+	case reAlterRP.MatchString(stmt):
+		m := reAlterRP.FindStringSubmatch(stmt)
+		return nil, true, s.alterRetentionPolicy(unquoteIdent(m[2]), unquoteIdent(m[1]), m[3])
+
+	case reDropRP.MatchString(stmt):
+		m := reDropRP.FindStringSubmatch(stmt)
+		db, err := s.database(&queryContext{db: unquoteIdent(m[2])})
+		if err != nil {
+			return nil, true, err
+		}
+		name := unquoteIdent(m[1])
+		delete(db.retentionPolicies, name)
+		if db.defaultRP == name {
+			db.defaultRP = ""
+		}
+		return nil, true, nil
+
+	case reShowRPs.MatchString(stmt):
+		m := reShowRPs.FindStringSubmatch(stmt)
+		c := *ctx
+		if m[1] != "" {
+			c.db = unquoteIdent(m[1])
+		}
+		db, err := s.database(&c)
+		if err != nil {
+			return nil, true, err
+		}
+		return []influxModels.Row{db.showRetentionPolicies()}, true, nil
+
+	case reDropMeas.MatchString(stmt):
+		m := reDropMeas.FindStringSubmatch(stmt)
+		db, err := s.database(ctx)
+		if err != nil {
+			return nil, true, err
+		}
+		name := unquoteIdent(m[1])
+		if _, ok := db.fieldTypes[name]; !ok {
+			return nil, true, errors.New("measurement not found")
+		}
+		db.deletePoints(name, nil)
+		return nil, true, nil
+
+	case reCreateCQ.MatchString(stmt):
+		m := reCreateCQ.FindStringSubmatch(stmt)
+		db, err := s.database(&queryContext{db: unquoteIdent(m[2])})
+		if err != nil {
+			return nil, true, err
+		}
+		name := unquoteIdent(m[1])
+		if existing, ok := db.continuousQueries[name]; ok {
+			if existing != stmt {
+				return nil, true, errors.New("continuous query already exists")
+			}
+			return nil, true, nil
+		}
+		db.continuousQueries[name] = stmt
+		return nil, true, nil
+
+	case reDropCQ.MatchString(stmt):
+		m := reDropCQ.FindStringSubmatch(stmt)
+		db, err := s.database(&queryContext{db: unquoteIdent(m[2])})
+		if err != nil {
+			return nil, true, err
+		}
+		name := unquoteIdent(m[1])
+		if _, ok := db.continuousQueries[name]; !ok {
+			return nil, true, errors.New("continuous query not found")
+		}
+		delete(db.continuousQueries, name)
+		return nil, true, nil
+
+	case reShowCQs.MatchString(stmt):
+		var rows []influxModels.Row
+		for _, dbName := range sortedKeys(s.databases) {
+			db := s.databases[dbName]
+			row := influxModels.Row{Name: dbName, Columns: []string{"name", "query"}}
+			names := make([]string, 0, len(db.continuousQueries))
+			for name := range db.continuousQueries {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				row.Values = append(row.Values, []interface{}{name, db.continuousQueries[name]})
+			}
+			rows = append(rows, row)
+		}
+		return rows, true, nil
+
+	case reDelete.MatchString(stmt):
+		m := reDelete.FindStringSubmatch(stmt)
+		db, err := s.database(ctx)
+		if err != nil {
+			return nil, true, err
+		}
+		var conditions []condition
+		if m[2] != "" {
+			conditions, err = parseConditions(ctx, m[2])
+			if err != nil {
+				return nil, true, err
+			}
+		}
+		db.deletePoints(unquoteIdent(m[1]), conditions)
+		return nil, true, nil
+	}
+
+	return nil, false, nil
+}
+
+func (s *Server) createRetentionPolicy(dbName, name, options string) error {
+	db, err := s.database(&queryContext{db: dbName})
+	if err != nil {
+		return err
+	}
+
+	rp := &retentionPolicy{name: name, replicaN: 1}
+	if err := rp.parseOptions(options); err != nil {
+		return err
+	}
+	if rp.shardGroupDuration == 0 {
+		rp.shardGroupDuration = defaultShardGroupDuration(rp.duration)
+	}
+
+	if existing, ok := db.retentionPolicies[name]; ok {
+		if *existing != *rp {
+			return errors.New("retention policy already exists")
+		}
+	} else {
+		db.retentionPolicies[name] = rp
+	}
+
+	if reRPDefault.MatchString(options) {
+		db.defaultRP = name
+	}
+
+	return nil
+}
+
+func (s *Server) alterRetentionPolicy(dbName, name, options string) error {
+	db, err := s.database(&queryContext{db: dbName})
+	if err != nil {
+		return err
+	}
+
+	rp, ok := db.retentionPolicies[name]
+	if !ok {
+		return errors.New("retention policy not found")
+	}
+
+	if err := rp.parseOptions(options); err != nil {
+		return err
+	}
+
+	if reRPDefault.MatchString(options) {
+		db.defaultRP = name
+	}
+
+	return nil
+}
+
+// parseOptions sets the DURATION, REPLICATION and SHARD DURATION options
+// present in options.
+func (rp *retentionPolicy) parseOptions(options string) error {
+	if m := reRPShard.FindStringSubmatch(options); m != nil {
+		d, err := parseDuration(m[1])
+		if err != nil {
+			return err
+		}
+		rp.shardGroupDuration = d
+		// remove so the shard duration is not matched as the duration
+		options = strings.Replace(options, m[0], "", 1)
+	}
+
+	if m := reRPDuration.FindStringSubmatch(options); m != nil {
+		d, err := parseDuration(m[1])
+		if err != nil {
+			return err
+		}
+		rp.duration = d
+	}
+
+	if m := reRPReplica.FindStringSubmatch(options); m != nil {
+		rp.replicaN, _ = strconv.Atoi(m[1])
+	}
+
+	return nil
+}
+
+func defaultShardGroupDuration(d time.Duration) time.Duration {
+	switch {
+	case d == 0 || d > 180*24*time.Hour:
+		return 7 * 24 * time.Hour
+	case d >= 2*24*time.Hour:
+		return 24 * time.Hour
+	}
+	return time.Hour
+}
+
+func (db *database) showRetentionPolicies() influxModels.Row {
+	row := influxModels.Row{
+		Columns: []string{"name", "duration", "shardGroupDuration", "replicaN", "default"},
+	}
+
+	names := make([]string, 0, len(db.retentionPolicies))
+	for name := range db.retentionPolicies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		rp := db.retentionPolicies[name]
+		row.Values = append(row.Values, []interface{}{
+			rp.name, rp.duration.String(), rp.shardGroupDuration.String(),
+			rp.replicaN, name == db.defaultRP,
+		})
+	}
+
+	return row
+}
+
+// deletePoints removes the points of measurement, or all measurements if
+// it is empty, that match all conditions.
+func (db *database) deletePoints(measurement string, conditions []condition) {
+	sel := &selectStatement{conditions: conditions}
+	remaining := make(map[string]bool)
+
+	points := db.points[:0]
+	for _, p := range db.points {
+		name := string(p.Name())
+		if (measurement == "" || name == measurement) && sel.match(p) {
+			continue
+		}
+		remaining[name] = true
+		points = append(points, p)
+	}
+	db.points = points
+
+	for name := range db.fieldTypes {
+		if !remaining[name] {
+			delete(db.fieldTypes, name)
+		}
+	}
+}
+
+var reDurationPart = regexp.MustCompile(`^(\d+)(ns|u|µ|ms|s|m|h|d|w)`)
+
+// parseDuration parses an InfluxQL duration literal such as 1h30m, or INF.
+func parseDuration(s string) (time.Duration, error) {
+	if strings.EqualFold(s, "INF") {
+		return 0, nil
+	}
+
+	units := map[string]time.Duration{
+		"ns": time.Nanosecond,
+		"u":  time.Microsecond,
+		"µ":  time.Microsecond,
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+		"d":  24 * time.Hour,
+		"w":  7 * 24 * time.Hour,
+	}
+
+	var d time.Duration
+	rest := s
+	for rest != "" {
+		m := reDurationPart.FindStringSubmatch(rest)
+		if m == nil {
+			return 0, fmt.Errorf("invalid duration: %v", s)
+		}
+		n, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(n) * units[m[2]]
+		rest = rest[len(m[0]):]
+	}
+
+	if s == "" {
+		return 0, errors.New("invalid duration")
+	}
+
+	return d, nil
+}
@@ -0,0 +1,707 @@
+package influxdbtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	influxModels "github.com/influxdata/influxdb1-client/models"
+)
+
+var fieldTypeNames = map[influxModels.FieldType]string{
+	influxModels.Integer:  "integer",
+	influxModels.Float:    "float",
+	influxModels.Boolean:  "boolean",
+	influxModels.String:   "string",
+	influxModels.Unsigned: "unsigned",
+}
+
+type database struct {
+	name       string
+	points     []influxModels.Point
+	fieldTypes map[string]map[string]influxModels.FieldType
+
+	retentionPolicies map[string]*retentionPolicy
+	defaultRP         string
+	continuousQueries map[string]string
+}
+
+func newDatabase(name string) *database {
+	return &database{
+		name:       name,
+		fieldTypes: make(map[string]map[string]influxModels.FieldType),
+		retentionPolicies: map[string]*retentionPolicy{
+			"autogen": {name: "autogen", shardGroupDuration: 7 * 24 * time.Hour, replicaN: 1},
+		},
+		defaultRP:         "autogen",
+		continuousQueries: make(map[string]string),
+	}
+}
+
+// write stores points in the database. Points with a field whose type
+// conflicts with a previous write are dropped, and the first conflict is
+// returned as an error.
+func (db *database) write(points []influxModels.Point) (dropped int, err error) {
+	for _, p := range points {
+		measurement := string(p.Name())
+		types, ok := db.fieldTypes[measurement]
+		if !ok {
+			types = make(map[string]influxModels.FieldType)
+			db.fieldTypes[measurement] = types
+		}
+
+		var conflict error
+		iter := p.FieldIterator()
+		for iter.Next() {
+			key := string(iter.FieldKey())
+			existing, ok := types[key]
+			if ok && existing != iter.Type() {
+				conflict = fmt.Errorf(
+					"field type conflict: input field %q on measurement %q is type %v, already exists as type %v",
+					key, measurement, fieldTypeNames[iter.Type()], fieldTypeNames[existing])
+				break
+			}
+		}
+
+		if conflict != nil {
+			dropped++
+			if err == nil {
+				err = conflict
+			}
+			continue
+		}
+
+		iter.Reset()
+		for iter.Next() {
+			types[string(iter.FieldKey())] = iter.Type()
+		}
+		db.points = append(db.points, p)
+	}
+
+	return
+}
+
+type queryContext struct {
+	db     string
+	epoch  string
+	params map[string]interface{}
+}
+
+// splitStatements splits a query string into statements separated by
+// semicolons that are not quoted.
+func splitStatements(q string) ([]string, error) {
+	var ret []string
+	var quote rune
+	start := 0
+
+	for i, c := range q {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ';':
+			ret = append(ret, q[start:i])
+			start = i + 1
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("unterminated quoted string")
+	}
+
+	ret = append(ret, q[start:])
+
+	stmts := ret[:0]
+	for _, s := range ret {
+		s = strings.TrimSpace(s)
+		if s != "" {
+			stmts = append(stmts, s)
+		}
+	}
+
+	return stmts, nil
+}
+
+var (
+	reCreateDatabase = regexp.MustCompile(`(?is)^CREATE\s+DATABASE\s+("[^"]*"|\S+)`)
+	reDropDatabase   = regexp.MustCompile(`(?is)^DROP\s+DATABASE\s+("[^"]*"|\S+)\s*$`)
+	reShowDatabases  = regexp.MustCompile(`(?is)^SHOW\s+DATABASES\s*$`)
+	reShowMeasure    = regexp.MustCompile(`(?is)^SHOW\s+MEASUREMENTS\s*$`)
+	reShowFieldKeys  = regexp.MustCompile(`(?is)^SHOW\s+FIELD\s+KEYS(?:\s+FROM\s+("[^"]*"|\S+))?\s*$`)
+	reShowTagKeys    = regexp.MustCompile(`(?is)^SHOW\s+TAG\s+KEYS(?:\s+FROM\s+("[^"]*"|\S+))?\s*$`)
+	reSelect         = regexp.MustCompile(`(?is)^SELECT\s+(.+?)\s+FROM\s+(\S+)` +
+		`(?:\s+WHERE\s+(.+?))?` +
+		`(?:\s+GROUP\s+BY\s+(.+?))?` +
+		`(?:\s+ORDER\s+BY\s+time(?:\s+(ASC|DESC))?)?` +
+		`(?:\s+LIMIT\s+(\d+))?\s*$`)
+)
+
+// execute runs a single statement against the server. The caller must hold
+// s.mu.
+func (s *Server) execute(ctx *queryContext, stmt string) result {
+	rows, err := s.executeRows(ctx, stmt)
+	if err != nil {
+		return result{Err: err.Error()}
+	}
+	return result{Series: rows}
+}
+
+func (s *Server) executeRows(ctx *queryContext, stmt string) ([]influxModels.Row, error) {
+	if m := reCreateDatabase.FindStringSubmatch(stmt); m != nil {
+		s.createDatabase(unquoteIdent(m[1]))
+		return nil, nil
+	}
+
+	if m := reDropDatabase.FindStringSubmatch(stmt); m != nil {
+		delete(s.databases, unquoteIdent(m[1]))
+		return nil, nil
+	}
+
+	if reShowDatabases.MatchString(stmt) {
+		row := influxModels.Row{Name: "databases", Columns: []string{"name"}}
+		for _, name := range sortedKeys(s.databases) {
+			row.Values = append(row.Values, []interface{}{name})
+		}
+		return []influxModels.Row{row}, nil
+	}
+
+	if reShowMeasure.MatchString(stmt) {
+		db, err := s.database(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(db.fieldTypes) == 0 {
+			return nil, nil
+		}
+		row := influxModels.Row{Name: "measurements", Columns: []string{"name"}}
+		for _, name := range sortedKeys(db.fieldTypes) {
+			row.Values = append(row.Values, []interface{}{name})
+		}
+		return []influxModels.Row{row}, nil
+	}
+
+	if m := reShowFieldKeys.FindStringSubmatch(stmt); m != nil {
+		db, err := s.database(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return db.showFieldKeys(unquoteIdent(m[1])), nil
+	}
+
+	if m := reShowTagKeys.FindStringSubmatch(stmt); m != nil {
+		db, err := s.database(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return db.showTagKeys(unquoteIdent(m[1])), nil
+	}
+
+	if m := reSelect.FindStringSubmatch(stmt); m != nil {
+		db, err := s.database(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sel, err := parseSelect(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		return sel.run(ctx, db), nil
+	}
+
+	if rows, ok, err := s.executeAdmin(ctx, stmt); ok {
+		return rows, err
+	}
+
+	if rows, ok, err := s.executeUsers(stmt); ok {
+		return rows, err
+	}
+
+	return nil, fmt.Errorf("influxdbtest: unsupported statement: %v", stmt)
+}
+
+// showFieldKeys returns a series for each measurement, or only for
+// measurement if it is not empty, listing its fields and their types.
+func (db *database) showFieldKeys(measurement string) []influxModels.Row {
+	var ret []influxModels.Row
+
+	for _, name := range sortedKeys(db.fieldTypes) {
+		if measurement != "" && name != measurement {
+			continue
+		}
+
+		row := influxModels.Row{Name: name, Columns: []string{"fieldKey", "fieldType"}}
+		types := db.fieldTypes[name]
+		for _, key := range sortedKeys(types) {
+			row.Values = append(row.Values, []interface{}{key, fieldTypeName(types[key])})
+		}
+		ret = append(ret, row)
+	}
+
+	return ret
+}
+
+// showTagKeys returns a series for each measurement, or only for
+// measurement if it is not empty, listing its tag keys.
+func (db *database) showTagKeys(measurement string) []influxModels.Row {
+	tagKeys := make(map[string]map[string]bool)
+	for _, p := range db.points {
+		name := string(p.Name())
+		if measurement != "" && name != measurement {
+			continue
+		}
+		keys, ok := tagKeys[name]
+		if !ok {
+			keys = make(map[string]bool)
+			tagKeys[name] = keys
+		}
+		for _, t := range p.Tags() {
+			keys[string(t.Key)] = true
+		}
+	}
+
+	var ret []influxModels.Row
+	for _, name := range sortedKeys(tagKeys) {
+		keys := tagKeys[name]
+		if len(keys) == 0 {
+			continue
+		}
+		row := influxModels.Row{Name: name, Columns: []string{"tagKey"}}
+		for _, key := range sortedKeys(keys) {
+			row.Values = append(row.Values, []interface{}{key})
+		}
+		ret = append(ret, row)
+	}
+
+	return ret
+}
+
+func fieldTypeName(t influxModels.FieldType) string {
+	switch t {
+	case influxModels.Integer:
+		return "integer"
+	case influxModels.Unsigned:
+		return "unsigned"
+	case influxModels.Boolean:
+		return "boolean"
+	case influxModels.String:
+		return "string"
+	}
+	return "float"
+}
+
+func (s *Server) database(ctx *queryContext) (*database, error) {
+	if ctx.db == "" {
+		return nil, errors.New("database name required")
+	}
+	db, ok := s.databases[ctx.db]
+	if !ok {
+		return nil, fmt.Errorf("database not found: %v", ctx.db)
+	}
+	return db, nil
+}
+
+type condition struct {
+	key   string
+	op    string
+	value interface{}
+}
+
+type selectStatement struct {
+	columns     []string
+	measurement string
+	conditions  []condition
+	groupBy     []string
+	descending  bool
+	limit       int
+}
+
+func parseSelect(ctx *queryContext, m []string) (*selectStatement, error) {
+	sel := &selectStatement{}
+
+	for _, c := range strings.Split(m[1], ",") {
+		sel.columns = append(sel.columns, unquoteIdent(strings.TrimSpace(c)))
+	}
+
+	parts := strings.Split(m[2], ".")
+	sel.measurement = unquoteIdent(parts[len(parts)-1])
+
+	if m[3] != "" {
+		conditions, err := parseConditions(ctx, m[3])
+		if err != nil {
+			return nil, err
+		}
+		sel.conditions = conditions
+	}
+
+	if m[4] != "" {
+		for _, g := range strings.Split(m[4], ",") {
+			g = unquoteIdent(strings.TrimSpace(g))
+			if strings.HasPrefix(strings.ToLower(g), "time(") {
+				return nil, errors.New("influxdbtest: GROUP BY time() is not supported")
+			}
+			sel.groupBy = append(sel.groupBy, g)
+		}
+	}
+
+	sel.descending = strings.EqualFold(m[5], "DESC")
+
+	if m[6] != "" {
+		sel.limit, _ = strconv.Atoi(m[6])
+	}
+
+	return sel, nil
+}
+
+var reCondition = regexp.MustCompile(`^\s*("[^"]*"|[A-Za-z_][\w.]*)\s*(=|!=|<>|<=|>=|<|>)\s*('(?:[^'\\]|\\.)*'|\$\w+|[-+]?[\d.]+(?:e[-+]?\d+)?|true|false)\s*(?i:(AND)\s+|$)`)
+
+func parseConditions(ctx *queryContext, where string) ([]condition, error) {
+	var ret []condition
+
+	for where != "" {
+		m := reCondition.FindStringSubmatch(where)
+		if m == nil {
+			return nil, fmt.Errorf("influxdbtest: unsupported condition: %v", where)
+		}
+
+		value, err := parseLiteral(ctx, m[3])
+		if err != nil {
+			return nil, err
+		}
+
+		op := m[2]
+		if op == "<>" {
+			op = "!="
+		}
+
+		ret = append(ret, condition{unquoteIdent(m[1]), op, value})
+		where = where[len(m[0]):]
+	}
+
+	return ret, nil
+}
+
+func parseLiteral(ctx *queryContext, lit string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(lit, "'"):
+		s := lit[1 : len(lit)-1]
+		s = strings.Replace(s, `\'`, `'`, -1)
+		return strings.Replace(s, `\\`, `\`, -1), nil
+	case strings.HasPrefix(lit, "$"):
+		v, ok := ctx.params[lit[1:]]
+		if !ok {
+			return nil, fmt.Errorf("missing parameter: %v", lit[1:])
+		}
+		if n, ok := v.(json.Number); ok {
+			return n.Float64()
+		}
+		return v, nil
+	case lit == "true":
+		return true, nil
+	case lit == "false":
+		return false, nil
+	}
+
+	return strconv.ParseFloat(lit, 64)
+}
+
+func (sel *selectStatement) run(ctx *queryContext, db *database) []influxModels.Row {
+	var points []influxModels.Point
+	tagKeys := make(map[string]bool)
+
+	for _, p := range db.points {
+		if string(p.Name()) != sel.measurement {
+			continue
+		}
+		for _, t := range p.Tags() {
+			tagKeys[string(t.Key)] = true
+		}
+		if sel.match(p) {
+			points = append(points, p)
+		}
+	}
+
+	if len(points) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		if sel.descending {
+			return points[i].Time().After(points[j].Time())
+		}
+		return points[i].Time().Before(points[j].Time())
+	})
+
+	groupBy := sel.groupBy
+	if len(groupBy) == 1 && groupBy[0] == "*" {
+		groupBy = sortedKeys(tagKeys)
+	}
+	grouped := make(map[string]bool)
+	for _, g := range groupBy {
+		grouped[g] = true
+	}
+
+	columns := []string{"time"}
+	for _, c := range sel.columns {
+		if c != "*" {
+			columns = append(columns, c)
+			continue
+		}
+		keys := make(map[string]bool)
+		for k := range db.fieldTypes[sel.measurement] {
+			keys[k] = true
+		}
+		for k := range tagKeys {
+			if !grouped[k] {
+				keys[k] = true
+			}
+		}
+		columns = append(columns, sortedKeys(keys)...)
+	}
+
+	series := make(map[string]*influxModels.Row)
+	for _, p := range points {
+		tags := make(map[string]string)
+		for _, g := range groupBy {
+			tags[g] = p.Tags().GetString(g)
+		}
+		key := string(influxModels.MakeKey(nil, influxModels.NewTags(tags)))
+
+		row, ok := series[key]
+		if !ok {
+			row = &influxModels.Row{Name: sel.measurement, Columns: columns}
+			if len(groupBy) > 0 {
+				row.Tags = tags
+			}
+			series[key] = row
+		}
+
+		if values := sel.values(ctx, p, columns); values != nil {
+			row.Values = append(row.Values, values)
+		}
+	}
+
+	var ret []influxModels.Row
+	for _, key := range sortedKeys(series) {
+		row := series[key]
+		if sel.limit > 0 && len(row.Values) > sel.limit {
+			row.Values = row.Values[:sel.limit]
+		}
+		if len(row.Values) > 0 {
+			ret = append(ret, *row)
+		}
+	}
+
+	return ret
+}
+
+func (sel *selectStatement) match(p influxModels.Point) bool {
+	fields, _ := p.Fields()
+
+	for _, c := range sel.conditions {
+		var v interface{}
+		switch {
+		case c.key == "time":
+			v = p.Time()
+		case p.HasTag([]byte(c.key)):
+			v = p.Tags().GetString(c.key)
+		default:
+			v = fields[c.key]
+		}
+
+		if !compare(v, c.op, c.value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// values returns the values of the point for columns, or nil if the point
+// has none of the selected fields.
+func (sel *selectStatement) values(ctx *queryContext, p influxModels.Point, columns []string) []interface{} {
+	fields, _ := p.Fields()
+	ret := make([]interface{}, len(columns))
+	hasField := false
+
+	for i, c := range columns {
+		switch {
+		case c == "time":
+			ret[i] = formatTime(p.Time(), ctx.epoch)
+		case p.HasTag([]byte(c)):
+			ret[i] = p.Tags().GetString(c)
+		default:
+			if v, ok := fields[c]; ok {
+				ret[i] = v
+				hasField = true
+			}
+		}
+	}
+
+	if !hasField {
+		return nil
+	}
+
+	return ret
+}
+
+func compare(v interface{}, op string, target interface{}) bool {
+	var c int
+
+	switch v := v.(type) {
+	case time.Time:
+		var t time.Time
+		switch target := target.(type) {
+		case string:
+			var err error
+			t, err = time.Parse(time.RFC3339Nano, target)
+			if err != nil {
+				return false
+			}
+		case float64:
+			t = time.Unix(0, int64(target))
+		default:
+			return false
+		}
+		c = compareOrdered(v.UnixNano(), t.UnixNano())
+	case string:
+		s, ok := target.(string)
+		if !ok {
+			return false
+		}
+		c = strings.Compare(v, s)
+	case bool:
+		b, ok := target.(bool)
+		if !ok || (op != "=" && op != "!=") {
+			return false
+		}
+		c = 1
+		if v == b {
+			c = 0
+		}
+	case int64, uint64, float64:
+		f, ok := target.(float64)
+		if !ok {
+			return false
+		}
+		c = compareFloat(toFloat(v), f)
+	default:
+		return false
+	}
+
+	switch op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+
+	return false
+}
+
+func compareOrdered(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+func formatTime(t time.Time, epoch string) interface{} {
+	var d time.Duration
+	switch epoch {
+	case "h":
+		d = time.Hour
+	case "m":
+		d = time.Minute
+	case "s":
+		d = time.Second
+	case "ms":
+		d = time.Millisecond
+	case "u", "µ":
+		d = time.Microsecond
+	case "ns", "n":
+		d = time.Nanosecond
+	default:
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return t.UnixNano() / int64(d)
+}
+
+func unquoteIdent(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func sortedKeys(m interface{}) []string {
+	var ret []string
+	switch m := m.(type) {
+	case map[string]*database:
+		for k := range m {
+			ret = append(ret, k)
+		}
+	case map[string]map[string]influxModels.FieldType:
+		for k := range m {
+			ret = append(ret, k)
+		}
+	case map[string]influxModels.FieldType:
+		for k := range m {
+			ret = append(ret, k)
+		}
+	case map[string]map[string]bool:
+		for k := range m {
+			ret = append(ret, k)
+		}
+	case map[string]bool:
+		for k := range m {
+			ret = append(ret, k)
+		}
+	case map[string]*influxModels.Row:
+		for k := range m {
+			ret = append(ret, k)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,356 @@
+// Package influxdbtest provides a local stand-in for an InfluxDb 1.x server
+// that can be used to exercise the influxdbhelper HTTP client in tests
+// without a running database.
+package influxdbtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	influxModels "github.com/influxdata/influxdb1-client/models"
+)
+
+// Version is reported in the X-Influxdb-Version header of every response.
+const Version = "1.8.0-influxdbtest"
+
+// Request is a record of a request received by the Server.
+type Request struct {
+	Method string
+	Path   string
+	// Query contains the URL query parameters, and the form values of a
+	// form encoded body.
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+type failure struct {
+	status  int
+	message string
+}
+
+// Server is a fake InfluxDb server that speaks the /ping, /write and /query
+// HTTP endpoints. Points written to the server are stored in memory and can
+// be read back with a small subset of InfluxQL.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	databases map[string]*database
+	users     map[string]*user
+	failures  []failure
+	requests  []Request
+}
+
+// NewServer starts and returns a new Server. The caller should call Close
+// when finished to shut it down.
+func NewServer() *Server {
+	// unsigned fields are written with a u suffix
+	influxModels.EnableUintSupport()
+
+	s := &Server{
+		databases: make(map[string]*database),
+		users:     make(map[string]*user),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", s.handlePing)
+	mux.HandleFunc("/write", s.handleWrite)
+	mux.HandleFunc("/query", s.handleQuery)
+
+	s.Server = httptest.NewServer(s.record(mux))
+	return s
+}
+
+// CreateDatabase creates a database on the server if it does not already
+// exist.
+func (s *Server) CreateDatabase(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.createDatabase(name)
+}
+
+func (s *Server) createDatabase(name string) *database {
+	db, ok := s.databases[name]
+	if !ok {
+		db = newDatabase(name)
+		s.databases[name] = db
+	}
+	return db
+}
+
+// Points returns a copy of all points written to a database.
+func (s *Server) Points(db string) []influxModels.Point {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.databases[db]
+	if !ok {
+		return nil
+	}
+
+	ret := make([]influxModels.Point, len(d.points))
+	copy(ret, d.points)
+	return ret
+}
+
+// FailNext causes the next request received by the server to fail with
+// status and an InfluxDb style error payload containing message. Calls
+// are queued, so calling FailNext n times fails the next n requests.
+func (s *Server) FailNext(status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure{status, message})
+}
+
+// Requests returns all requests received by the server so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ret := make([]Request, len(s.requests))
+	copy(ret, s.requests)
+	return ret
+}
+
+func (s *Server) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		query := r.URL.Query()
+		if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+			form, _ := url.ParseQuery(string(body))
+			for k, v := range form {
+				query[k] = append(query[k], v...)
+			}
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  query,
+			Header: r.Header.Clone(),
+			Body:   body,
+		})
+		var f *failure
+		if len(s.failures) > 0 {
+			f = &s.failures[0]
+			s.failures = s.failures[1:]
+		}
+		s.mu.Unlock()
+
+		w.Header().Set("X-Influxdb-Version", Version)
+
+		if f != nil {
+			writeError(w, f.status, f.message)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handlePing(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleWrite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	dbName := r.URL.Query().Get("db")
+	if dbName == "" {
+		writeError(w, http.StatusBadRequest, "database is required")
+		return
+	}
+
+	var reader io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		reader = gz
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	points, parseErr := influxModels.ParsePointsWithPrecision(body,
+		time.Now().UTC(), r.URL.Query().Get("precision"))
+
+	s.mu.Lock()
+	db, ok := s.databases[dbName]
+	if !ok {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound,
+			fmt.Sprintf("database not found: %q", dbName))
+		return
+	}
+
+	// like InfluxDb, the lines that parse are stored, unless none do
+	if parseErr != nil && len(points) == 0 {
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, parseErr.Error())
+		return
+	}
+
+	dropped, writeErr := db.write(points)
+	s.mu.Unlock()
+
+	if writeErr != nil {
+		writeError(w, http.StatusBadRequest,
+			fmt.Sprintf("partial write: %v dropped=%v", writeErr, dropped))
+		return
+	}
+
+	if parseErr != nil {
+		writeError(w, http.StatusBadRequest, "partial write: "+parseErr.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	q := r.Form.Get("q")
+	if q == "" {
+		writeError(w, http.StatusBadRequest, `missing required parameter "q"`)
+		return
+	}
+
+	params := make(map[string]interface{})
+	if p := r.Form.Get("params"); p != "" {
+		dec := json.NewDecoder(bytes.NewReader([]byte(p)))
+		dec.UseNumber()
+		if err := dec.Decode(&params); err != nil {
+			writeError(w, http.StatusBadRequest,
+				"error parsing query parameters: "+err.Error())
+			return
+		}
+	}
+
+	ctx := &queryContext{
+		db:     r.Form.Get("db"),
+		epoch:  r.Form.Get("epoch"),
+		params: params,
+	}
+
+	stmts, err := splitStatements(q)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "error parsing query: "+err.Error())
+		return
+	}
+
+	results := make([]result, len(stmts))
+	s.mu.Lock()
+	for i, stmt := range stmts {
+		results[i] = s.execute(ctx, stmt)
+		results[i].StatementID = i
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// responses are compressed if the client accepts gzip, as by InfluxDb
+	var out io.Writer = w
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+
+	if r.Form.Get("chunked") != "true" {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(out).Encode(response{Results: results})
+		return
+	}
+
+	chunkSize := 10000
+	if c, err := strconv.Atoi(r.Form.Get("chunk_size")); err == nil && c > 0 {
+		chunkSize = c
+	}
+
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(out)
+	for _, res := range results {
+		for _, chunk := range res.chunks(chunkSize) {
+			enc.Encode(response{Results: []result{chunk}})
+		}
+	}
+}
+
+type result struct {
+	StatementID int                `json:"statement_id"`
+	Series      []influxModels.Row `json:"series,omitempty"`
+	Partial     bool               `json:"partial,omitempty"`
+	Err         string             `json:"error,omitempty"`
+}
+
+// chunks splits a result into results containing at most size values each.
+func (r result) chunks(size int) []result {
+	if r.Err != "" || len(r.Series) == 0 {
+		return []result{r}
+	}
+
+	var ret []result
+	for _, row := range r.Series {
+		values := row.Values
+		for {
+			chunk := row
+			chunk.Values = values
+			if len(values) > size {
+				chunk.Values = values[:size]
+				chunk.Partial = true
+			}
+			values = values[len(chunk.Values):]
+			ret = append(ret, result{
+				StatementID: r.StatementID,
+				Series:      []influxModels.Row{chunk},
+				Partial:     true,
+			})
+			if len(values) == 0 {
+				break
+			}
+		}
+	}
+
+	ret[len(ret)-1].Partial = false
+	return ret
+}
+
+type response struct {
+	Results []result `json:"results,omitempty"`
+	Err     string   `json:"error,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response{Err: message})
+}
@@ -0,0 +1,312 @@
+package influxdbtest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+func newTestClient(t *testing.T, s *Server) influxClient.Client {
+	c, err := influxClient.NewHTTPClient(influxClient.HTTPConfig{Addr: s.URL})
+	if err != nil {
+		t.Fatal("Error creating client: ", err)
+	}
+	return c
+}
+
+func writeLines(t *testing.T, c influxClient.Client, db string, n int) {
+	bp, _ := influxClient.NewBatchPoints(influxClient.BatchPointsConfig{Database: db})
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		location := "rm1"
+		if i%2 == 1 {
+			location = "rm2"
+		}
+		pt, err := influxClient.NewPoint("test",
+			map[string]string{"location": location},
+			map[string]interface{}{"temperature": float64(i)},
+			start.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatal("Error creating point: ", err)
+		}
+		bp.AddPoint(pt)
+	}
+	if err := c.Write(bp); err != nil {
+		t.Fatal("Error writing points: ", err)
+	}
+}
+
+func TestServerPing(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	_, version, err := newTestClient(t, s).Ping(0)
+	if err != nil {
+		t.Fatal("Error pinging: ", err)
+	}
+
+	if version != Version {
+		t.Errorf("%v != %v", version, Version)
+	}
+}
+
+func TestServerWriteDatabaseNotFound(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	bp, _ := influxClient.NewBatchPoints(influxClient.BatchPointsConfig{Database: "nodb"})
+	pt, _ := influxClient.NewPoint("test", nil, map[string]interface{}{"v": 1.0}, time.Now())
+	bp.AddPoint(pt)
+
+	err := newTestClient(t, s).Write(bp)
+	if err == nil || !strings.Contains(err.Error(), "database not found") {
+		t.Error("Expected database not found error, got: ", err)
+	}
+}
+
+func TestServerWriteParseError(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	post := func(body string) (int, string) {
+		resp, err := http.Post(s.URL+"/write?db=db", "text/plain", strings.NewReader(body))
+		if err != nil {
+			t.Fatal("Error writing: ", err)
+		}
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(msg)
+	}
+
+	// the lines that parse are stored
+	status, msg := post("test v=1 1546300800000000000\ntest v= 1546300801000000000\n")
+	if status != http.StatusBadRequest || !strings.Contains(msg, "partial write: ") {
+		t.Errorf("Unexpected response: %v %v", status, msg)
+	}
+
+	if n := len(s.Points("db")); n != 1 {
+		t.Errorf("%v != 1", n)
+	}
+
+	// nothing is stored if no line parses
+	status, msg = post("test v= 1546300802000000000\n")
+	if status != http.StatusBadRequest || strings.Contains(msg, "partial write") {
+		t.Errorf("Unexpected response: %v %v", status, msg)
+	}
+
+	if n := len(s.Points("db")); n != 1 {
+		t.Errorf("%v != 1", n)
+	}
+}
+
+func TestServerWriteQuery(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c := newTestClient(t, s)
+	writeLines(t, c, "db", 4)
+
+	if len(s.Points("db")) != 4 {
+		t.Fatalf("Expected 4 points, got %v", len(s.Points("db")))
+	}
+
+	resp, err := c.Query(influxClient.NewQuery(
+		`SELECT * FROM test WHERE location = 'rm2' ORDER BY time DESC LIMIT 1`, "db", ""))
+	if err != nil {
+		t.Fatal("Query error: ", err)
+	}
+	if resp.Error() != nil {
+		t.Fatal("Query error: ", resp.Error())
+	}
+
+	series := resp.Results[0].Series
+	if len(series) != 1 || len(series[0].Values) != 1 {
+		t.Fatalf("Unexpected result: %+v", series)
+	}
+
+	columns := strings.Join(series[0].Columns, ",")
+	if columns != "time,location,temperature" {
+		t.Errorf("Unexpected columns: %v", columns)
+	}
+
+	if series[0].Values[0][2].(interface{ String() string }).String() != "3" {
+		t.Errorf("Unexpected values: %v", series[0].Values)
+	}
+}
+
+func TestServerQueryGroupBy(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c := newTestClient(t, s)
+	writeLines(t, c, "db", 4)
+
+	resp, err := c.Query(influxClient.NewQuery(
+		`SELECT temperature FROM test GROUP BY location`, "db", ""))
+	if err != nil {
+		t.Fatal("Query error: ", err)
+	}
+
+	series := resp.Results[0].Series
+	if len(series) != 2 {
+		t.Fatalf("Expected 2 series, got %v", len(series))
+	}
+
+	if series[1].Tags["location"] != "rm2" || len(series[1].Values) != 2 {
+		t.Errorf("Unexpected series: %+v", series[1])
+	}
+}
+
+func TestServerQueryChunked(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c := newTestClient(t, s)
+	writeLines(t, c, "db", 5)
+
+	q := influxClient.NewQuery(`SELECT * FROM test`, "db", "")
+	q.ChunkSize = 2
+
+	cr, err := c.QueryAsChunk(q)
+	if err != nil {
+		t.Fatal("Query error: ", err)
+	}
+	defer cr.Close()
+
+	chunks, values := 0, 0
+	for {
+		r, err := cr.NextResponse()
+		if err != nil {
+			break
+		}
+		chunks++
+		values += len(r.Results[0].Series[0].Values)
+	}
+
+	if chunks != 3 || values != 5 {
+		t.Errorf("Expected 3 chunks with 5 values, got %v with %v", chunks, values)
+	}
+}
+
+func TestServerQueryError(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	resp, err := newTestClient(t, s).Query(
+		influxClient.NewQuery(`SELECT * FROM test`, "nodb", ""))
+	if err != nil {
+		t.Fatal("Query error: ", err)
+	}
+
+	if resp.Error() == nil || !strings.Contains(resp.Error().Error(), "database not found") {
+		t.Error("Expected database not found error, got: ", resp.Error())
+	}
+}
+
+func TestServerFieldTypeConflict(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c := newTestClient(t, s)
+	writeLines(t, c, "db", 1)
+
+	bp, _ := influxClient.NewBatchPoints(influxClient.BatchPointsConfig{Database: "db"})
+	pt, _ := influxClient.NewPoint("test", nil, map[string]interface{}{"temperature": "hot"}, time.Now())
+	bp.AddPoint(pt)
+
+	err := c.Write(bp)
+	if err == nil || !strings.Contains(err.Error(), "field type conflict") {
+		t.Error("Expected field type conflict, got: ", err)
+	}
+}
+
+func TestServerFailNext(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+	s.FailNext(http.StatusServiceUnavailable, "unavailable")
+
+	c := newTestClient(t, s)
+	resp, err := c.Query(influxClient.NewQuery(`SHOW DATABASES`, "", ""))
+	if err == nil && resp.Error() == nil {
+		t.Error("Expected error")
+	}
+
+	resp, err = c.Query(influxClient.NewQuery(`SHOW DATABASES`, "", ""))
+	if err != nil {
+		t.Fatal("Query error: ", err)
+	}
+
+	if resp.Results[0].Series[0].Values[0][0] != "db" {
+		t.Errorf("Unexpected result: %+v", resp.Results)
+	}
+
+	if len(s.Requests()) != 2 {
+		t.Errorf("Expected 2 requests, got %v", len(s.Requests()))
+	}
+}
+
+func TestServerRetentionPolicies(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c := newTestClient(t, s)
+	for _, q := range []string{
+		`CREATE RETENTION POLICY "day" ON "db" DURATION 1d REPLICATION 1 SHARD DURATION 1h DEFAULT`,
+		`ALTER RETENTION POLICY "day" ON "db" DURATION 2d`,
+	} {
+		resp, err := c.Query(influxClient.NewQuery(q, "db", ""))
+		if err != nil || resp.Error() != nil {
+			t.Fatalf("Query error: %v, %v", err, resp.Error())
+		}
+	}
+
+	resp, err := c.Query(influxClient.NewQuery(`SHOW RETENTION POLICIES ON "db"`, "", ""))
+	if err != nil || resp.Error() != nil {
+		t.Fatalf("Query error: %v, %v", err, resp.Error())
+	}
+
+	values := resp.Results[0].Series[0].Values
+	if len(values) != 2 || values[1][0] != "day" || values[1][1] != "48h0m0s" ||
+		values[1][2] != "1h0m0s" || values[1][4] != true || values[0][4] != false {
+		t.Errorf("Unexpected retention policies: %v", values)
+	}
+}
+
+func TestServerDelete(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c := newTestClient(t, s)
+	writeLines(t, c, "db", 4)
+
+	resp, err := c.Query(influxClient.NewQuery(`DELETE FROM "test" WHERE "location" = 'rm1'`, "db", ""))
+	if err != nil || resp.Error() != nil {
+		t.Fatalf("Query error: %v, %v", err, resp.Error())
+	}
+
+	if n := len(s.Points("db")); n != 2 {
+		t.Errorf("Expected 2 points, got %v", n)
+	}
+
+	resp, _ = c.Query(influxClient.NewQuery(`DROP MEASUREMENT "test"`, "db", ""))
+	if resp.Error() != nil || len(s.Points("db")) != 0 {
+		t.Errorf("Error dropping measurement: %v", resp.Error())
+	}
+
+	resp, _ = c.Query(influxClient.NewQuery(`DROP MEASUREMENT "test"`, "db", ""))
+	if resp.Error() == nil {
+		t.Error("Expected measurement not found error")
+	}
+}
@@ -0,0 +1,162 @@
+package influxdbtest
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+
+	influxModels "github.com/influxdata/influxdb1-client/models"
+)
+
+// privilege is a bit set of read and write privileges on a database.
+type privilege int
+
+const (
+	readPrivilege  privilege = 1
+	writePrivilege privilege = 2
+	allPrivileges            = readPrivilege | writePrivilege
+)
+
+var privilegeNames = map[privilege]string{
+	0:              "NO PRIVILEGES",
+	readPrivilege:  "READ",
+	writePrivilege: "WRITE",
+	allPrivileges:  "ALL PRIVILEGES",
+}
+
+// user is a user account. Users are stored, but the server does not
+// authenticate requests.
+type user struct {
+	password   string
+	admin      bool
+	privileges map[string]privilege
+}
+
+var (
+	reString     = `('(?:[^'\\]|\\.)*')`
+	rePrivilege  = `(READ|WRITE|ALL(?:\s+PRIVILEGES)?)`
+	reCreateUser = regexp.MustCompile(`(?is)^CREATE\s+USER\s+` + reIdent + `\s+WITH\s+PASSWORD\s+` + reString + `(\s+WITH\s+ALL\s+PRIVILEGES)?\s*$`)
+	reDropUser   = regexp.MustCompile(`(?is)^DROP\s+USER\s+` + reIdent + `\s*$`)
+	reSetPasswd  = regexp.MustCompile(`(?is)^SET\s+PASSWORD\s+FOR\s+` + reIdent + `\s*=\s*` + reString + `\s*$`)
+	reGrant      = regexp.MustCompile(`(?is)^GRANT\s+` + rePrivilege + `(?:\s+ON\s+` + reIdent + `)?\s+TO\s+` + reIdent + `\s*$`)
+	reRevoke     = regexp.MustCompile(`(?is)^REVOKE\s+` + rePrivilege + `(?:\s+ON\s+` + reIdent + `)?\s+FROM\s+` + reIdent + `\s*$`)
+	reShowUsers  = regexp.MustCompile(`(?is)^SHOW\s+USERS\s*$`)
+	reShowGrants = regexp.MustCompile(`(?is)^SHOW\s+GRANTS\s+FOR\s+` + reIdent + `\s*$`)
+)
+
+func parsePrivilege(s string) privilege {
+	switch strings.ToUpper(s) {
+	case "READ":
+		return readPrivilege
+	case "WRITE":
+		return writePrivilege
+	}
+	return allPrivileges
+}
+
+func unquoteString(s string) string {
+	s = s[1 : len(s)-1]
+	s = strings.Replace(s, `\'`, `'`, -1)
+	return strings.Replace(s, `\\`, `\`, -1)
+}
+
+// executeUsers runs user management statements. ok is false if stmt is not
+// one of them.
+func (s *Server) executeUsers(stmt string) (rows []influxModels.Row, ok bool, err error) {
+	switch {
+	case reCreateUser.MatchString(stmt):
+		m := reCreateUser.FindStringSubmatch(stmt)
+		name, password, admin := unquoteIdent(m[1]), unquoteString(m[2]), m[3] != ""
+		if u, ok := s.users[name]; ok {
+			if u.password != password || u.admin != admin {
+				return nil, true, errors.New("user already exists")
+			}
+			return nil, true, nil
+		}
+		s.users[name] = &user{password: password, admin: admin, privileges: make(map[string]privilege)}
+		return nil, true, nil
+
+	case reDropUser.MatchString(stmt):
+		name := unquoteIdent(reDropUser.FindStringSubmatch(stmt)[1])
+		if _, ok := s.users[name]; !ok {
+			return nil, true, errors.New("user not found")
+		}
+		delete(s.users, name)
+		return nil, true, nil
+
+	case reSetPasswd.MatchString(stmt):
+		m := reSetPasswd.FindStringSubmatch(stmt)
+		u, err := s.user(m[1])
+		if err != nil {
+			return nil, true, err
+		}
+		u.password = unquoteString(m[2])
+		return nil, true, nil
+
+	case reGrant.MatchString(stmt):
+		m := reGrant.FindStringSubmatch(stmt)
+		u, err := s.user(m[3])
+		if err != nil {
+			return nil, true, err
+		}
+		if m[2] == "" {
+			u.admin = true
+		} else {
+			u.privileges[unquoteIdent(m[2])] = parsePrivilege(m[1])
+		}
+		return nil, true, nil
+
+	case reRevoke.MatchString(stmt):
+		m := reRevoke.FindStringSubmatch(stmt)
+		u, err := s.user(m[3])
+		if err != nil {
+			return nil, true, err
+		}
+		if m[2] == "" {
+			u.admin = false
+		} else {
+			db := unquoteIdent(m[2])
+			u.privileges[db] &^= parsePrivilege(m[1])
+		}
+		return nil, true, nil
+
+	case reShowUsers.MatchString(stmt):
+		row := influxModels.Row{Columns: []string{"user", "admin"}}
+		names := make([]string, 0, len(s.users))
+		for name := range s.users {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			row.Values = append(row.Values, []interface{}{name, s.users[name].admin})
+		}
+		return []influxModels.Row{row}, true, nil
+
+	case reShowGrants.MatchString(stmt):
+		u, err := s.user(reShowGrants.FindStringSubmatch(stmt)[1])
+		if err != nil {
+			return nil, true, err
+		}
+		row := influxModels.Row{Columns: []string{"database", "privilege"}}
+		dbs := make([]string, 0, len(u.privileges))
+		for db := range u.privileges {
+			dbs = append(dbs, db)
+		}
+		sort.Strings(dbs)
+		for _, db := range dbs {
+			row.Values = append(row.Values, []interface{}{db, privilegeNames[u.privileges[db]]})
+		}
+		return []influxModels.Row{row}, true, nil
+	}
+
+	return nil, false, nil
+}
+
+func (s *Server) user(ident string) (*user, error) {
+	u, ok := s.users[unquoteIdent(ident)]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+	return u, nil
+}
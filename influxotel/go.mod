@@ -0,0 +1,19 @@
+module github.com/cbrake/influxdbhelper/v2/influxotel
+
+require (
+	github.com/cbrake/influxdbhelper/v2 v2.2.0
+	go.opentelemetry.io/otel v1.16.0
+	go.opentelemetry.io/otel/sdk v1.16.0
+	go.opentelemetry.io/otel/trace v1.16.0
+)
+
+require (
+	github.com/go-logr/logr v1.2.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/influxdata/influxdb1-client v0.0.0-20190809212627-fc22c7df067e // indirect
+	github.com/mitchellh/mapstructure v1.1.2 // indirect
+	go.opentelemetry.io/otel/metric v1.16.0 // indirect
+	golang.org/x/sys v0.8.0 // indirect
+)
+
+go 1.19
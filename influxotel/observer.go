@@ -0,0 +1,88 @@
+// Package influxotel records the operations of an influxdbhelper client as
+// OpenTelemetry spans.
+//
+//	c.UseObserver(influxotel.NewObserver(tracerProvider))
+//
+// Client operations do not take a context, so the spans have no parent:
+// each is the root span of a new trace, and is not part of the trace of the
+// caller. Spans can be matched with the caller's trace by their start and
+// end times, which are those of the operation.
+package influxotel
+
+import (
+	"context"
+
+	"github.com/cbrake/influxdbhelper/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer that creates spans.
+const TracerName = "github.com/cbrake/influxdbhelper/v2/influxotel"
+
+// Attributes of the spans, in addition to db.system, db.name and
+// db.statement.
+const (
+	MeasurementKey = attribute.Key("influxdb.measurement")
+	PointsKey      = attribute.Key("influxdb.points")
+	RowsKey        = attribute.Key("influxdb.rows")
+	BytesKey       = attribute.Key("influxdb.bytes")
+)
+
+// Observer is an influxdbhelper.Observer that creates a span named
+// influxdb.<operation> for each operation. The spans have no parent.
+type Observer struct {
+	tracer trace.Tracer
+}
+
+// NewObserver returns an Observer that creates spans with a tracer of
+// provider. If provider is nil, the global provider is used.
+func NewObserver(provider trace.TracerProvider) *Observer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &Observer{tracer: provider.Tracer(TracerName)}
+}
+
+// Observe records a span for an operation, as the root span of a new trace,
+// as there is no context of the caller to take the parent span from.
+func (o *Observer) Observe(e influxdbhelper.Event) {
+	attributes := []attribute.KeyValue{attribute.String("db.system", "influxdb")}
+	if e.Database != "" {
+		attributes = append(attributes, attribute.String("db.name", e.Database))
+	}
+	if e.Query != "" {
+		attributes = append(attributes, attribute.String("db.statement", influxdbhelper.CleanQuery(e.Query)))
+	}
+	if e.Measurement != "" {
+		attributes = append(attributes, MeasurementKey.String(e.Measurement))
+	}
+	if e.Points > 0 {
+		attributes = append(attributes, PointsKey.Int(e.Points))
+	}
+	if e.Rows > 0 {
+		attributes = append(attributes, RowsKey.Int(e.Rows))
+	}
+	if e.Bytes > 0 {
+		attributes = append(attributes, BytesKey.Int(e.Bytes))
+	}
+
+	kind := trace.SpanKindInternal
+	if e.Operation == influxdbhelper.OpQuery || e.Operation == influxdbhelper.OpWrite {
+		kind = trace.SpanKindClient
+	}
+
+	_, span := o.tracer.Start(context.Background(), "influxdb."+string(e.Operation),
+		trace.WithTimestamp(e.Start),
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(attributes...))
+
+	if e.Err != nil {
+		span.RecordError(e.Err, trace.WithTimestamp(e.Start.Add(e.Duration)))
+		span.SetStatus(codes.Error, e.Err.Error())
+	}
+
+	span.End(trace.WithTimestamp(e.Start.Add(e.Duration)))
+}
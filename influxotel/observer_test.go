@@ -0,0 +1,97 @@
+package influxotel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2"
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// attributes returns the attributes of a span as a map.
+func attributes(s tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	ret := make(map[attribute.Key]attribute.Value)
+	for _, a := range s.Attributes {
+		ret[a.Key] = a.Value
+	}
+	return ret
+}
+
+func TestObserver(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	c, _ := influxdbhelper.NewClient(s.URL, "", "", "ns")
+	c.UseObserver(NewObserver(provider))
+
+	type envSample struct {
+		Time        time.Time `influx:"time"`
+		Temperature float64   `influx:"temperature"`
+	}
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := c.UseDB("db").UseMeasurement("env").WritePoint(envSample{start, 70})
+	if err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+
+	var result []envSample
+	if err := c.UseDB("db").DecodeQuery("SELECT *\n  FROM env", &result); err != nil {
+		t.Fatal("Error querying: ", err)
+	}
+	c.UseDB("missing").DecodeQuery("SELECT * FROM env", &result)
+
+	spans := exporter.GetSpans()
+
+	exp := []struct {
+		name string
+		kind trace.SpanKind
+	}{
+		{"influxdb.encode", trace.SpanKindInternal},
+		{"influxdb.write", trace.SpanKindClient},
+		{"influxdb.query", trace.SpanKindClient},
+		{"influxdb.decode", trace.SpanKindInternal},
+		{"influxdb.query", trace.SpanKindClient},
+	}
+
+	if len(spans) != len(exp) {
+		t.Fatalf("%v != %v", len(spans), len(exp))
+	}
+
+	for i, span := range spans {
+		if span.Name != exp[i].name || span.SpanKind != exp[i].kind {
+			t.Errorf("%v %v != %v %v", span.Name, span.SpanKind, exp[i].name, exp[i].kind)
+		}
+		if span.EndTime.Before(span.StartTime) {
+			t.Errorf("%v: end %v before start %v", span.Name, span.EndTime, span.StartTime)
+		}
+		if db := attributes(span)["db.system"].AsString(); db != "influxdb" {
+			t.Errorf("%v != influxdb", db)
+		}
+	}
+
+	if a := attributes(spans[0]); a[MeasurementKey].AsString() != "env" || a[PointsKey].AsInt64() != 1 {
+		t.Errorf("Unexpected encode attributes: %v", a)
+	}
+
+	if a := attributes(spans[1]); a[BytesKey].AsInt64() == 0 || a["db.name"].AsString() != "db" {
+		t.Errorf("Unexpected write attributes: %v", a)
+	}
+
+	if a := attributes(spans[2]); a["db.statement"].AsString() != "SELECT * FROM env" || a[RowsKey].AsInt64() != 1 {
+		t.Errorf("Unexpected query attributes: %v", a)
+	}
+
+	if span := spans[4]; span.Status.Code != codes.Error || len(span.Events) != 1 {
+		t.Errorf("Expected error status: %+v", span.Status)
+	}
+}
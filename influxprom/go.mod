@@ -0,0 +1,8 @@
+module github.com/cbrake/influxdbhelper/v2/influxprom
+
+require (
+	github.com/cbrake/influxdbhelper/v2 v2.2.0
+	github.com/prometheus/client_golang v1.11.1
+)
+
+go 1.13
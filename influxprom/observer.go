@@ -0,0 +1,97 @@
+// Package influxprom records the operations of an influxdbhelper client as
+// Prometheus metrics.
+//
+//	o := influxprom.NewObserver("myapp")
+//	prometheus.MustRegister(o)
+//	c.UseObserver(o)
+package influxprom
+
+import (
+	"github.com/cbrake/influxdbhelper/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Observer is an influxdbhelper.Observer and a prometheus.Collector of
+// these metrics, labeled by operation (query, decode, encode or write) and
+// database:
+//
+//	influxdb_operation_duration_seconds  histogram of operation durations
+//	influxdb_operation_errors_total      operations that failed
+//	influxdb_points_total                points encoded or written
+//	influxdb_rows_total                  rows returned by queries or decoded
+//	influxdb_bytes_total                 bytes written or read by queries
+//
+// The metric names are prefixed with the namespace passed to NewObserver.
+type Observer struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	points   *prometheus.CounterVec
+	rows     *prometheus.CounterVec
+	bytes    *prometheus.CounterVec
+}
+
+var labels = []string{"operation", "database"}
+
+// NewObserver returns an Observer whose metric names are prefixed with
+// namespace, if it is not empty.
+func NewObserver(namespace string) *Observer {
+	counter := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "influxdb",
+			Name:      name,
+			Help:      help,
+		}, labels)
+	}
+
+	return &Observer{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "influxdb",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of InfluxDb operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		errors: counter("operation_errors_total", "Number of InfluxDb operations that failed."),
+		points: counter("points_total", "Number of points encoded or written."),
+		rows:   counter("rows_total", "Number of rows returned by queries or decoded."),
+		bytes:  counter("bytes_total", "Number of bytes written, or read by queries."),
+	}
+}
+
+// Observe records the metrics of an operation.
+func (o *Observer) Observe(e influxdbhelper.Event) {
+	values := []string{string(e.Operation), e.Database}
+
+	o.duration.WithLabelValues(values...).Observe(e.Duration.Seconds())
+	if e.Err != nil {
+		o.errors.WithLabelValues(values...).Inc()
+	}
+	if e.Points > 0 {
+		o.points.WithLabelValues(values...).Add(float64(e.Points))
+	}
+	if e.Rows > 0 {
+		o.rows.WithLabelValues(values...).Add(float64(e.Rows))
+	}
+	if e.Bytes > 0 {
+		o.bytes.WithLabelValues(values...).Add(float64(e.Bytes))
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (o *Observer) Describe(ch chan<- *prometheus.Desc) {
+	o.duration.Describe(ch)
+	o.errors.Describe(ch)
+	o.points.Describe(ch)
+	o.rows.Describe(ch)
+	o.bytes.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (o *Observer) Collect(ch chan<- prometheus.Metric) {
+	o.duration.Collect(ch)
+	o.errors.Collect(ch)
+	o.points.Collect(ch)
+	o.rows.Collect(ch)
+	o.bytes.Collect(ch)
+}
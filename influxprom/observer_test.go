@@ -0,0 +1,73 @@
+package influxprom
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2"
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserver(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	o := NewObserver("test")
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(o); err != nil {
+		t.Fatal("Error registering: ", err)
+	}
+
+	c, _ := influxdbhelper.NewClient(s.URL, "", "", "ns")
+	c.UseObserver(o)
+
+	type envSample struct {
+		Time        time.Time `influx:"time"`
+		Temperature float64   `influx:"temperature"`
+	}
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		err := c.UseDB("db").UseMeasurement("env").WritePoint(envSample{start.Add(time.Duration(i) * time.Second), 70})
+		if err != nil {
+			t.Fatal("Error writing point: ", err)
+		}
+	}
+
+	var result []envSample
+	if err := c.UseDB("db").DecodeQuery("SELECT * FROM env", &result); err != nil {
+		t.Fatal("Error querying: ", err)
+	}
+	c.UseDB("missing").DecodeQuery("SELECT * FROM env", &result)
+
+	exp := `
+# HELP test_influxdb_operation_errors_total Number of InfluxDb operations that failed.
+# TYPE test_influxdb_operation_errors_total counter
+test_influxdb_operation_errors_total{database="missing",operation="query"} 1
+# HELP test_influxdb_points_total Number of points encoded or written.
+# TYPE test_influxdb_points_total counter
+test_influxdb_points_total{database="db",operation="encode"} 3
+test_influxdb_points_total{database="db",operation="write"} 3
+# HELP test_influxdb_rows_total Number of rows returned by queries or decoded.
+# TYPE test_influxdb_rows_total counter
+test_influxdb_rows_total{database="db",operation="decode"} 3
+test_influxdb_rows_total{database="db",operation="query"} 3
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(exp),
+		"test_influxdb_operation_errors_total", "test_influxdb_points_total", "test_influxdb_rows_total")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if n := testutil.CollectAndCount(o, "test_influxdb_operation_duration_seconds"); n != 5 {
+		t.Errorf("%v != 5", n)
+	}
+
+	if v := testutil.ToFloat64(o.bytes.WithLabelValues("write", "db")); v == 0 {
+		t.Error("Expected bytes written")
+	}
+}
@@ -0,0 +1,140 @@
+//go:build go1.21
+// +build go1.21
+
+// Package influxslog logs the queries of an influxdbhelper client with
+// log/slog.
+//
+//	c.UseObserver(influxslog.NewObserver(slog.Default(), influxslog.Options{
+//		SlowThreshold: time.Second,
+//	}))
+//
+// Each query is logged with the cleaned query, database, duration, number
+// of rows and error. Password literals are redacted from the query, and the
+// values of bound parameters are redacted unless listed in
+// Options.ShowParams.
+package influxslog
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2"
+)
+
+// Redacted replaces the value of a bound parameter that is not shown.
+const Redacted = "[REDACTED]"
+
+// Options configures an Observer.
+type Options struct {
+	// Level is the level of queries. The default is slog.LevelInfo.
+	Level slog.Level
+	// SlowThreshold is the duration at and above which a query is logged
+	// at slog.LevelWarn, if it is greater than the Level. 0 disables slow
+	// query detection.
+	SlowThreshold time.Duration
+	// ShowParams are the names of bound parameters whose values are
+	// logged. The values of other parameters are logged as Redacted.
+	ShowParams []string
+	// Message is the log message. The default is "influxdb query".
+	Message string
+}
+
+// Observer is an influxdbhelper.Observer that logs queries. Other
+// operations are ignored. Failed queries are logged at slog.LevelError.
+type Observer struct {
+	logger  *slog.Logger
+	options Options
+	show    map[string]bool
+}
+
+// NewObserver returns an Observer that logs queries to logger. If logger
+// is nil, slog.Default() is used.
+func NewObserver(logger *slog.Logger, options Options) *Observer {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	if options.Message == "" {
+		options.Message = "influxdb query"
+	}
+
+	show := make(map[string]bool, len(options.ShowParams))
+	for _, p := range options.ShowParams {
+		show[p] = true
+	}
+
+	return &Observer{logger: logger, options: options, show: show}
+}
+
+// Observe logs a query.
+func (o *Observer) Observe(e influxdbhelper.Event) {
+	if e.Operation != influxdbhelper.OpQuery {
+		return
+	}
+
+	level := o.level(e)
+	ctx := context.Background()
+	if !o.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("query", influxdbhelper.CleanQuery(e.Query)),
+		slog.String("db", e.Database),
+		slog.Duration("duration", e.Duration),
+		slog.Int("rows", e.Rows),
+	}
+
+	if len(e.Params) > 0 {
+		attrs = append(attrs, slog.Attr{Key: "params", Value: slog.GroupValue(o.params(e.Params)...)})
+	}
+
+	if o.slow(e) {
+		attrs = append(attrs, slog.Bool("slow", true))
+	}
+
+	if e.Err != nil {
+		attrs = append(attrs, slog.String("error", e.Err.Error()))
+	}
+
+	o.logger.LogAttrs(ctx, level, o.options.Message, attrs...)
+}
+
+// slow returns whether e took at least the slow query threshold.
+func (o *Observer) slow(e influxdbhelper.Event) bool {
+	return o.options.SlowThreshold > 0 && e.Duration >= o.options.SlowThreshold
+}
+
+// level returns the level to log e at.
+func (o *Observer) level(e influxdbhelper.Event) slog.Level {
+	level := o.options.Level
+	if o.slow(e) && level < slog.LevelWarn {
+		level = slog.LevelWarn
+	}
+	if e.Err != nil && level < slog.LevelError {
+		level = slog.LevelError
+	}
+	return level
+}
+
+// params returns the attributes of bound parameters, sorted by name, with
+// the values that are not shown redacted.
+func (o *Observer) params(params map[string]interface{}) []slog.Attr {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	attrs := make([]slog.Attr, len(names))
+	for i, name := range names {
+		if o.show[name] {
+			attrs[i] = slog.Any(name, params[name])
+		} else {
+			attrs[i] = slog.String(name, Redacted)
+		}
+	}
+	return attrs
+}
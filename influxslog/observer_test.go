@@ -0,0 +1,144 @@
+//go:build go1.21
+// +build go1.21
+
+package influxslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2"
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+// newLogger returns a logger of JSON records without times, at level
+// debug and above.
+func newLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+// records decodes the JSON records in buf.
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var ret []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var r map[string]interface{}
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal("Error decoding record: ", err)
+		}
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+func TestObserver(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	buf := &bytes.Buffer{}
+	c, _ := influxdbhelper.NewClient(s.URL, "", "", "ns")
+	c.UseObserver(NewObserver(newLogger(buf), Options{
+		Level:      slog.LevelDebug,
+		ShowParams: []string{"location"},
+	}))
+
+	err := c.UseDB("db").UseMeasurement("env").WritePointTagsFields(
+		map[string]string{"location": "rm1"}, map[string]interface{}{"value": 1.0}, time.Now())
+	if err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+
+	var result []struct {
+		Time  time.Time `influx:"time"`
+		Value float64   `influx:"value"`
+	}
+	err = c.UseDB("db").DecodeQueryParams(`SELECT *
+		FROM env
+		WHERE location = $location AND value < $secret`,
+		map[string]interface{}{"location": "rm1", "secret": 42}, &result)
+	if err != nil {
+		t.Fatal("Error querying: ", err)
+	}
+
+	c.UseDB("missing").DecodeQuery("SELECT * FROM env", &result)
+
+	r := records(t, buf)
+	if len(r) != 2 {
+		t.Fatalf("%v != 2", len(r))
+	}
+
+	if r[0]["level"] != "DEBUG" || r[0]["msg"] != "influxdb query" ||
+		r[0]["query"] != "SELECT * FROM env WHERE location = $location AND value < $secret" ||
+		r[0]["db"] != "db" || r[0]["rows"] != 1.0 {
+		t.Errorf("Unexpected record: %v", r[0])
+	}
+
+	params, _ := r[0]["params"].(map[string]interface{})
+	if params["location"] != "rm1" || params["secret"] != Redacted {
+		t.Errorf("Unexpected params: %v", params)
+	}
+
+	if r[1]["level"] != "ERROR" || r[1]["error"] == nil {
+		t.Errorf("Unexpected record: %v", r[1])
+	}
+}
+
+func TestObserverLevels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	o := NewObserver(newLogger(buf), Options{SlowThreshold: time.Second, Message: "query"})
+
+	events := []influxdbhelper.Event{
+		{Operation: influxdbhelper.OpQuery, Query: "SELECT * FROM a", Duration: time.Millisecond},
+		{Operation: influxdbhelper.OpQuery, Query: "SELECT * FROM b", Duration: 2 * time.Second},
+		{Operation: influxdbhelper.OpQuery, Query: "SELECT * FROM c", Duration: 2 * time.Second, Err: errors.New("timeout")},
+		{Operation: influxdbhelper.OpQuery, Query: `CREATE USER "bob" WITH PASSWORD 'secret'`},
+		{Operation: influxdbhelper.OpQuery, Query: `SET PASSWORD FOR "bob" = 'secret'`},
+		// other operations are not logged
+		{Operation: influxdbhelper.OpWrite, Duration: 2 * time.Second},
+	}
+
+	for _, e := range events {
+		o.Observe(e)
+	}
+
+	r := records(t, buf)
+	exp := []struct {
+		level string
+		slow  interface{}
+	}{
+		{"INFO", nil},
+		{"WARN", true},
+		{"ERROR", true},
+		{"INFO", nil},
+		{"INFO", nil},
+	}
+
+	if len(r) != len(exp) {
+		t.Fatalf("%v != %v", len(r), len(exp))
+	}
+
+	for i := range exp {
+		if r[i]["level"] != exp[i].level || r[i]["slow"] != exp[i].slow || r[i]["msg"] != "query" {
+			t.Errorf("%v: unexpected record: %v", i, r[i])
+		}
+	}
+
+	// password literals are redacted
+	if r[3]["query"] != `CREATE USER "bob" WITH PASSWORD [REDACTED]` ||
+		r[4]["query"] != `SET PASSWORD FOR "bob" = [REDACTED]` {
+		t.Errorf("Password not redacted: %v, %v", r[3]["query"], r[4]["query"])
+	}
+}
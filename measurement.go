@@ -0,0 +1,88 @@
+package influxdbhelper
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// MeasurementNamer can be implemented by data types to define the
+// measurement they are written to, rather than storing it in every value
+// in an InfluxMeasurement field.
+type MeasurementNamer interface {
+	InfluxMeasurementName() string
+}
+
+// NamingStrategy converts a Go type name into a measurement name. It is
+// used when the measurement is not set by UseMeasurement, an
+// InfluxMeasurement field, the MeasurementNamer interface, or a measurement
+// struct tag.
+type NamingStrategy func(typeName string) string
+
+var (
+	// TypeName uses the Go type name as is. This is the default.
+	TypeName NamingStrategy = func(typeName string) string { return typeName }
+
+	// LowerCase converts the type name to lower case: EnvSample -> envsample
+	LowerCase NamingStrategy = strings.ToLower
+
+	// SnakeCase converts the type name to snake case: EnvSample -> env_sample
+	SnakeCase NamingStrategy = toSnakeCase
+)
+
+func toSnakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// start a new word at a lower to upper transition, or at the
+			// last upper case letter of an acronym: HTTPRequest -> http_request
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+var measurementNamerType = reflect.TypeOf((*MeasurementNamer)(nil)).Elem()
+
+// typeMeasurement returns the measurement defined for a struct type, either
+// by the MeasurementNamer interface, or a tag on a blank marker field:
+//
+//	_ struct{} `influx:"measurement=env"`
+//
+// If neither is present, naming is applied to the type name.
+func typeMeasurement(v reflect.Value, naming NamingStrategy) string {
+	t := v.Type()
+
+	if t.Implements(measurementNamerType) {
+		return v.Interface().(MeasurementNamer).InfluxMeasurementName()
+	}
+
+	if reflect.PtrTo(t).Implements(measurementNamerType) {
+		p := reflect.New(t)
+		p.Elem().Set(v)
+		return p.Interface().(MeasurementNamer).InfluxMeasurementName()
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Name != "_" {
+			continue
+		}
+		if m := getMeasurementTag(t.Field(i).Tag.Get("influx")); m != "" {
+			return m
+		}
+	}
+
+	if naming == nil {
+		naming = TypeName
+	}
+
+	return naming(t.Name())
+}
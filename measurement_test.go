@@ -0,0 +1,100 @@
+package influxdbhelper
+
+import (
+	"testing"
+	"time"
+)
+
+type namedSample struct {
+	Value float64 `influx:"value"`
+}
+
+func (namedSample) InfluxMeasurementName() string {
+	return "named"
+}
+
+type pointerNamedSample struct {
+	Value float64 `influx:"value"`
+}
+
+func (*pointerNamedSample) InfluxMeasurementName() string {
+	return "pointer_named"
+}
+
+func TestEncodeMeasurementName(t *testing.T) {
+	type MarkerSample struct {
+		_     struct{} `influx:"measurement=env"`
+		Value float64  `influx:"value"`
+	}
+
+	type EnvSample struct {
+		Value float64 `influx:"value"`
+	}
+
+	type HTTPRequestStats struct {
+		Value float64 `influx:"value"`
+	}
+
+	data := []struct {
+		d           interface{}
+		naming      NamingStrategy
+		measurement string
+	}{
+		{namedSample{1}, nil, "named"},
+		{&namedSample{1}, SnakeCase, "named"},
+		{pointerNamedSample{1}, nil, "pointer_named"},
+		{MarkerSample{Value: 1}, nil, "env"},
+		{EnvSample{1}, nil, "EnvSample"},
+		{EnvSample{1}, LowerCase, "envsample"},
+		{EnvSample{1}, SnakeCase, "env_sample"},
+		{HTTPRequestStats{1}, SnakeCase, "http_request_stats"},
+	}
+
+	for _, d := range data {
+		_, _, fields, measurement, err := encodeWithNaming(d.d, nil, d.naming)
+		if err != nil {
+			t.Error("Error encoding: ", err)
+		}
+
+		if measurement != d.measurement {
+			t.Errorf("%v != %v", measurement, d.measurement)
+		}
+
+		if len(fields) != 1 {
+			t.Errorf("Unexpected fields: %v", fields)
+		}
+	}
+}
+
+func TestEncodeMeasurementFieldPriority(t *testing.T) {
+	type MarkerSample struct {
+		_                 struct{} `influx:"measurement=env"`
+		InfluxMeasurement Measurement
+		Time              time.Time `influx:"time"`
+	}
+
+	_, _, _, measurement, _ := encode(MarkerSample{InfluxMeasurement: "override"}, nil)
+	if measurement != "override" {
+		t.Errorf("%v != %v", measurement, "override")
+	}
+
+	_, _, _, measurement, _ = encode(MarkerSample{}, nil)
+	if measurement != "env" {
+		t.Errorf("%v != %v", measurement, "env")
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	data := map[string]string{
+		"EnvSample":   "env_sample",
+		"envSample":   "env_sample",
+		"HTTPRequest": "http_request",
+		"ID":          "id",
+	}
+
+	for in, out := range data {
+		if s := SnakeCase(in); s != out {
+			t.Errorf("%v: %v != %v", in, s, out)
+		}
+	}
+}
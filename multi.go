@@ -0,0 +1,483 @@
+package influxdbhelper
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+// Balance selects the node that a MultiClient sends queries to.
+type Balance int
+
+const (
+	// RoundRobin sends queries to each healthy node in turn.
+	RoundRobin Balance = iota
+	// LowestLatency sends queries to the healthy node with the lowest ping
+	// latency.
+	LowestLatency
+)
+
+// WriteMode selects the nodes that a MultiClient sends writes to.
+type WriteMode int
+
+const (
+	// WriteAll sends writes to every node, healthy or not, as for InfluxDb
+	// relays that each forward writes to a server. If the write fails on
+	// some of the nodes, a *MultiWriteError is returned.
+	WriteAll WriteMode = iota
+	// WriteFailover sends writes to the first healthy node, in the order
+	// of the URLs, and to the next one if it fails.
+	WriteFailover
+)
+
+// MultiConfig configures a client created with NewMultiClient.
+type MultiConfig struct {
+	// Config is shared by all nodes. Its URL is ignored.
+	Config
+
+	// URLs are the urls of the nodes.
+	URLs      []string
+	Balance   Balance
+	WriteMode WriteMode
+
+	// HealthCheckInterval is the time between health checks of the nodes
+	// with Ping. The default is 10s. If negative, nodes are only checked
+	// by CheckHealth.
+	HealthCheckInterval time.Duration
+}
+
+// NodeHealth is the health of a node of a MultiClient.
+type NodeHealth struct {
+	URL     string
+	Healthy bool
+	// Latency is the round trip time of the last successful ping.
+	Latency time.Duration
+	// Version is the InfluxDb version reported by the last successful
+	// ping.
+	Version string
+	// Checked is the time of the last health check, or zero if the node
+	// has not been checked.
+	Checked time.Time
+	// Err is the error that made the node unhealthy.
+	Err error
+}
+
+// NodeError is returned when a node of a MultiClient cannot be reached or
+// fails with a 5xx status code. It also identifies the nodes that failed in
+// a *MultiWriteError.
+type NodeError struct {
+	URL string
+	Err error
+}
+
+func (e *NodeError) Error() string {
+	return e.URL + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error of the node.
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+// MultiWriteError is returned by writes in WriteAll mode that fail on some
+// or all of the nodes. The points were written to the nodes in Written, so
+// retrying the whole write writes them again there; such writes are not
+// retried by the default classification of a RetryPolicy.
+type MultiWriteError struct {
+	// Written are the urls of the nodes written.
+	Written []string
+	// Failed are the errors of the nodes that failed.
+	Failed []*NodeError
+}
+
+func (e *MultiWriteError) Error() string {
+	errs := make([]string, len(e.Failed))
+	for i, f := range e.Failed {
+		errs[i] = f.Error()
+	}
+	return fmt.Sprintf("write failed on %v of %v nodes: %v",
+		len(e.Failed), len(e.Failed)+len(e.Written), strings.Join(errs, "; "))
+}
+
+// Is reports whether the error of any node that failed matches target. It
+// is needed by errors.Is before Go 1.20, which does not use Unwrap.
+func (e *MultiWriteError) Is(target error) bool {
+	for _, f := range e.Failed {
+		if errors.Is(f, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error of the nodes that failed that matches target.
+// It is needed by errors.As before Go 1.20, which does not use Unwrap.
+func (e *MultiWriteError) As(target interface{}) bool {
+	for _, f := range e.Failed {
+		if errors.As(f, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns the errors of the nodes that failed.
+func (e *MultiWriteError) Unwrap() []error {
+	ret := make([]error, len(e.Failed))
+	for i, f := range e.Failed {
+		ret[i] = f
+	}
+	return ret
+}
+
+// A MultiClient is a Client of several InfluxDb servers or relays.
+type MultiClient interface {
+	Client
+
+	// Health returns the health of each node, in the order of the URLs.
+	Health() []NodeHealth
+
+	// CheckHealth pings each node, and returns their health.
+	CheckHealth() []NodeHealth
+}
+
+// NewMultiClient returns a new client of the nodes at config.URLs,
+// configured by config.Config modified by options.
+//
+// Queries are sent to a healthy node selected by config.Balance, and
+// writes to the nodes selected by config.WriteMode. A node is
+// unhealthy after it cannot be reached or fails with a 5xx status code,
+// until a health check succeeds. Queries that only read data are then
+// retried on the next healthy node. If no node is healthy, all nodes are
+// tried. Close stops the periodic health checks.
+//
+//	c, err := NewMultiClient(MultiConfig{
+//		URLs:    []string{"http://relay1:9096", "http://relay2:9096"},
+//		Balance: LowestLatency,
+//	}, WithDB("myDb"))
+func NewMultiClient(config MultiConfig, options ...Option) (MultiClient, error) {
+	if len(config.URLs) == 0 {
+		return nil, errors.New("no urls")
+	}
+
+	base, err := config.Config.apply(options)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &multiClient{
+		balance:   config.Balance,
+		writeMode: config.WriteMode,
+		done:      make(chan struct{}),
+	}
+
+	for _, u := range config.URLs {
+		c := base
+		c.URL = u
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+
+		client, err := c.newHTTPClient()
+		if err != nil {
+			return nil, err
+		}
+
+		m.nodes = append(m.nodes, &node{
+			client: client,
+			health: NodeHealth{URL: u, Healthy: true},
+		})
+	}
+
+	interval := config.HealthCheckInterval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+	if interval > 0 {
+		go m.checkHealthEvery(interval)
+	}
+
+	base.URL = config.URLs[0]
+	return &multiHelperClient{base.newHelperClient(m), m}, nil
+}
+
+// multiHelperClient is the MultiClient returned by NewMultiClient.
+type multiHelperClient struct {
+	*helperClient
+	multi *multiClient
+}
+
+func (c *multiHelperClient) Health() []NodeHealth {
+	return c.multi.Health()
+}
+
+func (c *multiHelperClient) CheckHealth() []NodeHealth {
+	return c.multi.CheckHealth()
+}
+
+// node is a server of a multiClient.
+type node struct {
+	client *httpClient
+
+	lock   sync.Mutex
+	health NodeHealth
+}
+
+// check pings the node, and updates its health.
+func (n *node) check() {
+	latency, version, err := n.client.Ping(0)
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.health.Checked = time.Now()
+	n.health.Healthy = err == nil
+	n.health.Err = err
+	if err == nil {
+		n.health.Latency = latency
+		n.health.Version = version
+	}
+}
+
+// fail marks the node unhealthy if err is a failure of the node, and
+// returns err wrapped in a *NodeError.
+func (n *node) fail(err error) error {
+	if !isNodeFailure(err) {
+		return err
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.health.Healthy = false
+	n.health.Err = err
+	return &NodeError{URL: n.health.URL, Err: err}
+}
+
+func (n *node) getHealth() NodeHealth {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.health
+}
+
+// isNodeFailure returns true if err shows that a node cannot serve
+// requests, rather than that the request is invalid.
+func isNodeFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if code := statusCode(err); code != 0 {
+		return code >= 500
+	}
+
+	var netErr net.Error
+	return errors.Is(err, ErrConnection) || errors.As(err, &netErr)
+}
+
+// multiClient implements influxClient.Client for several nodes.
+type multiClient struct {
+	nodes     []*node
+	balance   Balance
+	writeMode WriteMode
+	next      uint32
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (m *multiClient) Health() []NodeHealth {
+	ret := make([]NodeHealth, len(m.nodes))
+	for i, n := range m.nodes {
+		ret[i] = n.getHealth()
+	}
+	return ret
+}
+
+func (m *multiClient) CheckHealth() []NodeHealth {
+	var wg sync.WaitGroup
+	for _, n := range m.nodes {
+		wg.Add(1)
+		go func(n *node) {
+			defer wg.Done()
+			n.check()
+		}(n)
+	}
+	wg.Wait()
+
+	return m.Health()
+}
+
+func (m *multiClient) checkHealthEvery(interval time.Duration) {
+	m.CheckHealth()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.CheckHealth()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// healthyNodes returns the healthy nodes, in the order of the URLs, or all
+// nodes if none are healthy.
+func (m *multiClient) healthyNodes() []*node {
+	var ret []*node
+	for _, n := range m.nodes {
+		if n.getHealth().Healthy {
+			ret = append(ret, n)
+		}
+	}
+
+	if len(ret) == 0 {
+		return m.nodes
+	}
+
+	return ret
+}
+
+// queryNodes returns the nodes to send a query to, in the order they are
+// tried.
+func (m *multiClient) queryNodes() []*node {
+	nodes := m.healthyNodes()
+
+	switch m.balance {
+	case LowestLatency:
+		nodes = append([]*node(nil), nodes...)
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[i].getHealth().Latency < nodes[j].getHealth().Latency
+		})
+		return nodes
+	default:
+		start := int(atomic.AddUint32(&m.next, 1)-1) % len(nodes)
+		return append(nodes[start:len(nodes):len(nodes)], nodes[:start]...)
+	}
+}
+
+// Ping pings each node, and returns the latency and version of the first
+// healthy node, or the error of the first node if none are healthy.
+func (m *multiClient) Ping(timeout time.Duration) (time.Duration, string, error) {
+	health := m.CheckHealth()
+	for _, h := range health {
+		if h.Healthy {
+			return h.Latency, h.Version, nil
+		}
+	}
+
+	return 0, "", &NodeError{URL: health[0].URL, Err: health[0].Err}
+}
+
+func (m *multiClient) Write(bp influxClient.BatchPoints) error {
+	_, err := m.write(bp)
+	return err
+}
+
+// write writes bp to the nodes selected by the write mode, and returns the
+// total size written.
+func (m *multiClient) write(bp influxClient.BatchPoints) (int, error) {
+	if m.writeMode == WriteFailover {
+		var err error
+		for _, n := range m.healthyNodes() {
+			var size int
+			size, err = n.client.write(bp)
+			if err = n.fail(err); !isNodeFailure(err) {
+				return size, err
+			}
+		}
+		return 0, err
+	}
+
+	sizes := make([]int, len(m.nodes))
+	errs := make([]error, len(m.nodes))
+
+	var wg sync.WaitGroup
+	for i, n := range m.nodes {
+		wg.Add(1)
+		go func(i int, n *node) {
+			defer wg.Done()
+			sizes[i], errs[i] = n.client.write(bp)
+			errs[i] = n.fail(errs[i])
+		}(i, n)
+	}
+	wg.Wait()
+
+	total := 0
+	multiErr := &MultiWriteError{}
+	for i, n := range m.nodes {
+		url := n.getHealth().URL
+		if errs[i] == nil {
+			total += sizes[i]
+			multiErr.Written = append(multiErr.Written, url)
+			continue
+		}
+
+		nodeErr, ok := errs[i].(*NodeError)
+		if !ok {
+			nodeErr = &NodeError{URL: url, Err: newWriteError(errs[i])}
+		}
+		multiErr.Failed = append(multiErr.Failed, nodeErr)
+	}
+
+	if len(multiErr.Failed) > 0 {
+		return total, multiErr
+	}
+
+	return total, nil
+}
+
+func (m *multiClient) Query(q influxClient.Query) (*influxClient.Response, error) {
+	response, _, err := m.query(q)
+	return response, err
+}
+
+// query sends q to the nodes selected by the balance until one does not
+// fail, and returns the size of the response.
+func (m *multiClient) query(q influxClient.Query) (*influxClient.Response, int, error) {
+	var err error
+	for _, n := range m.queryNodes() {
+		var response *influxClient.Response
+		var size int
+		response, size, err = n.client.query(q)
+		if err = n.fail(err); !isNodeFailure(err) || !isIdempotentQuery(q.Command) {
+			return response, size, err
+		}
+	}
+	return nil, 0, err
+}
+
+func (m *multiClient) QueryAsChunk(q influxClient.Query) (*influxClient.ChunkedResponse, error) {
+	var err error
+	for _, n := range m.queryNodes() {
+		var response *influxClient.ChunkedResponse
+		response, err = n.client.QueryAsChunk(q)
+		if err = n.fail(err); !isNodeFailure(err) || !isIdempotentQuery(q.Command) {
+			return response, err
+		}
+	}
+	return nil, err
+}
+
+// Close stops health checks, and closes the clients of the nodes.
+func (m *multiClient) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.done)
+	})
+
+	for _, n := range m.nodes {
+		n.client.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,290 @@
+package influxdbhelper
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+// newMultiTest returns n test servers with a db database, and a multi
+// client of them without periodic health checks.
+func newMultiTest(t *testing.T, n int, config MultiConfig) ([]*influxdbtest.Server, MultiClient) {
+	var servers []*influxdbtest.Server
+	for i := 0; i < n; i++ {
+		s := influxdbtest.NewServer()
+		s.CreateDatabase("db")
+		servers = append(servers, s)
+		config.URLs = append(config.URLs, s.URL)
+	}
+
+	config.HealthCheckInterval = -1
+	c, err := NewMultiClient(config, WithDB("db"), WithMeasurement("env"))
+	if err != nil {
+		t.Fatal("Error creating client: ", err)
+	}
+
+	return servers, c
+}
+
+// countRequests returns the number of requests to path received by s.
+func countRequests(s *influxdbtest.Server, path string) int {
+	n := 0
+	for _, r := range s.Requests() {
+		if r.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+func writeValue(c Client) error {
+	return c.WritePointTagsFields(nil, map[string]interface{}{"value": 1.0}, time.Now())
+}
+
+func TestMultiClientWriteAll(t *testing.T) {
+	servers, c := newMultiTest(t, 2, MultiConfig{WriteMode: WriteAll})
+	defer c.Close()
+	defer servers[0].Close()
+
+	if err := writeValue(c); err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+
+	for i, s := range servers {
+		if n := len(s.Points("db")); n != 1 {
+			t.Errorf("server %v: %v != 1", i, n)
+		}
+	}
+
+	servers[1].Close()
+
+	err := writeValue(c)
+	var multiErr *MultiWriteError
+	if !errors.As(err, &multiErr) || len(multiErr.Written) != 1 || multiErr.Written[0] != servers[0].URL {
+		t.Fatalf("Expected *MultiWriteError, got: %v", err)
+	}
+
+	var nodeErr *NodeError
+	if !errors.As(err, &nodeErr) || nodeErr.URL != servers[1].URL || !errors.Is(err, ErrConnection) {
+		t.Errorf("Expected connection error of node 1, got: %v", err)
+	}
+
+	if n := len(servers[0].Points("db")); n != 2 {
+		t.Errorf("%v != 2", n)
+	}
+
+	health := c.Health()
+	if !health[0].Healthy || health[1].Healthy || health[1].Err == nil {
+		t.Errorf("Unexpected health: %+v", health)
+	}
+
+	// unhealthy nodes are still written
+	if err := writeValue(c); !errors.As(err, &multiErr) || multiErr.Failed[0].URL != servers[1].URL {
+		t.Errorf("Expected *MultiWriteError, got: %v", err)
+	}
+
+	if health := c.CheckHealth(); !health[0].Healthy || health[1].Healthy {
+		t.Errorf("Unexpected health: %+v", health)
+	}
+}
+
+func TestMultiWriteErrorIsAs(t *testing.T) {
+	err := &MultiWriteError{Failed: []*NodeError{
+		{"http://relay1:9096", &connectionError{errors.New("connection refused")}},
+		{"http://relay2:9096", &HTTPError{StatusCode: 503}},
+	}}
+
+	// the methods are used by errors.Is and errors.As before Go 1.20,
+	// which do not use Unwrap() []error
+	if !err.Is(ErrConnection) || err.Is(ErrNoDatabase) {
+		t.Error("Is does not match the node errors")
+	}
+
+	var httpErr *HTTPError
+	if !err.As(&httpErr) || httpErr.StatusCode != 503 {
+		t.Errorf("As does not match the node errors: %v", httpErr)
+	}
+
+	// a write that failed on all nodes is buffered by a WALWriter
+	policy := DefaultRetryPolicy()
+	if !policy.IsRetryable(err) {
+		t.Error("Expected write that failed on all nodes to be retryable")
+	}
+}
+
+func TestMultiClientWriteAllPartial(t *testing.T) {
+	servers, c := newMultiTest(t, 3, MultiConfig{WriteMode: WriteAll})
+	defer c.Close()
+	for _, s := range servers {
+		defer s.Close()
+	}
+
+	// a write that reached some nodes is not retried
+	c.UseRetryPolicy(RetryPolicy{MaxAttempts: 3, RetryableStatusCodes: []int{503}})
+
+	servers[1].FailNext(503, "overloaded")
+	servers[2].FailNext(400, "unable to parse")
+	err := writeValue(c)
+
+	var multiErr *MultiWriteError
+	if !errors.As(err, &multiErr) {
+		t.Fatalf("Expected *MultiWriteError, got: %v", err)
+	}
+
+	if len(multiErr.Written) != 1 || multiErr.Written[0] != servers[0].URL || len(multiErr.Failed) != 2 ||
+		multiErr.Failed[0].URL != servers[1].URL || multiErr.Failed[1].URL != servers[2].URL {
+		t.Errorf("Unexpected error: %+v", multiErr)
+	}
+
+	var writeErr *WriteError
+	if !errors.As(multiErr.Failed[1], &writeErr) || statusCode(multiErr.Failed[0]) != 503 {
+		t.Errorf("Unexpected node errors: %v", err)
+	}
+
+	for i, exp := range []int{1, 0, 0} {
+		if n := len(servers[i].Points("db")); n != exp {
+			t.Errorf("server %v: %v != %v", i, n, exp)
+		}
+	}
+
+	// writes that failed on all nodes are retried
+	for _, s := range servers {
+		s.FailNext(503, "overloaded")
+	}
+	if err := writeValue(c); err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+
+	for i, exp := range []int{2, 1, 1} {
+		if n := len(servers[i].Points("db")); n != exp {
+			t.Errorf("server %v: %v != %v", i, n, exp)
+		}
+	}
+}
+
+func TestMultiClientFailover(t *testing.T) {
+	servers, c := newMultiTest(t, 2, MultiConfig{WriteMode: WriteFailover})
+	defer c.Close()
+	for _, s := range servers {
+		defer s.Close()
+	}
+
+	if err := writeValue(c); err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+
+	servers[0].FailNext(503, "overloaded")
+	if err := writeValue(c); err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+
+	if n := len(servers[0].Points("db")); n != 1 {
+		t.Errorf("%v != 1", n)
+	}
+	if n := len(servers[1].Points("db")); n != 1 {
+		t.Errorf("%v != 1", n)
+	}
+
+	if health := c.Health(); health[0].Healthy || !health[1].Healthy {
+		t.Errorf("Unexpected health: %+v", health)
+	}
+
+	// invalid writes are not failed over
+	servers[1].FailNext(400, "unable to parse")
+	var writeErr *WriteError
+	if err := writeValue(c); !errors.As(err, &writeErr) {
+		t.Errorf("Expected *WriteError, got: %v", err)
+	}
+
+	health := c.CheckHealth()
+	if !health[0].Healthy || !health[1].Healthy || health[0].Version != influxdbtest.Version {
+		t.Errorf("Unexpected health: %+v", health)
+	}
+}
+
+func TestMultiClientQuery(t *testing.T) {
+	servers, c := newMultiTest(t, 2, MultiConfig{Balance: RoundRobin})
+	defer c.Close()
+	for _, s := range servers {
+		defer s.Close()
+	}
+
+	if err := writeValue(c); err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+
+	var result []struct {
+		Time  time.Time `influx:"time"`
+		Value float64   `influx:"value"`
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := c.DecodeQuery("SELECT * FROM env", &result); err != nil {
+			t.Fatal("Error querying: ", err)
+		}
+	}
+
+	for i, s := range servers {
+		if n := countRequests(s, "/query"); n != 2 {
+			t.Errorf("server %v: %v != 2", i, n)
+		}
+	}
+
+	// reads are retried on the next node
+	servers[0].FailNext(500, "internal error")
+	servers[1].FailNext(500, "internal error")
+	if err := c.DecodeQuery("SELECT * FROM env", &result); err == nil {
+		t.Error("Expected error if all nodes fail")
+	}
+
+	c.CheckHealth()
+	servers[0].FailNext(500, "internal error")
+	for i := 0; i < 2; i++ {
+		if err := c.DecodeQuery("SELECT * FROM env", &result); err != nil || len(result) != 1 {
+			t.Errorf("Error querying: %v, %v", err, result)
+		}
+	}
+
+	if n := countRequests(servers[0], "/query"); n != 4 {
+		t.Errorf("%v != 4", n)
+	}
+}
+
+func TestMultiClientLowestLatency(t *testing.T) {
+	servers, c := newMultiTest(t, 3, MultiConfig{Balance: LowestLatency})
+	defer c.Close()
+	for _, s := range servers {
+		defer s.Close()
+	}
+
+	m := c.(*multiHelperClient).multi
+	for i, latency := range []time.Duration{30, 10, 20} {
+		m.nodes[i].health.Latency = latency * time.Millisecond
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Query(influxClient.Query{Command: "SHOW DATABASES"}); err != nil {
+			t.Fatal("Error querying: ", err)
+		}
+	}
+
+	for i, exp := range []int{0, 3, 0} {
+		if n := countRequests(servers[i], "/query"); n != exp {
+			t.Errorf("server %v: %v != %v", i, n, exp)
+		}
+	}
+}
+
+func TestNewMultiClientErrors(t *testing.T) {
+	if _, err := NewMultiClient(MultiConfig{}); err == nil {
+		t.Error("Expected no urls error")
+	}
+
+	_, err := NewMultiClient(MultiConfig{URLs: []string{"http://localhost:8086", "localhost:8087"}})
+	if err == nil {
+		t.Error("Expected invalid url error")
+	}
+}
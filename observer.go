@@ -0,0 +1,113 @@
+package influxdbhelper
+
+import (
+	"time"
+
+	influxModels "github.com/influxdata/influxdb1-client/models"
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+// Operation is a kind of client operation reported to an Observer.
+type Operation string
+
+const (
+	// OpQuery is a query sent to the server, including retries.
+	OpQuery Operation = "query"
+	// OpDecode is the decode of query results by DecodeQuery.
+	OpDecode Operation = "decode"
+	// OpEncode is the encode of a data struct by WritePoint.
+	OpEncode Operation = "encode"
+	// OpWrite is a write of points to the server, including retries.
+	OpWrite Operation = "write"
+)
+
+// Event describes a completed client operation.
+type Event struct {
+	Operation Operation
+	Start     time.Time
+	Duration  time.Duration
+
+	// Database is the database of queries and writes.
+	Database string
+	// Measurement is the measurement of encoded points.
+	Measurement string
+	// Query is the query of query and decode operations, with password
+	// literals replaced by [REDACTED].
+	Query string
+	// Params are the bound parameters of a query.
+	Params map[string]interface{}
+
+	// Points is the number of points encoded or written.
+	Points int
+	// Rows is the number of rows returned by a query or decoded.
+	Rows int
+	// Bytes is the size of the line protocol written, or of the response
+	// read by a query. It is 0 for chunked queries, which are read by the
+	// caller.
+	Bytes int
+
+	// Err is the error of the operation, or nil if it succeeded.
+	Err error
+}
+
+// An Observer is notified of each operation of a client, for example to
+// record metrics or traces. Observe is called after the operation
+// completes, and must be safe for concurrent use if the client is.
+type Observer interface {
+	Observe(e Event)
+}
+
+// ObserverFunc is a function that implements Observer.
+type ObserverFunc func(e Event)
+
+// Observe calls f(e).
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
+
+// Observers returns an Observer that notifies each of observers in turn.
+func Observers(observers ...Observer) Observer {
+	return multiObserver(observers)
+}
+
+type multiObserver []Observer
+
+func (m multiObserver) Observe(e Event) {
+	for _, o := range m {
+		o.Observe(e)
+	}
+}
+
+// observe notifies the observer of c, if any, of an operation started at
+// start.
+func (c *helperClient) observe(start time.Time, e Event) {
+	if c.observer == nil {
+		return
+	}
+	e.Start = start
+	e.Duration = time.Since(start)
+	e.Query = redactPasswords(e.Query)
+	c.observer.Observe(e)
+}
+
+// responseRows returns the number of rows in the results of a response.
+func responseRows(response *influxClient.Response) int {
+	if response == nil {
+		return 0
+	}
+
+	rows := 0
+	for _, r := range response.Results {
+		rows += seriesRows(r.Series)
+	}
+	return rows
+}
+
+// seriesRows returns the number of rows in series.
+func seriesRows(series []influxModels.Row) int {
+	rows := 0
+	for _, s := range series {
+		rows += len(s.Values)
+	}
+	return rows
+}
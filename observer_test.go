@@ -0,0 +1,139 @@
+package influxdbhelper
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+// recorder is an Observer that records events.
+type recorder struct {
+	lock   sync.Mutex
+	events []Event
+}
+
+func (r *recorder) Observe(e Event) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, e)
+}
+
+func TestObserver(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	r := &recorder{}
+	count := 0
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseObserver(Observers(r, ObserverFunc(func(e Event) { count++ })))
+
+	type envSample struct {
+		Time        time.Time `influx:"time"`
+		Location    string    `influx:"location,tag"`
+		Temperature float64   `influx:"temperature"`
+	}
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		err := c.UseDB("db").UseMeasurement("env").WritePoint(envSample{start.Add(time.Duration(i) * time.Second), "rm1", 70})
+		if err != nil {
+			t.Fatal("Error writing point: ", err)
+		}
+	}
+
+	var result []envSample
+	if err := c.UseDB("db").DecodeQuery("SELECT * FROM env", &result); err != nil {
+		t.Fatal("Error querying: ", err)
+	}
+
+	exp := []struct {
+		op     Operation
+		points int
+		rows   int
+	}{
+		{OpEncode, 1, 0},
+		{OpWrite, 1, 0},
+		{OpEncode, 1, 0},
+		{OpWrite, 1, 0},
+		{OpQuery, 0, 2},
+		{OpDecode, 0, 2},
+	}
+
+	if len(r.events) != len(exp) {
+		t.Fatalf("%v != %v", len(r.events), len(exp))
+	}
+
+	for i, e := range r.events {
+		if e.Operation != exp[i].op || e.Points != exp[i].points || e.Rows != exp[i].rows {
+			t.Errorf("%v: %+v != %+v", i, e, exp[i])
+		}
+		if e.Database != "db" || e.Err != nil || e.Start.IsZero() {
+			t.Errorf("%v: unexpected event: %+v", i, e)
+		}
+	}
+
+	if e := r.events[0]; e.Measurement != "env" {
+		t.Errorf("%v != env", e.Measurement)
+	}
+
+	if e := r.events[1]; e.Bytes != len("env,location=rm1 temperature=70 1546300800000000000\n") {
+		t.Errorf("Unexpected write bytes: %v", e.Bytes)
+	}
+
+	if e := r.events[4]; e.Query != "SELECT * FROM env" || e.Bytes == 0 {
+		t.Errorf("Unexpected query event: %+v", e)
+	}
+
+	if count != len(exp) {
+		t.Errorf("%v != %v", count, len(exp))
+	}
+}
+
+func TestObserverErrors(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	r := &recorder{}
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseObserver(r)
+
+	// encode error, nothing is written
+	if err := c.UseDB("db").WritePoint(42); err == nil {
+		t.Error("Expected encode error")
+	}
+
+	var result []struct {
+		Time  time.Time `influx:"time"`
+		Value float64   `influx:"value"`
+	}
+	c.UseDB("db").UseMeasurement("m").WritePointTagsFields(nil, map[string]interface{}{"value": "on"}, time.Now())
+	if err := c.UseDB("db").DecodeQuery("SELECT * FROM m", &result); err == nil {
+		t.Error("Expected decode error")
+	}
+
+	// statement errors are reported as query errors
+	if err := c.UseDB("missing").DecodeQuery("SELECT * FROM m", &result); err == nil {
+		t.Error("Expected query error")
+	}
+
+	ops := []Operation{OpEncode, OpDecode, OpQuery}
+	var errs []Operation
+	for _, e := range r.events {
+		if e.Err != nil {
+			errs = append(errs, e.Operation)
+		}
+	}
+
+	if len(errs) != len(ops) {
+		t.Fatalf("%v != %v", errs, ops)
+	}
+	for i := range ops {
+		if errs[i] != ops[i] {
+			t.Errorf("%v != %v", errs, ops)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package influxdbhelper
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// StructParams returns query parameters for the fields of a struct, using
+// the influx struct field tags for the parameter names, in the same way as
+// WritePoint. This allows a filter struct to be bound to a query:
+//
+//	type filter struct {
+//		Location string    `influx:"location,tag"`
+//		Start    time.Time `influx:"start"`
+//	}
+//
+//	params, _ := StructParams(filter{"Rm 243", start})
+//	c.DecodeQueryParams(`SELECT * FROM test WHERE location = $location AND time > $start`,
+//		params, &result)
+func StructParams(filter interface{}) (map[string]interface{}, error) {
+	v := reflect.ValueOf(filter)
+	if v.Kind() == reflect.Ptr {
+		v = reflect.Indirect(v)
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil, errors.New("filter must be a struct")
+	}
+
+	params := make(map[string]interface{})
+	for i := 0; i < v.NumField(); i++ {
+		structField := v.Type().Field(i)
+		if structField.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		fieldData := getInfluxFieldTagData(structField.Name, structField.Tag.Get("influx"))
+		if fieldData.fieldName == "-" || fieldData.isTagMap || fieldData.isFieldMap {
+			continue
+		}
+
+		params[fieldData.fieldName] = v.Field(i).Interface()
+	}
+
+	return params, nil
+}
+
+// bindParams converts parameter values to the representation InfluxDb
+// expects. Times are sent as RFC3339 strings in UTC, which InfluxDb
+// compares against the time column.
+func bindParams(params map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		switch v := v.(type) {
+		case time.Time:
+			ret[k] = v.UTC().Format(time.RFC3339Nano)
+		case *time.Time:
+			if v == nil {
+				ret[k] = nil
+				continue
+			}
+			ret[k] = v.UTC().Format(time.RFC3339Nano)
+		default:
+			ret[k] = v
+		}
+	}
+	return ret
+}
+
+// QuoteIdent quotes an identifier such as a measurement, tag key, or
+// database name for use in an InfluxQL statement.
+func QuoteIdent(ident string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(ident) + `"`
+}
+
+// QuoteString quotes a string literal for use in an InfluxQL statement.
+// Prefer bound parameters with DecodeQueryParams where possible.
+func QuoteString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`)
+	return `'` + r.Replace(s) + `'`
+}
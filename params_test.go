@@ -0,0 +1,92 @@
+package influxdbhelper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+func TestDecodeQueryParams(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+
+	type sample struct {
+		Time        time.Time `influx:"time"`
+		Location    string    `influx:"location,tag"`
+		Temperature float64   `influx:"temperature"`
+	}
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	locations := []string{"Rm 243", "Rm 244", "Rm 243' OR location = 'Rm 244"}
+	for i, l := range locations {
+		err := c.UseDB("db").UseMeasurement("test").WritePoint(
+			sample{start.Add(time.Duration(i) * time.Hour), l, float64(i)})
+		if err != nil {
+			t.Fatal("Error writing point: ", err)
+		}
+	}
+
+	type filter struct {
+		Location string    `influx:"location"`
+		Start    time.Time `influx:"start"`
+		Ignored  string    `influx:"-"`
+	}
+
+	q := `SELECT * FROM test WHERE location = $location AND time >= $start`
+
+	params, err := StructParams(filter{Location: locations[2], Start: start})
+	if err != nil {
+		t.Fatal("Error creating params: ", err)
+	}
+
+	read := []sample{}
+	if err := c.UseDB("db").DecodeQueryParams(q, params, &read); err != nil {
+		t.Fatal("Query error: ", err)
+	}
+
+	if len(read) != 1 || read[0].Location != locations[2] {
+		t.Errorf("Unexpected result: %+v", read)
+	}
+
+	// times are bound in UTC, regardless of the location of the value
+	params["start"] = start.Add(time.Hour).In(time.FixedZone("EST", -5*3600))
+	params["location"] = locations[0]
+	read = []sample{}
+	if err := c.UseDB("db").DecodeQueryParams(q, params, &read); err != nil {
+		t.Fatal("Query error: ", err)
+	}
+
+	if len(read) != 0 {
+		t.Errorf("Expected no results, got: %+v", read)
+	}
+}
+
+func TestStructParams(t *testing.T) {
+	if _, err := StructParams("not a struct"); err == nil {
+		t.Error("Expected error")
+	}
+
+	params, _ := StructParams(&struct {
+		Location string `influx:"location,tag"`
+		Count    int
+		ignored  int
+	}{"here", 3, 0})
+
+	if len(params) != 2 || params["location"] != "here" || params["Count"] != 3 {
+		t.Errorf("Unexpected params: %v", params)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if q := QuoteIdent(`my "db"`); q != `"my \"db\""` {
+		t.Errorf("Unexpected ident: %v", q)
+	}
+
+	if q := QuoteString(`it's a \ test`); q != `'it\'s a \\ test'` {
+		t.Errorf("Unexpected string: %v", q)
+	}
+}
@@ -0,0 +1,163 @@
+package influxdbhelper
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RetryPolicy defines how writes and idempotent queries are retried when
+// they fail with a transient error.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// A value of 1 or less disables retries.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts. A delay requested by
+	// the server with a Retry-After header is not capped.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor the backoff is multiplied by after each
+	// attempt.
+	Multiplier float64
+
+	// Jitter is the fraction (0 to 1) of each delay that is randomized.
+	Jitter float64
+
+	// RetryableStatusCodes lists the HTTP status codes that are retried.
+	RetryableStatusCodes []int
+
+	// Retryable, if set, replaces the default classification of errors.
+	Retryable func(err error) bool
+
+	// OnRetry, if set, is called before sleeping ahead of each retry.
+	// attempt is the number of the attempt that failed, starting at 1.
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// DefaultRetryPolicy returns a policy that retries up to 5 times with
+// exponential backoff starting at 100ms, on connection errors and on
+// 429, 500, 502, 503 and 504 status codes.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryableStatusCodes: []int{
+			429, 500, 502, 503, 504,
+		},
+	}
+}
+
+// sleep is replaced in tests.
+var sleep = time.Sleep
+
+// IsRetryable reports whether err is a transient error according to the
+// policy.
+func (p *RetryPolicy) IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+
+	// retrying would write the points again to the nodes written
+	var multiErr *MultiWriteError
+	if errors.As(err, &multiErr) && len(multiErr.Written) > 0 {
+		return false
+	}
+
+	if code := statusCode(err); code != 0 {
+		for _, c := range p.RetryableStatusCodes {
+			if c == code {
+				return true
+			}
+		}
+		return false
+	}
+
+	var netErr net.Error
+	if errors.Is(err, ErrConnection) || errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// backoff returns the delay before retrying after the given attempt.
+func (p *RetryPolicy) backoff(attempt int, err error) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		d -= d * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+
+	delay := time.Duration(d)
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > delay {
+		delay = httpErr.RetryAfter
+	}
+
+	return delay
+}
+
+// do calls fn until it succeeds, returns an error that is not retryable,
+// or the maximum number of attempts is reached.
+func (p *RetryPolicy) do(fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !p.IsRetryable(err) {
+			return err
+		}
+
+		delay := p.backoff(attempt, err)
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, delay, err)
+		}
+		sleep(delay)
+	}
+}
+
+var reMutatingStatement = regexp.MustCompile(`(?i)\bINTO\b`)
+
+// isIdempotentQuery returns true if all statements in a query only read
+// data and can be safely retried.
+func isIdempotentQuery(q string) bool {
+	for _, stmt := range strings.Split(q, ";") {
+		stmt = strings.TrimSpace(stmt)
+		if stmt == "" {
+			continue
+		}
+		fields := strings.Fields(stmt)
+		keyword := strings.ToUpper(fields[0])
+		if keyword != "SELECT" && keyword != "SHOW" {
+			return false
+		}
+		if keyword == "SELECT" && reMutatingStatement.MatchString(stmt) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,174 @@
+package influxdbhelper
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+// noSleep replaces sleep with a function that records delays. The returned
+// function restores the original.
+func noSleep() (*[]time.Duration, func()) {
+	delays := []time.Duration{}
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	return &delays, func() { sleep = time.Sleep }
+}
+
+func TestRetryWrite(t *testing.T) {
+	delays, restore := noSleep()
+	defer restore()
+
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+	s.FailNext(http.StatusServiceUnavailable, "unavailable")
+	s.FailNext(http.StatusServiceUnavailable, "unavailable")
+
+	retries := 0
+	policy := DefaultRetryPolicy()
+	policy.Jitter = 0
+	policy.OnRetry = func(attempt int, delay time.Duration, err error) {
+		retries++
+	}
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseRetryPolicy(policy)
+
+	err := c.UseDB("db").UseMeasurement("test").WritePointTagsFields(nil,
+		map[string]interface{}{"value": 1.0}, time.Now())
+	if err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+
+	if retries != 2 || len(s.Points("db")) != 1 {
+		t.Errorf("Expected 2 retries and 1 point, got %v and %v", retries, len(s.Points("db")))
+	}
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}
+	if len(*delays) != 2 || (*delays)[0] != expected[0] || (*delays)[1] != expected[1] {
+		t.Errorf("%v != %v", *delays, expected)
+	}
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	_, restore := noSleep()
+	defer restore()
+
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+	s.FailNext(http.StatusBadRequest, "bad request")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseRetryPolicy(DefaultRetryPolicy())
+
+	err := c.UseDB("db").UseMeasurement("test").WritePointTagsFields(nil,
+		map[string]interface{}{"value": 1.0}, time.Now())
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected bad request error, got: %v", err)
+	}
+
+	if len(s.Requests()) != 1 {
+		t.Errorf("Expected 1 request, got %v", len(s.Requests()))
+	}
+}
+
+func TestRetryQuery(t *testing.T) {
+	_, restore := noSleep()
+	defer restore()
+
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseRetryPolicy(DefaultRetryPolicy())
+
+	s.FailNext(http.StatusServiceUnavailable, "unavailable")
+	result := []struct{}{}
+	if err := c.UseDB("db").DecodeQuery("SELECT * FROM test", &result); err != nil {
+		t.Fatal("Query error: ", err)
+	}
+
+	if len(s.Requests()) != 2 {
+		t.Errorf("Expected 2 requests, got %v", len(s.Requests()))
+	}
+
+	// statements that modify data are not retried
+	s.FailNext(http.StatusServiceUnavailable, "unavailable")
+	err := c.UseDB("db").DecodeQuery("SELECT * INTO copy FROM test", &result)
+	if err == nil {
+		t.Error("Expected error")
+	}
+
+	if len(s.Requests()) != 3 {
+		t.Errorf("Expected 3 requests, got %v", len(s.Requests()))
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+	}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, e := range expected {
+		if d := p.backoff(i+1, nil); d != e {
+			t.Errorf("attempt %v: %v != %v", i+1, d, e)
+		}
+	}
+
+	err := &HTTPError{StatusCode: 503, RetryAfter: time.Minute}
+	if d := p.backoff(1, err); d != time.Minute {
+		t.Errorf("Retry-After not respected: %v", d)
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.backoff(1, nil); d < 500*time.Millisecond || d > time.Second {
+			t.Fatalf("jittered delay out of range: %v", d)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("120"); d != 2*time.Minute {
+		t.Errorf("%v != %v", d, 2*time.Minute)
+	}
+
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if d := parseRetryAfter(date); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("Unexpected delay for date: %v", d)
+	}
+
+	if d := parseRetryAfter("garbage"); d != 0 {
+		t.Errorf("%v != 0", d)
+	}
+}
+
+func TestIsIdempotentQuery(t *testing.T) {
+	data := []struct {
+		query      string
+		idempotent bool
+	}{
+		{"SELECT * FROM test", true},
+		{"show databases", true},
+		{"SELECT * FROM a; SHOW MEASUREMENTS", true},
+		{"SELECT * INTO b FROM a", false},
+		{"CREATE DATABASE test", false},
+		{"SELECT * FROM a; DROP MEASUREMENT a", false},
+	}
+
+	for _, d := range data {
+		if isIdempotentQuery(d.query) != d.idempotent {
+			t.Errorf("%v: expected %v", d.query, d.idempotent)
+		}
+	}
+}
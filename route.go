@@ -0,0 +1,136 @@
+package influxdbhelper
+
+import (
+	"fmt"
+	"reflect"
+
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+// Destination is the database and retention policy that points are
+// written to.
+type Destination struct {
+	Database        string
+	RetentionPolicy string
+}
+
+// A RouteFunc returns the destination of a point written by a client,
+// set with UseRouter.
+//
+// route is the value of the tag with the route option of the struct
+// written with WritePoint or WritePoints, such as the customer tag of
+// `influx:"customer,tag,route"`, or "" if there is none. def is the
+// destination without routing: the db and retention policy set with UseDB
+// and UseRetentionPolicy, or those of the batch passed to Write.
+type RouteFunc func(p *influxClient.Point, route string, def Destination) (Destination, error)
+
+// RouteToDatabase returns a RouteFunc that writes points to the database
+// named by format, with %s replaced by the route value, and the default
+// retention policy. If the point has no route value, the value of tag is
+// used, so batches passed to Write are also routed. Points without either
+// are written to the default destination.
+//
+//	c.UseRouter(RouteToDatabase("customer_%s", "customer"))
+func RouteToDatabase(format, tag string) RouteFunc {
+	return func(p *influxClient.Point, route string, def Destination) (Destination, error) {
+		if route == "" && tag != "" {
+			route = p.Tags()[tag]
+		}
+
+		if route == "" {
+			return def, nil
+		}
+
+		return Destination{fmt.Sprintf(format, route), def.RetentionPolicy}, nil
+	}
+}
+
+// routeTag returns the key of the tag with the route option in the struct
+// d, or "" if there is none.
+func routeTag(d interface{}) string {
+	t := reflect.TypeOf(d)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return ""
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		fieldData := getInfluxFieldTagData(f.Name, f.Tag.Get("influx"))
+		if fieldData.route && fieldData.isTag {
+			return fieldData.fieldName
+		}
+	}
+
+	return ""
+}
+
+// routedPoint is a point to write, with its route value.
+type routedPoint struct {
+	point *influxClient.Point
+	route string
+}
+
+// destination returns the destination of p, using the router of c. Without
+// a router, route values are ignored, so that they cannot select any
+// database, such as _internal or that of another tenant.
+func (c *helperClient) destination(p routedPoint, def Destination) (Destination, error) {
+	if c.router != nil {
+		dest, err := c.router(p.point, p.route, def)
+		if err != nil {
+			return Destination{}, err
+		}
+		if dest.Database == "" {
+			return Destination{}, fmt.Errorf("no database for point %v", p.point.Name())
+		}
+		return dest, nil
+	}
+
+	return def, nil
+}
+
+// writeRouted groups points by destination, and writes each group with
+// one write. If a write fails, the other groups are still written, and
+// the first error is returned.
+func (c *helperClient) writeRouted(points []routedPoint, def Destination, precision string) error {
+	var order []Destination
+	batches := make(map[Destination]influxClient.BatchPoints)
+
+	for _, p := range points {
+		dest, err := c.destination(p, def)
+		if err != nil {
+			return err
+		}
+
+		bp, ok := batches[dest]
+		if !ok {
+			bp, err = influxClient.NewBatchPoints(influxClient.BatchPointsConfig{
+				Database:        dest.Database,
+				RetentionPolicy: dest.RetentionPolicy,
+				Precision:       precision,
+			})
+			if err != nil {
+				return err
+			}
+			batches[dest] = bp
+			order = append(order, dest)
+		}
+
+		bp.AddPoint(p.point)
+	}
+
+	var ret error
+	for _, dest := range order {
+		if err := c.writeBatch(batches[dest]); err != nil && ret == nil {
+			ret = err
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,164 @@
+package influxdbhelper
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+type tenantSample struct {
+	InfluxMeasurement Measurement
+	Time              time.Time `influx:"time"`
+	Customer          string    `influx:"customer,tag,route"`
+	Value             float64   `influx:"value"`
+}
+
+func TestWritePointsRouteTag(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+	s.CreateDatabase("tenant_acme")
+	s.CreateDatabase("tenant_globex")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := []tenantSample{
+		{"env", start, "acme", 1},
+		{"env", start, "globex", 2},
+		{"env", start.Add(time.Second), "acme", 3},
+		{"env", start, "", 4},
+	}
+
+	// without a router, route values do not select the database
+	if err := c.UseDB("db").WritePoints(samples); err != nil {
+		t.Fatal("Error writing points: ", err)
+	}
+	if err := c.UseDB("db").WritePoint(tenantSample{"env", start, "_internal", 5}); err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+
+	if n := len(s.Points("db")); n != 5 {
+		t.Errorf("%v != 5", n)
+	}
+
+	c.UseRouter(RouteToDatabase("tenant_%s", ""))
+	if err := c.UseDB("db").WritePoints(samples); err != nil {
+		t.Fatal("Error writing points: ", err)
+	}
+
+	exp := map[string]int{"tenant_acme": 2, "tenant_globex": 1, "db": 6}
+	for db, n := range exp {
+		if points := s.Points(db); len(points) != n {
+			t.Errorf("%v: %v != %v", db, len(points), n)
+		}
+	}
+
+	if n := countRequests(s, "/write"); n != 5 {
+		t.Errorf("%v != 5", n)
+	}
+
+	// single points are routed too
+	if err := c.UseDB("db").WritePoint(&samples[1]); err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+	if n := len(s.Points("tenant_globex")); n != 2 {
+		t.Errorf("%v != 2", n)
+	}
+}
+
+func TestRouteToDatabase(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+	s.CreateDatabase("customer_acme")
+	s.CreateDatabase("customer_globex")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseRouter(RouteToDatabase("customer_%s", "customer"))
+
+	bp, _ := influxClient.NewBatchPoints(influxClient.BatchPointsConfig{Database: "db"})
+	for _, customer := range []string{"acme", "globex", "acme", ""} {
+		tags := map[string]string{}
+		if customer != "" {
+			tags["customer"] = customer
+		}
+		pt, err := influxClient.NewPoint("env", tags, map[string]interface{}{"value": 1.0}, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		bp.AddPoint(pt)
+	}
+
+	if err := c.Write(bp); err != nil {
+		t.Fatal("Error writing batch: ", err)
+	}
+
+	exp := map[string]int{"customer_acme": 2, "customer_globex": 1, "db": 1}
+	for db, n := range exp {
+		if points := s.Points(db); len(points) != n {
+			t.Errorf("%v: %v != %v", db, len(points), n)
+		}
+	}
+
+	// the route value of structs has priority over the tag
+	if err := c.UseDB("db").WritePoint(tenantSample{"env", time.Now(), "globex", 1}); err != nil {
+		t.Fatal("Error writing point: ", err)
+	}
+	if n := len(s.Points("customer_globex")); n != 2 {
+		t.Errorf("%v != 2", n)
+	}
+}
+
+func TestRouterErrors(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	s.CreateDatabase("db")
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+
+	type badRoute struct {
+		Time     time.Time `influx:"time"`
+		Customer string    `influx:"customer,route"`
+	}
+	if err := c.UseDB("db").WritePoint(badRoute{time.Now(), "acme"}); err == nil {
+		t.Error("Expected route option error")
+	}
+
+	if err := c.UseDB("db").WritePoints(tenantSample{}); err == nil {
+		t.Error("Expected slice error")
+	}
+
+	errRoute := errors.New("unknown customer")
+	c.UseRouter(func(p *influxClient.Point, route string, def Destination) (Destination, error) {
+		if route == "initech" {
+			return Destination{}, errRoute
+		}
+		return def, nil
+	})
+
+	samples := []tenantSample{{"env", time.Now(), "acme", 1}, {"env", time.Now(), "initech", 2}}
+	if err := c.UseDB("db").WritePoints(samples); err != errRoute {
+		t.Errorf("%v != %v", err, errRoute)
+	}
+
+	if n := len(s.Points("db")); n != 0 {
+		t.Errorf("%v != 0", n)
+	}
+
+	// a write to one destination fails, the others are written
+	s.CreateDatabase("acme")
+	c.UseRouter(RouteToDatabase("%s", ""))
+	err := c.UseDB("db").WritePoints(samples)
+	var writeErr *WriteError
+	if !errors.As(err, &writeErr) {
+		t.Errorf("Expected *WriteError, got: %v", err)
+	}
+
+	if n := len(s.Points("acme")); n != 1 {
+		t.Errorf("%v != 1", n)
+	}
+}
@@ -1,21 +1,76 @@
 package influxdbhelper
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 )
 
 // Measurement is a type that defines the influx db measurement.
 type Measurement = string
 
+// InfluxDb field types that can be set with a struct tag option.
+const (
+	fieldTypeInteger  = "int"
+	fieldTypeFloat    = "float"
+	fieldTypeString   = "string"
+	fieldTypeBoolean  = "bool"
+	fieldTypeUnsigned = "unsigned"
+)
+
+var fieldTypeOptions = map[string]string{
+	"int":      fieldTypeInteger,
+	"integer":  fieldTypeInteger,
+	"float":    fieldTypeFloat,
+	"string":   fieldTypeString,
+	"bool":     fieldTypeBoolean,
+	"boolean":  fieldTypeBoolean,
+	"unsigned": fieldTypeUnsigned,
+}
+
 type influxFieldTagData struct {
 	fieldName string
 	isTag     bool
 	isField   bool
+	// isTagMap and isFieldMap are set for map fields tagged with the tags
+	// or fields option, whose entries are written as tags or fields.
+	isTagMap   bool
+	isFieldMap bool
+	// fieldType is the InfluxDb type the field is coerced to, or "" to
+	// use the Go type.
+	fieldType string
+
+	// tag value normalization and validation options
+	trim     bool
+	lower    bool
+	required bool
+	maxLen   int
+	oneOf    []string
+	// optionErr records an invalid option value, reported on encode.
+	optionErr error
+
+	// rollup is the aggregate used for the field by RollupQuery.
+	rollup string
+
+	// route is set for the tag whose value selects the destination of
+	// the point.
+	route bool
+}
+
+// getMeasurementTag returns the measurement from a measurement=name option
+// in a struct tag, or "" if there is none.
+func getMeasurementTag(structTag string) string {
+	for _, part := range splitTopLevel(structTag, ',') {
+		if strings.HasPrefix(part, "measurement=") {
+			return strings.TrimPrefix(part, "measurement=")
+		}
+	}
+	return ""
 }
 
 func getInfluxFieldTagData(fieldName, structTag string) (fieldData *influxFieldTagData) {
 	fieldData = &influxFieldTagData{fieldName: fieldName}
-	parts := strings.Split(structTag, ",")
+	parts := splitTopLevel(structTag, ',')
 	fieldName, parts = parts[0], parts[1:]
 	if fieldName != "" {
 		fieldData.fieldName = fieldName
@@ -28,6 +83,35 @@ func getInfluxFieldTagData(fieldName, structTag string) (fieldData *influxFieldT
 		if part == "field" {
 			fieldData.isField = true
 		}
+		if part == "tags" {
+			fieldData.isTagMap = true
+		}
+		if part == "fields" {
+			fieldData.isFieldMap = true
+		}
+		if t, ok := fieldTypeOptions[part]; ok {
+			fieldData.fieldType = t
+		}
+		switch {
+		case part == "trim":
+			fieldData.trim = true
+		case part == "lower" || part == "lowercase":
+			fieldData.lower = true
+		case part == "required":
+			fieldData.required = true
+		case strings.HasPrefix(part, "maxlen="):
+			n, err := strconv.Atoi(strings.TrimPrefix(part, "maxlen="))
+			if err != nil || n < 0 {
+				fieldData.optionErr = fmt.Errorf("invalid option %q", part)
+			}
+			fieldData.maxLen = n
+		case strings.HasPrefix(part, "oneof="):
+			fieldData.oneOf = strings.Split(strings.TrimPrefix(part, "oneof="), "|")
+		case strings.HasPrefix(part, "rollup="):
+			fieldData.rollup = strings.TrimPrefix(part, "rollup=")
+		case part == "route":
+			fieldData.route = true
+		}
 	}
 
 	if !fieldData.isField && !fieldData.isTag {
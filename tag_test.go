@@ -36,3 +36,37 @@ func TestTag(t *testing.T) {
 		}
 	}
 }
+
+func TestTagFunction(t *testing.T) {
+	fieldData := getInfluxFieldTagData("Test", "percentile(humidity,95),field")
+	if fieldData.fieldName != "percentile(humidity,95)" || !fieldData.isField {
+		t.Errorf("Unexpected field data: %+v", fieldData)
+	}
+}
+
+func TestTagFieldType(t *testing.T) {
+	data := []struct {
+		fieldTag  string
+		fieldType string
+		isTag     bool
+	}{
+		{"count", "", false},
+		{"count,int", fieldTypeInteger, false},
+		{"count,integer", fieldTypeInteger, false},
+		{"count,field,float", fieldTypeFloat, false},
+		{"count,string", fieldTypeString, false},
+		{"count,boolean", fieldTypeBoolean, false},
+		{"count,unsigned", fieldTypeUnsigned, false},
+		{"count,tag", "", true},
+	}
+
+	for _, testData := range data {
+		fieldData := getInfluxFieldTagData("Count", testData.fieldTag)
+		if fieldData.fieldType != testData.fieldType {
+			t.Errorf("%v != %v", fieldData.fieldType, testData.fieldType)
+		}
+		if fieldData.isTag != testData.isTag {
+			t.Errorf("%v != %v", fieldData.isTag, testData.isTag)
+		}
+	}
+}
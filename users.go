@@ -0,0 +1,167 @@
+package influxdbhelper
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Privilege is a privilege a user can be granted on a database.
+type Privilege string
+
+// Privileges that can be granted on a database.
+const (
+	ReadPrivilege  Privilege = "READ"
+	WritePrivilege Privilege = "WRITE"
+	AllPrivileges  Privilege = "ALL PRIVILEGES"
+	// NoPrivileges is reported by ShowGrants for a database on which all
+	// privileges have been revoked.
+	NoPrivileges Privilege = "NO PRIVILEGES"
+)
+
+// User is a user account on the server.
+type User struct {
+	Name  string `influx:"user"`
+	Admin bool   `influx:"admin"`
+}
+
+// Grant is a privilege granted to a user on a database.
+type Grant struct {
+	Database  string    `influx:"database"`
+	Privilege Privilege `influx:"privilege"`
+}
+
+// checkPrivilege returns an error if p is not a privilege that can be granted
+// or revoked, as it is written into the statement unquoted.
+func checkPrivilege(p Privilege) error {
+	switch p {
+	case ReadPrivilege, WritePrivilege, AllPrivileges:
+		return nil
+	}
+	return fmt.Errorf("invalid privilege %q", string(p))
+}
+
+// CreateUser creates a user if no user with the same name exists. An
+// existing user is not changed; use SetPassword, Grant and Revoke to update
+// it. An admin user has all privileges on all databases.
+func (c *helperClient) CreateUser(name, password string, admin bool) error {
+	users, err := c.ListUsers()
+	if err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		if u.Name == name {
+			return nil
+		}
+	}
+
+	q := "CREATE USER " + QuoteIdent(name) + " WITH PASSWORD " + QuoteString(password)
+	if admin {
+		q += " WITH ALL PRIVILEGES"
+	}
+
+	_, err = c.execute("", q)
+	return err
+}
+
+// rePassword matches the password literals of CREATE USER and SET PASSWORD
+// statements.
+var rePassword = regexp.MustCompile(`(?i)(\bWITH\s+PASSWORD\s+|\bSET\s+PASSWORD\s+FOR\s+(?:"(?:[^"\\]|\\.)*"|[^\s=]+)\s*=\s*)'(?:[^'\\]|\\.)*'`)
+
+// redactPasswords replaces the password literals in q with [REDACTED], so
+// they are not exposed in errors, logs and traces.
+func redactPasswords(q string) string {
+	return rePassword.ReplaceAllString(q, "${1}[REDACTED]")
+}
+
+// DropUser drops a user, if it exists.
+func (c *helperClient) DropUser(name string) error {
+	_, err := c.execute("", "DROP USER "+QuoteIdent(name))
+
+	var qErr *QueryError
+	if errors.As(err, &qErr) && strings.Contains(qErr.Message, "user not found") {
+		return nil
+	}
+
+	return err
+}
+
+// ListUsers returns the users on the server.
+func (c *helperClient) ListUsers() ([]User, error) {
+	rows, err := c.execute("", "SHOW USERS")
+	if err != nil {
+		return nil, err
+	}
+
+	users := []User{}
+	if err := decode(rows, &users); err != nil {
+		return nil, &DecodeError{err}
+	}
+
+	return users, nil
+}
+
+// SetPassword sets the password of a user.
+func (c *helperClient) SetPassword(name, password string) error {
+	_, err := c.execute("", "SET PASSWORD FOR "+QuoteIdent(name)+" = "+QuoteString(password))
+	return err
+}
+
+// Grant grants privilege on db to a user, replacing any privilege the user
+// already has on db. privilege must be ReadPrivilege, WritePrivilege or
+// AllPrivileges. If db is empty, the user is made an admin, and privilege
+// must be AllPrivileges.
+func (c *helperClient) Grant(user string, privilege Privilege, db string) error {
+	if err := checkPrivilege(privilege); err != nil {
+		return err
+	}
+
+	if db == "" {
+		if privilege != AllPrivileges {
+			return errors.New("only all privileges can be granted on all databases")
+		}
+		_, err := c.execute("", "GRANT ALL PRIVILEGES TO "+QuoteIdent(user))
+		return err
+	}
+
+	_, err := c.execute("", "GRANT "+string(privilege)+" ON "+QuoteIdent(db)+" TO "+QuoteIdent(user))
+	return err
+}
+
+// Revoke revokes privilege on db from a user. Revoking ReadPrivilege from a
+// user with AllPrivileges leaves WritePrivilege, and vice versa. If db is
+// empty, admin privileges are revoked, and privilege must be AllPrivileges.
+func (c *helperClient) Revoke(user string, privilege Privilege, db string) error {
+	if err := checkPrivilege(privilege); err != nil {
+		return err
+	}
+
+	if db == "" {
+		if privilege != AllPrivileges {
+			return errors.New("only all privileges can be revoked on all databases")
+		}
+		_, err := c.execute("", "REVOKE ALL PRIVILEGES FROM "+QuoteIdent(user))
+		return err
+	}
+
+	_, err := c.execute("", "REVOKE "+string(privilege)+" ON "+QuoteIdent(db)+" FROM "+QuoteIdent(user))
+	return err
+}
+
+// ShowGrants returns the privileges granted to a user on each database.
+// Admin privileges are reported by ListUsers.
+func (c *helperClient) ShowGrants(user string) ([]Grant, error) {
+	rows, err := c.execute("", "SHOW GRANTS FOR "+QuoteIdent(user))
+	if err != nil {
+		return nil, err
+	}
+
+	grants := []Grant{}
+	if err := decode(rows, &grants); err != nil {
+		return nil, &DecodeError{err}
+	}
+
+	return grants, nil
+}
@@ -0,0 +1,202 @@
+package influxdbhelper
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+func TestUserAdmin(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+
+	for i := 0; i < 2; i++ {
+		if err := c.CreateUser("tenant", "secret", false); err != nil {
+			t.Fatal("Error creating user: ", err)
+		}
+	}
+
+	if err := c.CreateUser("root", "secret", true); err != nil {
+		t.Fatal("Error creating user: ", err)
+	}
+
+	users, err := c.ListUsers()
+	if err != nil {
+		t.Fatal("Error listing users: ", err)
+	}
+
+	expectedUsers := []User{{"root", true}, {"tenant", false}}
+	if !reflect.DeepEqual(users, expectedUsers) {
+		t.Errorf("%+v != %+v", users, expectedUsers)
+	}
+
+	if err := c.SetPassword("tenant", "new secret"); err != nil {
+		t.Error("Error setting password: ", err)
+	}
+
+	for _, g := range []Grant{{"db1", AllPrivileges}, {"db2", ReadPrivilege}} {
+		if err := c.Grant("tenant", g.Privilege, g.Database); err != nil {
+			t.Fatal("Error granting privilege: ", err)
+		}
+	}
+
+	if err := c.Revoke("tenant", ReadPrivilege, "db1"); err != nil {
+		t.Fatal("Error revoking privilege: ", err)
+	}
+
+	grants, err := c.ShowGrants("tenant")
+	if err != nil {
+		t.Fatal("Error showing grants: ", err)
+	}
+
+	expectedGrants := []Grant{{"db1", WritePrivilege}, {"db2", ReadPrivilege}}
+	if !reflect.DeepEqual(grants, expectedGrants) {
+		t.Errorf("%+v != %+v", grants, expectedGrants)
+	}
+
+	if err := c.Grant("tenant", ReadPrivilege, ""); err == nil {
+		t.Error("Expected error granting read on all databases")
+	}
+
+	if err := c.Grant("tenant", AllPrivileges, ""); err != nil {
+		t.Error("Error granting admin: ", err)
+	}
+
+	if err := c.Revoke("tenant", AllPrivileges, ""); err != nil {
+		t.Error("Error revoking admin: ", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.DropUser("tenant"); err != nil {
+			t.Fatal("Error dropping user: ", err)
+		}
+	}
+
+	users, _ = c.ListUsers()
+	if len(users) != 1 {
+		t.Errorf("Unexpected users: %+v", users)
+	}
+}
+
+func TestSetPasswordRedacted(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+
+	err := c.SetPassword("nobody", "secret")
+	var qErr *QueryError
+	if !errors.As(err, &qErr) {
+		t.Fatalf("Expected *QueryError, got %v", err)
+	}
+
+	if strings.Contains(qErr.Statement, "secret") {
+		t.Errorf("Password not redacted: %v", qErr.Statement)
+	}
+}
+
+func TestSetPasswordConnectionError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Error listening: ", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	var events []Event
+	c, _ := NewClient("http://"+addr, "", "", "ns")
+	c.UseObserver(ObserverFunc(func(e Event) {
+		events = append(events, e)
+	}))
+
+	err = c.SetPassword("bob", "s3cret")
+	if !errors.Is(err, ErrConnection) {
+		t.Fatalf("Expected connection error, got %v", err)
+	}
+
+	if strings.Contains(err.Error(), "s3cret") || strings.Contains(err.Error(), "PASSWORD") {
+		t.Errorf("Password not redacted: %v", err)
+	}
+
+	for _, e := range events {
+		if e.Err != nil && strings.Contains(e.Err.Error(), "s3cret") {
+			t.Errorf("Password not redacted: %v", e.Err)
+		}
+	}
+}
+
+func TestGrantInvalidPrivilege(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+
+	c, _ := NewClient(s.URL, "", "", "ns")
+
+	privilege := Privilege("READ ON x TO y; DROP DATABASE z --")
+	if err := c.Grant("bob", privilege, "db"); err == nil {
+		t.Error("Expected error granting invalid privilege")
+	}
+	if err := c.Revoke("bob", privilege, "db"); err == nil {
+		t.Error("Expected error revoking invalid privilege")
+	}
+	if err := c.Grant("bob", NoPrivileges, "db"); err == nil {
+		t.Error("Expected error granting no privileges")
+	}
+
+	if n := len(s.Requests()); n != 0 {
+		t.Errorf("%v != 0", n)
+	}
+}
+
+func TestRedactPasswords(t *testing.T) {
+	data := map[string]string{
+		`CREATE USER "bob" WITH PASSWORD 'it\'s secret' WITH ALL PRIVILEGES`: `CREATE USER "bob" WITH PASSWORD [REDACTED] WITH ALL PRIVILEGES`,
+		`set password for "b = ob" = 'secret'`:                               `set password for "b = ob" = [REDACTED]`,
+		`SET PASSWORD FOR bob='secret'; SHOW USERS`:                          `SET PASSWORD FOR bob=[REDACTED]; SHOW USERS`,
+		`SELECT * FROM env WHERE password = 'secret'`:                        `SELECT * FROM env WHERE password = 'secret'`,
+	}
+
+	for in, out := range data {
+		if r := redactPasswords(in); r != out {
+			t.Errorf("%v != %v", r, out)
+		}
+	}
+}
+
+func TestUserObserverRedacted(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+
+	var events []Event
+	c, _ := NewClient(s.URL, "", "", "ns")
+	c.UseObserver(ObserverFunc(func(e Event) {
+		events = append(events, e)
+	}))
+
+	if err := c.CreateUser("tenant", "secret", false); err != nil {
+		t.Fatal("Error creating user: ", err)
+	}
+	if err := c.SetPassword("tenant", "new secret"); err != nil {
+		t.Fatal("Error setting password: ", err)
+	}
+	c.SetPassword("nobody", "secret")
+
+	if len(events) != 4 {
+		t.Fatalf("%v != 4", len(events))
+	}
+
+	for _, e := range events {
+		if strings.Contains(e.Query, "secret") {
+			t.Errorf("Password not redacted: %v", e.Query)
+		}
+		var qErr *QueryError
+		if errors.As(e.Err, &qErr) && strings.Contains(qErr.Statement, "secret") {
+			t.Errorf("Password not redacted: %v", qErr.Statement)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package influxdbhelper
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// tagValue converts a struct field to a tag value, applying the
+// normalization and validation options of the field tag:
+//
+//	trim       remove leading and trailing white space
+//	lower      convert to lower case
+//	required   return an error if the value is empty, rather than omitting
+//	           the tag
+//	maxlen=N   return an error if the value is longer than N characters
+//	oneof=a|b  return an error if the value is not one of the listed values
+//
+// Normalization is applied before validation, so
+// `influx:"env,tag,trim,lower,oneof=prod|dev"` accepts " Prod ".
+func tagValue(f reflect.Value, fieldData *influxFieldTagData) (string, error) {
+	if fieldData.optionErr != nil {
+		return "", fieldData.optionErr
+	}
+
+	for f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface {
+		if f.IsNil() {
+			break
+		}
+		f = f.Elem()
+	}
+
+	v := ""
+	if (f.Kind() != reflect.Ptr && f.Kind() != reflect.Interface) || !f.IsNil() {
+		v = fmt.Sprintf("%v", f)
+	}
+
+	if fieldData.trim {
+		v = strings.TrimSpace(v)
+	}
+
+	if fieldData.lower {
+		v = strings.ToLower(v)
+	}
+
+	if v == "" {
+		if fieldData.required {
+			return "", errors.New("tag value is required")
+		}
+		return "", nil
+	}
+
+	if fieldData.maxLen > 0 && utf8.RuneCountInString(v) > fieldData.maxLen {
+		return "", fmt.Errorf("tag value %q is longer than %v characters", v, fieldData.maxLen)
+	}
+
+	if len(fieldData.oneOf) > 0 {
+		for _, allowed := range fieldData.oneOf {
+			if v == allowed {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("tag value %q is not one of %v", v, strings.Join(fieldData.oneOf, ", "))
+	}
+
+	return v, nil
+}
@@ -0,0 +1,67 @@
+package influxdbhelper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodeTagNormalization(t *testing.T) {
+	name := " Rm 243 "
+	type Sample struct {
+		Location *string `influx:"location,tag,trim"`
+		Env      string  `influx:"env,tag,trim,lower,oneof=prod|dev"`
+		Host     string  `influx:"host,tag"`
+		Sensor   *string `influx:"sensor,tag"`
+		Value    float64 `influx:"value"`
+	}
+
+	_, tags, _, _, err := encode(Sample{&name, " Prod", "", nil, 1}, nil)
+	if err != nil {
+		t.Fatal("Error encoding: ", err)
+	}
+
+	expected := map[string]string{
+		"location": "Rm 243",
+		"env":      "prod",
+	}
+
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("%v != %v", tags, expected)
+	}
+}
+
+func TestEncodeTagValidation(t *testing.T) {
+	type Sample struct {
+		Location string  `influx:"location,tag,required,trim"`
+		Env      string  `influx:"env,tag,oneof=prod|dev"`
+		Host     string  `influx:"host,tag,maxlen=4"`
+		Value    float64 `influx:"value"`
+	}
+
+	_, _, _, _, err := encode(Sample{"rm", "prod", "abcd", 1}, nil)
+	if err != nil {
+		t.Error("Error encoding valid tags: ", err)
+	}
+
+	_, _, _, _, err = encode(Sample{"  ", "test", "abcde", 1}, nil)
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Expected *Error, got %v", err)
+	}
+
+	if len(e.Errors) != 3 {
+		t.Errorf("Unexpected errors: %v", e.Errors)
+	}
+}
+
+func TestEncodeTagInvalidOption(t *testing.T) {
+	type Sample struct {
+		Host  string  `influx:"host,tag,maxlen=x"`
+		Value float64 `influx:"value"`
+	}
+
+	_, _, _, _, err := encode(Sample{"a", 1}, nil)
+	if err == nil {
+		t.Error("Expected error for invalid maxlen option")
+	}
+}
@@ -0,0 +1,645 @@
+package influxdbhelper
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	influxModels "github.com/influxdata/influxdb1-client/models"
+	influxClient "github.com/influxdata/influxdb1-client/v2"
+)
+
+// ErrWALFull is returned by WALWriter writes when the buffer has reached
+// MaxDiskUsage and the eviction policy is EvictNone.
+var ErrWALFull = errors.New("write-ahead buffer is full")
+
+// EvictionPolicy defines what a WALWriter does when the buffer reaches
+// its maximum disk usage.
+type EvictionPolicy int
+
+const (
+	// EvictOldest deletes the oldest buffered segments to make room for
+	// new points.
+	EvictOldest EvictionPolicy = iota
+
+	// EvictNone rejects new points with ErrWALFull.
+	EvictNone
+)
+
+// WALConfig is used to configure a WALWriter.
+type WALConfig struct {
+	// Dir is the directory the segment files are stored in. It is created
+	// if it does not exist.
+	Dir string
+
+	// DB, RetentionPolicy and Precision are used for all writes. Precision
+	// defaults to "ns".
+	DB              string
+	RetentionPolicy string
+	Precision       string
+
+	// Measurement is used for WritePointTagsFields, and for WritePoint if
+	// set. Otherwise the measurement is determined from the data.
+	Measurement string
+
+	// Naming converts type names to measurement names in WritePoint.
+	Naming NamingStrategy
+
+	// TimeField is the name of the time field used by WritePoint, defaults
+	// to "time".
+	TimeField string
+
+	// MaxSegmentSize is the size in bytes at which a new segment file is
+	// started, defaults to 4MB.
+	MaxSegmentSize int64
+
+	// MaxDiskUsage is the maximum number of bytes buffered on disk. 0
+	// means no limit.
+	MaxDiskUsage int64
+
+	// Eviction selects what happens when MaxDiskUsage is reached.
+	Eviction EvictionPolicy
+
+	// ReplayInterval, if set, starts a goroutine that replays buffered
+	// points at this interval.
+	ReplayInterval time.Duration
+
+	// NoSync disables the fsync after every append. This is faster, but
+	// points may be lost if the machine crashes.
+	NoSync bool
+
+	// Transient reports whether a write error indicates InfluxDb is
+	// unreachable and the points should be buffered. Defaults to the
+	// classification of DefaultRetryPolicy. Points that fail with other
+	// errors are returned to the caller, or dropped during replay.
+	Transient func(err error) bool
+}
+
+// WALStats reports the state of a WALWriter.
+type WALStats struct {
+	// PendingBytes is the number of bytes buffered but not yet replayed.
+	PendingBytes int64
+	Segments     int
+	Buffered     uint64
+	Replayed     uint64
+	Dropped      uint64
+	EvictedBytes int64
+}
+
+type walSegment struct {
+	id   uint64
+	size int64
+}
+
+func (s *walSegment) name() string {
+	return fmt.Sprintf("%016x.wal", s.id)
+}
+
+// WALWriter writes points to InfluxDb through a Client. When InfluxDb is
+// unreachable, points are appended to segment files on disk and replayed
+// in order once it is reachable again. Replay progress is checkpointed, so
+// points survive restarts of the process.
+//
+// A batch that was written to InfluxDb just before a crash may be replayed
+// a second time. Points are always stored with a timestamp, so InfluxDb
+// overwrites rather than duplicates them.
+type WALWriter struct {
+	client Client
+	config WALConfig
+
+	mu       sync.Mutex
+	segments []*walSegment
+	active   *os.File
+	// readOffset is the offset of the first unreplayed record in
+	// segments[0].
+	readOffset int64
+	stats      WALStats
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+const (
+	walCheckpointFile = "checkpoint"
+	walHeaderSize     = 8
+)
+
+// NewWALWriter opens or creates the write-ahead buffer in config.Dir and
+// returns a writer that uses c to write to InfluxDb.
+func NewWALWriter(c Client, config WALConfig) (*WALWriter, error) {
+	if config.Dir == "" {
+		return nil, errors.New("WAL directory is required")
+	}
+	if config.DB == "" {
+		return nil, ErrNoDatabase
+	}
+	if config.MaxSegmentSize <= 0 {
+		config.MaxSegmentSize = 4 << 20
+	}
+	if config.Precision == "" {
+		config.Precision = "ns"
+	}
+	if config.TimeField == "" {
+		config.TimeField = "time"
+	}
+	if config.Transient == nil {
+		policy := DefaultRetryPolicy()
+		config.Transient = policy.IsRetryable
+	}
+
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	w := &WALWriter{
+		client: c,
+		config: config,
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	if config.ReplayInterval > 0 {
+		w.stop = make(chan struct{})
+		w.done = make(chan struct{})
+		go w.run()
+	}
+
+	return w, nil
+}
+
+// open loads the segments and checkpoint found in the directory.
+func (w *WALWriter) open() error {
+	files, err := filepath.Glob(filepath.Join(w.config.Dir, "*.wal"))
+	if err != nil {
+		return err
+	}
+
+	checkpointID, checkpointOffset, err := w.readCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		id, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(f), ".wal"), 16, 64)
+		if err != nil {
+			continue
+		}
+
+		if id < checkpointID {
+			// fully replayed, but not removed before a restart
+			os.Remove(f)
+			continue
+		}
+
+		// truncate any partially written record at the end of the segment
+		size, err := validSegmentSize(f)
+		if err != nil {
+			return err
+		}
+		if err := os.Truncate(f, size); err != nil {
+			return err
+		}
+
+		w.segments = append(w.segments, &walSegment{id: id, size: size})
+	}
+
+	sort.Slice(w.segments, func(i, j int) bool {
+		return w.segments[i].id < w.segments[j].id
+	})
+
+	if len(w.segments) > 0 && w.segments[0].id == checkpointID {
+		w.readOffset = checkpointOffset
+	}
+
+	w.updatePending()
+	return nil
+}
+
+func (w *WALWriter) readCheckpoint() (id uint64, offset int64, err error) {
+	data, err := ioutil.ReadFile(filepath.Join(w.config.Dir, walCheckpointFile))
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	_, err = fmt.Sscanf(string(data), "%x %d", &id, &offset)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid WAL checkpoint: %v", err)
+	}
+
+	return id, offset, nil
+}
+
+func (w *WALWriter) writeCheckpoint() error {
+	var id uint64
+	if len(w.segments) > 0 {
+		id = w.segments[0].id
+	}
+
+	path := filepath.Join(w.config.Dir, walCheckpointFile)
+	tmp := path + ".tmp"
+	data := fmt.Sprintf("%x %d\n", id, w.readOffset)
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(data); err != nil {
+		f.Close()
+		return err
+	}
+	if !w.config.NoSync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// validSegmentSize returns the size of the segment up to the end of the
+// last complete record.
+func validSegmentSize(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var size int64
+	for {
+		payload, err := readRecord(r)
+		if err != nil {
+			return size, nil
+		}
+		size += walHeaderSize + int64(len(payload))
+	}
+}
+
+// record format: 4 byte payload length, 4 byte CRC32 of payload, payload.
+func encodeRecord(payload []byte) []byte {
+	buf := make([]byte, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[walHeaderSize:], payload)
+	return buf
+}
+
+func readRecord(r io.Reader) ([]byte, error) {
+	var header [walHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, errors.New("WAL record checksum mismatch")
+	}
+
+	return payload, nil
+}
+
+// WritePoint encodes data like Client.WritePoint and writes it.
+func (w *WALWriter) WritePoint(data interface{}) error {
+	t, tags, fields, measurement, err := encodeWithNaming(data, &usingValue{w.config.TimeField, true}, w.config.Naming)
+	if err != nil {
+		return err
+	}
+
+	if w.config.Measurement != "" {
+		measurement = w.config.Measurement
+	}
+
+	return w.writePoint(measurement, tags, fields, t)
+}
+
+// WritePointTagsFields writes a point to the configured Measurement.
+func (w *WALWriter) WritePointTagsFields(tags map[string]string, fields map[string]interface{}, t time.Time) error {
+	if w.config.Measurement == "" {
+		return ErrNoMeasurement
+	}
+
+	return w.writePoint(w.config.Measurement, tags, fields, t)
+}
+
+func (w *WALWriter) writePoint(measurement string, tags map[string]string, fields map[string]interface{}, t time.Time) error {
+	bp, err := w.newBatchPoints()
+	if err != nil {
+		return err
+	}
+
+	pt, err := influxClient.NewPoint(measurement, tags, fields, t)
+	if err != nil {
+		return err
+	}
+
+	bp.AddPoint(pt)
+	return w.Write(bp)
+}
+
+func (w *WALWriter) newBatchPoints() (influxClient.BatchPoints, error) {
+	return influxClient.NewBatchPoints(influxClient.BatchPointsConfig{
+		Database:        w.config.DB,
+		RetentionPolicy: w.config.RetentionPolicy,
+		Precision:       w.config.Precision,
+	})
+}
+
+// Write writes a batch of points. The database, retention policy and
+// precision of bp are replaced by the values in the WALConfig. If nothing
+// is buffered, the batch is written to InfluxDb directly, and only
+// buffered if that fails with a transient error. Otherwise the batch is
+// appended to the buffer behind the points already waiting to preserve
+// write order.
+func (w *WALWriter) Write(bp influxClient.BatchPoints) error {
+	// points without a time would get the server time when replayed
+	now := time.Now()
+	for i, p := range bp.Points() {
+		if p.Time().IsZero() {
+			fields, err := p.Fields()
+			if err != nil {
+				return err
+			}
+			pt, err := influxClient.NewPoint(p.Name(), p.Tags(), fields, now)
+			if err != nil {
+				return err
+			}
+			bp.Points()[i] = pt
+		}
+	}
+
+	bp.SetDatabase(w.config.DB)
+	bp.SetRetentionPolicy(w.config.RetentionPolicy)
+	if err := bp.SetPrecision(w.config.Precision); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stats.PendingBytes == 0 {
+		err := w.client.Write(bp)
+		if err == nil || !w.config.Transient(err) {
+			return err
+		}
+	}
+
+	return w.append(bp)
+}
+
+func (w *WALWriter) append(bp influxClient.BatchPoints) error {
+	var lines bytes.Buffer
+	for _, p := range bp.Points() {
+		lines.WriteString(p.PrecisionString(bp.Precision()))
+		lines.WriteByte('\n')
+	}
+
+	record := encodeRecord(lines.Bytes())
+	size := int64(len(record))
+
+	if w.config.MaxDiskUsage > 0 {
+		for w.diskUsage()+size > w.config.MaxDiskUsage {
+			if w.config.Eviction == EvictNone || len(w.segments) == 0 {
+				return ErrWALFull
+			}
+			if err := w.evictOldest(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if w.active != nil && w.segments[len(w.segments)-1].size+size > w.config.MaxSegmentSize {
+		if err := w.active.Close(); err != nil {
+			return err
+		}
+		w.active = nil
+	}
+
+	if w.active == nil {
+		var id uint64
+		if len(w.segments) > 0 {
+			id = w.segments[len(w.segments)-1].id + 1
+		}
+		seg := &walSegment{id: id}
+		f, err := os.OpenFile(filepath.Join(w.config.Dir, seg.name()),
+			os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		w.active = f
+		w.segments = append(w.segments, seg)
+		if len(w.segments) == 1 {
+			w.readOffset = 0
+			if err := w.writeCheckpoint(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := w.active.Write(record); err != nil {
+		return err
+	}
+	if !w.config.NoSync {
+		if err := w.active.Sync(); err != nil {
+			return err
+		}
+	}
+
+	w.segments[len(w.segments)-1].size += size
+	w.stats.Buffered++
+	w.updatePending()
+	return nil
+}
+
+func (w *WALWriter) diskUsage() int64 {
+	var ret int64
+	for _, s := range w.segments {
+		ret += s.size
+	}
+	return ret
+}
+
+// evictOldest deletes the oldest segment and its unreplayed points.
+func (w *WALWriter) evictOldest() error {
+	seg := w.segments[0]
+	if len(w.segments) == 1 && w.active != nil {
+		if err := w.active.Close(); err != nil {
+			return err
+		}
+		w.active = nil
+	}
+
+	w.stats.EvictedBytes += seg.size - w.readOffset
+	return w.removeFirstSegment()
+}
+
+func (w *WALWriter) removeFirstSegment() error {
+	seg := w.segments[0]
+	w.segments = w.segments[1:]
+	w.readOffset = 0
+	if err := w.writeCheckpoint(); err != nil {
+		return err
+	}
+	w.updatePending()
+	return os.Remove(filepath.Join(w.config.Dir, seg.name()))
+}
+
+func (w *WALWriter) updatePending() {
+	w.stats.PendingBytes = w.diskUsage() - w.readOffset
+	w.stats.Segments = len(w.segments)
+}
+
+// Flush replays buffered points to InfluxDb in the order they were
+// written. It stops and returns the error if a write fails with a
+// transient error. Batches that fail with any other error are dropped.
+func (w *WALWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for len(w.segments) > 0 {
+		seg := w.segments[0]
+		if err := w.replaySegment(seg); err != nil {
+			return err
+		}
+
+		if w.active != nil && len(w.segments) == 1 {
+			if err := w.active.Close(); err != nil {
+				return err
+			}
+			w.active = nil
+		}
+
+		if err := w.removeFirstSegment(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *WALWriter) replaySegment(seg *walSegment) error {
+	f, err := os.Open(filepath.Join(w.config.Dir, seg.name()))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(w.readOffset, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	for w.readOffset < seg.size {
+		payload, err := readRecord(r)
+		if err != nil {
+			return err
+		}
+
+		bp, err := w.newBatchPoints()
+		if err != nil {
+			return err
+		}
+
+		points, err := influxModels.ParsePointsWithPrecision(payload,
+			time.Now().UTC(), w.config.Precision)
+		if err == nil {
+			for _, p := range points {
+				bp.AddPoint(influxClient.NewPointFrom(p))
+			}
+			err = w.client.Write(bp)
+		}
+
+		if err != nil && w.config.Transient(err) {
+			return err
+		}
+
+		if err != nil {
+			w.stats.Dropped++
+		} else {
+			w.stats.Replayed++
+		}
+
+		w.readOffset += walHeaderSize + int64(len(payload))
+		if err := w.writeCheckpoint(); err != nil {
+			return err
+		}
+		w.updatePending()
+	}
+
+	return nil
+}
+
+// Stats returns the current state of the writer.
+func (w *WALWriter) Stats() WALStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stats
+}
+
+func (w *WALWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.config.ReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			pending := w.stats.PendingBytes
+			w.mu.Unlock()
+			if pending > 0 {
+				w.Flush()
+			}
+		}
+	}
+}
+
+// Close stops the replay goroutine and closes the active segment. Points
+// that have not been replayed remain on disk and are replayed by the next
+// WALWriter opened on the same directory.
+func (w *WALWriter) Close() error {
+	if w.stop != nil {
+		close(w.stop)
+		<-w.done
+		w.stop = nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.active != nil {
+		err := w.active.Close()
+		w.active = nil
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package influxdbhelper
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cbrake/influxdbhelper/v2/influxdbtest"
+)
+
+type walSample struct {
+	Time  time.Time `influx:"time"`
+	Value float64   `influx:"value"`
+}
+
+func newTestWAL(t *testing.T) (*influxdbtest.Server, string, func()) {
+	s := influxdbtest.NewServer()
+	s.CreateDatabase("db")
+
+	dir, err := ioutil.TempDir("", "influxdbhelper-wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, dir, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func openTestWAL(t *testing.T, s *influxdbtest.Server, config WALConfig) *WALWriter {
+	c, _ := NewClient(s.URL, "", "", "ns")
+	config.DB = "db"
+	config.Measurement = "test"
+	w, err := NewWALWriter(c, config)
+	if err != nil {
+		t.Fatal("Error opening WAL: ", err)
+	}
+	return w
+}
+
+func writeWALSamples(t *testing.T, w *WALWriter, start, n int) {
+	for i := start; i < start+n; i++ {
+		err := w.WritePoint(walSample{time.Unix(int64(i), 0), float64(i)})
+		if err != nil {
+			t.Fatal("Error writing point: ", err)
+		}
+	}
+}
+
+func checkServerValues(t *testing.T, s *influxdbtest.Server, n int) {
+	points := s.Points("db")
+	if len(points) != n {
+		t.Fatalf("Expected %v points, got %v", n, len(points))
+	}
+
+	for i, p := range points {
+		fields, _ := p.Fields()
+		if fields["value"] != float64(i) {
+			t.Errorf("point %v out of order: %v", i, fields["value"])
+		}
+	}
+}
+
+func TestWALBuffersWhenUnavailable(t *testing.T) {
+	s, dir, cleanup := newTestWAL(t)
+	defer cleanup()
+
+	w := openTestWAL(t, s, WALConfig{Dir: dir})
+	defer w.Close()
+
+	writeWALSamples(t, w, 0, 1)
+
+	s.FailNext(http.StatusServiceUnavailable, "unavailable")
+	writeWALSamples(t, w, 1, 1)
+
+	// later points are buffered behind the failed point to preserve order
+	writeWALSamples(t, w, 2, 2)
+
+	stats := w.Stats()
+	if stats.Buffered != 3 || stats.PendingBytes == 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatal("Error flushing: ", err)
+	}
+
+	checkServerValues(t, s, 4)
+
+	stats = w.Stats()
+	if stats.Replayed != 3 || stats.PendingBytes != 0 || stats.Segments != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestWALSurvivesRestart(t *testing.T) {
+	s, dir, cleanup := newTestWAL(t)
+	defer cleanup()
+
+	w := openTestWAL(t, s, WALConfig{Dir: dir, MaxSegmentSize: 64})
+	s.FailNext(http.StatusServiceUnavailable, "unavailable")
+	writeWALSamples(t, w, 0, 5)
+
+	// replay the first two points, then fail
+	s.FailNext(http.StatusOK, "")
+	s.FailNext(http.StatusOK, "")
+	s.FailNext(http.StatusServiceUnavailable, "unavailable")
+	if err := w.Flush(); err == nil {
+		t.Error("Expected flush error")
+	}
+	w.Close()
+
+	if w.Stats().Replayed != 2 {
+		t.Fatalf("Expected 2 replayed points, got %v", w.Stats().Replayed)
+	}
+
+	w = openTestWAL(t, s, WALConfig{Dir: dir, MaxSegmentSize: 64})
+	defer w.Close()
+
+	if err := w.Flush(); err != nil {
+		t.Fatal("Error flushing: ", err)
+	}
+
+	// the first two points were acknowledged by the fake server without
+	// being stored, so only the remaining three are expected
+	points := s.Points("db")
+	if len(points) != 3 {
+		t.Fatalf("Expected 3 points, got %v", len(points))
+	}
+
+	fields, _ := points[0].Fields()
+	if fields["value"] != 2.0 {
+		t.Errorf("Expected replay to resume at point 2, got %v", fields["value"])
+	}
+}
+
+func TestWALTruncatesPartialRecord(t *testing.T) {
+	s, dir, cleanup := newTestWAL(t)
+	defer cleanup()
+
+	w := openTestWAL(t, s, WALConfig{Dir: dir})
+	s.FailNext(http.StatusServiceUnavailable, "unavailable")
+	writeWALSamples(t, w, 0, 2)
+	w.Close()
+
+	// simulate a crash in the middle of an append
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+	f, _ := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0644)
+	f.Write([]byte{0, 0, 0, 100, 1, 2})
+	f.Close()
+
+	w = openTestWAL(t, s, WALConfig{Dir: dir})
+	defer w.Close()
+
+	if err := w.Flush(); err != nil {
+		t.Fatal("Error flushing: ", err)
+	}
+
+	checkServerValues(t, s, 2)
+}
+
+func TestWALEviction(t *testing.T) {
+	s, dir, cleanup := newTestWAL(t)
+	defer cleanup()
+
+	w := openTestWAL(t, s, WALConfig{Dir: dir, MaxSegmentSize: 64, MaxDiskUsage: 128})
+	defer w.Close()
+
+	for i := 0; i < 10; i++ {
+		s.FailNext(http.StatusServiceUnavailable, "unavailable")
+	}
+	writeWALSamples(t, w, 0, 10)
+
+	stats := w.Stats()
+	if stats.EvictedBytes == 0 || stats.PendingBytes > 128 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestWALEvictNone(t *testing.T) {
+	s, dir, cleanup := newTestWAL(t)
+	defer cleanup()
+
+	w := openTestWAL(t, s, WALConfig{Dir: dir, MaxDiskUsage: 64, Eviction: EvictNone})
+	defer w.Close()
+
+	s.FailNext(http.StatusServiceUnavailable, "unavailable")
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		err = w.WritePoint(walSample{time.Unix(int64(i), 0), float64(i)})
+	}
+
+	if err != ErrWALFull {
+		t.Errorf("Expected ErrWALFull, got: %v", err)
+	}
+}